@@ -18,23 +18,46 @@ package main
 
 import (
 	"flag"
+	"net/http"
 
 	"github.com/golang/glog"
 	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/cloud"
 	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/driver"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
 	var endpoint = flag.String("endpoint", "unix://tmp/csi.sock", "CSI Endpoint")
+	var volumeAttachLimit = flag.Int64("volume-attach-limit", -1, "Number of volumes that can be attached to the node, computed from the instance type when negative")
+	var apiQPS = flag.Float64("aws-api-qps", cloud.DefaultAPIQPS, "Sustained rate of EC2 requests per second, not limited when not positive")
+	var apiBurst = flag.Int("aws-api-burst", cloud.DefaultAPIBurst, "Number of EC2 requests that can be made at once above aws-api-qps")
+	var apiMaxRetries = flag.Int("aws-api-max-retries", cloud.DefaultAPIMaxRetries, "Number of times failed EC2 requests are retried, including throttled ones")
+	var apiBatchWindow = flag.Duration("aws-api-batch-window", cloud.DefaultAPIBatchWindow, "Maximum time during which the lookups of volumes and instances made while another one is in flight are collected into a single EC2 request, not batched when not positive")
+	var metricsAddress = flag.String("metrics-address", "", "Address to serve the Prometheus metrics on at /metrics, not served when empty")
 	flag.Parse()
 
-	cloud, err := cloud.NewCloud()
+	if *metricsAddress != "" {
+		go serveMetrics(*metricsAddress)
+	}
+
+	cloud, err := cloud.NewCloud(
+		cloud.WithAPIRateLimit(*apiQPS, *apiBurst),
+		cloud.WithAPIMaxRetries(*apiMaxRetries),
+		cloud.WithAPIBatchWindow(*apiBatchWindow),
+	)
 	if err != nil {
 		glog.Fatalln(err)
 	}
 
-	drv := driver.NewDriver(cloud, nil, *endpoint)
+	drv := driver.NewDriver(cloud, nil, *endpoint, driver.WithVolumeAttachLimit(*volumeAttachLimit))
 	if err := drv.Run(); err != nil {
 		glog.Fatalln(err)
 	}
 }
+
+func serveMetrics(address string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	glog.Infof("Serving metrics on address: %s", address)
+	glog.Fatalln(http.ListenAndServe(address, mux))
+}
@@ -0,0 +1,301 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/logging"
+)
+
+const (
+	// DefaultAPIBatchWindow is the default maximum time during which the lookups of volumes
+	// and instances by ID made while another lookup is in flight are collected before being
+	// made in a single request.
+	DefaultAPIBatchWindow = 100 * time.Millisecond
+
+	// maxBatchSize is the maximum number of IDs looked up in a single request, which is
+	// the maximum number of values of an EC2 filter.
+	maxBatchSize = 200
+)
+
+// batch is a set of IDs looked up in a single request, along with its results.
+type batch struct {
+	// ctx is the context of the caller that started the batch, whose values the request uses
+	ctx     context.Context
+	ids     []string
+	waiters int
+	flushed bool
+	cancel  context.CancelFunc
+
+	// done is closed once results and err are set
+	done    chan struct{}
+	results map[string]interface{}
+	err     error
+}
+
+// batcher coalesces the lookups by ID made while another lookup is in flight into a single
+// request, and fans the results out to the callers. Lookups made while none is in flight are
+// made right away, so that single lookups don't wait for the window.
+type batcher struct {
+	window  time.Duration
+	maxSize int
+	// fetch looks up the IDs and returns the resources found, keyed by ID
+	fetch func(ctx context.Context, ids []string) (map[string]interface{}, error)
+
+	mux      sync.Mutex
+	pending  *batch
+	inFlight int
+}
+
+func newBatcher(window time.Duration, maxSize int, fetch func(ctx context.Context, ids []string) (map[string]interface{}, error)) *batcher {
+	return &batcher{
+		window:  window,
+		maxSize: maxSize,
+		fetch:   fetch,
+	}
+}
+
+// get returns the resource with the given ID, or nil if it was not found. Unless no lookup is
+// in flight, the lookup is added to the pending batch, which is made once the lookups in flight
+// are done, the window is over or the batch is full.
+func (b *batcher) get(ctx context.Context, id string) (interface{}, error) {
+	b.mux.Lock()
+	bt := b.pending
+	flush := false
+	if bt == nil {
+		bt = &batch{ctx: ctx, done: make(chan struct{})}
+		b.pending = bt
+		// Without lookups in flight, there is nothing to wait for
+		if b.inFlight == 0 {
+			flush = true
+		} else {
+			time.AfterFunc(b.window, func() { b.flush(bt) })
+		}
+	}
+	if !containsString(bt.ids, id) {
+		bt.ids = append(bt.ids, id)
+	}
+	bt.waiters++
+	if len(bt.ids) >= b.maxSize {
+		b.pending = nil
+		flush = true
+	}
+	b.mux.Unlock()
+
+	if flush {
+		go b.flush(bt)
+	}
+
+	select {
+	case <-bt.done:
+		if bt.err != nil {
+			return nil, bt.err
+		}
+		return bt.results[id], nil
+	case <-ctx.Done():
+		// The request is canceled once no caller is waiting for it anymore
+		b.mux.Lock()
+		bt.waiters--
+		if bt.waiters == 0 && bt.cancel != nil {
+			bt.cancel()
+		}
+		b.mux.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// flush makes the request of the batch, unless it was already made. The pending batch is made
+// once no lookup is in flight anymore.
+func (b *batcher) flush(bt *batch) {
+	b.mux.Lock()
+	if bt.flushed {
+		b.mux.Unlock()
+		return
+	}
+	bt.flushed = true
+	if b.pending == bt {
+		b.pending = nil
+	}
+	if bt.waiters == 0 {
+		b.mux.Unlock()
+		bt.err = context.Canceled
+		close(bt.done)
+		return
+	}
+	// The request outlives the cancellation of the caller that started the batch, as other
+	// callers are waiting for it, and is canceled once none of them is anymore
+	ctx, cancel := context.WithCancel(detachedContext{bt.ctx})
+	bt.cancel = cancel
+	ids := bt.ids
+	b.inFlight++
+	b.mux.Unlock()
+
+	logging.FromContext(ctx).V(5).Infof("Looking up %d IDs in a single request", len(ids))
+	bt.results, bt.err = b.fetch(ctx, ids)
+	cancel()
+	close(bt.done)
+
+	b.mux.Lock()
+	b.inFlight--
+	next := b.pending
+	if b.inFlight > 0 {
+		next = nil
+	}
+	b.mux.Unlock()
+	if next != nil {
+		b.flush(next)
+	}
+}
+
+// detachedContext carries the values of its parent, such as the logging fields, without its
+// deadline and cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// batchingEC2 decorates EC2 so that the concurrent DescribeVolumes and DescribeInstances
+// requests for a single ID are made as a single request, using a filter on the IDs. Other
+// requests are passed through.
+type batchingEC2 struct {
+	EC2
+	volumes   *batcher
+	instances *batcher
+}
+
+var _ EC2 = &batchingEC2{}
+
+func newBatchingEC2(svc EC2, window time.Duration) *batchingEC2 {
+	c := &batchingEC2{EC2: svc}
+	c.volumes = newBatcher(window, maxBatchSize, c.fetchVolumes)
+	c.instances = newBatcher(window, maxBatchSize, c.fetchInstances)
+	return c
+}
+
+func (c *batchingEC2) DescribeVolumesWithContext(ctx aws.Context, input *ec2.DescribeVolumesInput, opts ...request.Option) (*ec2.DescribeVolumesOutput, error) {
+	if len(opts) != 0 || len(input.VolumeIds) != 1 || len(input.Filters) != 0 || input.NextToken != nil || input.MaxResults != nil || input.DryRun != nil {
+		return c.EC2.DescribeVolumesWithContext(ctx, input, opts...)
+	}
+
+	volumeID := aws.StringValue(input.VolumeIds[0])
+	volume, err := c.volumes.get(ctx, volumeID)
+	if err != nil {
+		return nil, err
+	}
+	if volume == nil {
+		// This is the error EC2 returns when looking up a volume that doesn't exist by ID
+		return nil, awserr.New("InvalidVolume.NotFound", fmt.Sprintf("The volume '%s' does not exist.", volumeID), nil)
+	}
+	return &ec2.DescribeVolumesOutput{Volumes: []*ec2.Volume{volume.(*ec2.Volume)}}, nil
+}
+
+func (c *batchingEC2) DescribeInstancesWithContext(ctx aws.Context, input *ec2.DescribeInstancesInput, opts ...request.Option) (*ec2.DescribeInstancesOutput, error) {
+	if len(opts) != 0 || len(input.InstanceIds) != 1 || len(input.Filters) != 0 || input.NextToken != nil || input.MaxResults != nil || input.DryRun != nil {
+		return c.EC2.DescribeInstancesWithContext(ctx, input, opts...)
+	}
+
+	instanceID := aws.StringValue(input.InstanceIds[0])
+	reservation, err := c.instances.get(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	if reservation == nil {
+		// This is the error EC2 returns when looking up an instance that doesn't exist by ID
+		return nil, awserr.New("InvalidInstanceID.NotFound", fmt.Sprintf("The instance ID '%s' does not exist", instanceID), nil)
+	}
+	return &ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{reservation.(*ec2.Reservation)}}, nil
+}
+
+// fetchVolumes returns the volumes with the given IDs. Unlike looking them up by ID, filtering
+// on the IDs doesn't fail the whole request when one of the volumes doesn't exist.
+func (c *batchingEC2) fetchVolumes(ctx context.Context, volumeIDs []string) (map[string]interface{}, error) {
+	request := &ec2.DescribeVolumesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("volume-id"),
+				Values: aws.StringSlice(volumeIDs),
+			},
+		},
+	}
+
+	volumes := make(map[string]interface{})
+	for {
+		response, err := c.EC2.DescribeVolumesWithContext(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		for _, volume := range response.Volumes {
+			volumes[aws.StringValue(volume.VolumeId)] = volume
+		}
+		if aws.StringValue(response.NextToken) == "" {
+			return volumes, nil
+		}
+		request.NextToken = response.NextToken
+	}
+}
+
+// fetchInstances returns the instances with the given IDs, each in a reservation of its own.
+func (c *batchingEC2) fetchInstances(ctx context.Context, instanceIDs []string) (map[string]interface{}, error) {
+	request := &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("instance-id"),
+				Values: aws.StringSlice(instanceIDs),
+			},
+		},
+	}
+
+	reservations := make(map[string]interface{})
+	for {
+		response, err := c.EC2.DescribeInstancesWithContext(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		for _, reservation := range response.Reservations {
+			for _, instance := range reservation.Instances {
+				r := *reservation
+				r.Instances = []*ec2.Instance{instance}
+				reservations[aws.StringValue(instance.InstanceId)] = &r
+			}
+		}
+		if aws.StringValue(response.NextToken) == "" {
+			return reservations, nil
+		}
+		request.NextToken = response.NextToken
+	}
+}
@@ -0,0 +1,480 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/golang/mock/gomock"
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/cloud/mocks"
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/logging"
+)
+
+func TestBatchingEC2DescribeVolumes(t *testing.T) {
+	testCases := []struct {
+		name      string
+		volumeIDs []string
+		mockVols  []string
+		mockErr   error
+		expErrs   map[string]string
+	}{
+		{
+			name:      "success: volumes are looked up",
+			volumeIDs: []string{"vol-1", "vol-2", "vol-1", "vol-3"},
+			mockVols:  []string{"vol-1", "vol-2", "vol-3"},
+		},
+		{
+			name:      "fail: volume not found",
+			volumeIDs: []string{"vol-1", "vol-2"},
+			mockVols:  []string{"vol-1"},
+			expErrs: map[string]string{
+				"vol-2": "InvalidVolume.NotFound: The volume 'vol-2' does not exist.",
+			},
+		},
+		{
+			name:      "fail: the error of the request is returned to all callers",
+			volumeIDs: []string{"vol-1", "vol-2"},
+			mockErr:   fmt.Errorf("DescribeVolumes generic error"),
+			expErrs: map[string]string{
+				"vol-1": "DescribeVolumes generic error",
+				"vol-2": "DescribeVolumes generic error",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		mockCtrl := gomock.NewController(t)
+		mockEC2 := mocks.NewMockEC2(mockCtrl)
+		c := newBatchingEC2(mockEC2, 100*time.Millisecond)
+
+		var volumes []*ec2.Volume
+		for _, volumeID := range tc.mockVols {
+			volumes = append(volumes, &ec2.Volume{VolumeId: aws.String(volumeID)})
+		}
+		// The first lookup is made right away, and the others while it's in flight are batched
+		mockEC2.EXPECT().DescribeVolumesWithContext(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx aws.Context, input *ec2.DescribeVolumesInput, opts ...request.Option) (*ec2.DescribeVolumesOutput, error) {
+				ids := aws.StringValueSlice(input.Filters[0].Values)
+				for _, id := range ids {
+					if !containsString(tc.volumeIDs, id) {
+						t.Errorf("Expected volumes in %v to be looked up, got %v", tc.volumeIDs, ids)
+					}
+				}
+				if tc.mockErr != nil {
+					return nil, tc.mockErr
+				}
+				var found []*ec2.Volume
+				for _, volume := range volumes {
+					if containsString(ids, aws.StringValue(volume.VolumeId)) {
+						found = append(found, volume)
+					}
+				}
+				return &ec2.DescribeVolumesOutput{Volumes: found}, nil
+			}).MinTimes(1).MaxTimes(len(tc.volumeIDs))
+
+		var wg sync.WaitGroup
+		for _, volumeID := range tc.volumeIDs {
+			wg.Add(1)
+			go func(volumeID string) {
+				defer wg.Done()
+				input := &ec2.DescribeVolumesInput{VolumeIds: []*string{aws.String(volumeID)}}
+				output, err := c.DescribeVolumesWithContext(context.Background(), input)
+				if expErr, ok := tc.expErrs[volumeID]; ok {
+					if err == nil || err.Error() != expErr {
+						t.Errorf("DescribeVolumesWithContext(%q) failed: expected error %q, got: %v", volumeID, expErr, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Errorf("DescribeVolumesWithContext(%q) failed: expected no error, got: %v", volumeID, err)
+					return
+				}
+				if len(output.Volumes) != 1 || aws.StringValue(output.Volumes[0].VolumeId) != volumeID {
+					t.Errorf("DescribeVolumesWithContext(%q) failed: expected only the volume, got: %v", volumeID, output.Volumes)
+				}
+			}(volumeID)
+		}
+		wg.Wait()
+
+		mockCtrl.Finish()
+	}
+}
+
+func TestBatchingEC2DescribeInstances(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockEC2 := mocks.NewMockEC2(mockCtrl)
+	c := newBatchingEC2(mockEC2, 100*time.Millisecond)
+
+	reservation := &ec2.Reservation{
+		ReservationId: aws.String("r-1"),
+		Instances: []*ec2.Instance{
+			{InstanceId: aws.String("i-1")},
+			{InstanceId: aws.String("i-2")},
+		},
+	}
+	mockEC2.EXPECT().DescribeInstancesWithContext(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx aws.Context, input *ec2.DescribeInstancesInput, opts ...request.Option) (*ec2.DescribeInstancesOutput, error) {
+			ids := aws.StringValueSlice(input.Filters[0].Values)
+			r := *reservation
+			r.Instances = nil
+			for _, instance := range reservation.Instances {
+				if containsString(ids, aws.StringValue(instance.InstanceId)) {
+					r.Instances = append(r.Instances, instance)
+				}
+			}
+			return &ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{&r}}, nil
+		}).MinTimes(1).MaxTimes(3)
+
+	instanceIDs := []string{"i-1", "i-2", "i-3"}
+	errs := make([]error, len(instanceIDs))
+	outputs := make([]*ec2.DescribeInstancesOutput, len(instanceIDs))
+	var wg sync.WaitGroup
+	for i, instanceID := range instanceIDs {
+		wg.Add(1)
+		go func(i int, instanceID string) {
+			defer wg.Done()
+			input := &ec2.DescribeInstancesInput{InstanceIds: []*string{aws.String(instanceID)}}
+			outputs[i], errs[i] = c.DescribeInstancesWithContext(context.Background(), input)
+		}(i, instanceID)
+	}
+	wg.Wait()
+
+	for i, instanceID := range instanceIDs[:2] {
+		if errs[i] != nil {
+			t.Fatalf("DescribeInstancesWithContext(%q) failed: expected no error, got: %v", instanceID, errs[i])
+		}
+		reservations := outputs[i].Reservations
+		if len(reservations) != 1 || len(reservations[0].Instances) != 1 || aws.StringValue(reservations[0].Instances[0].InstanceId) != instanceID {
+			t.Fatalf("DescribeInstancesWithContext(%q) failed: expected only the instance, got: %v", instanceID, reservations)
+		}
+		if aws.StringValue(reservations[0].ReservationId) != "r-1" {
+			t.Fatalf("DescribeInstancesWithContext(%q) failed: expected the reservation of the instance, got: %v", instanceID, reservations[0])
+		}
+	}
+	if awsErr, ok := errs[2].(awserr.Error); !ok || awsErr.Code() != "InvalidInstanceID.NotFound" {
+		t.Fatalf("DescribeInstancesWithContext(%q) failed: expected not found error, got: %v", instanceIDs[2], errs[2])
+	}
+}
+
+func TestBatchingEC2PassThrough(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockEC2 := mocks.NewMockEC2(mockCtrl)
+	c := newBatchingEC2(mockEC2, time.Hour)
+	ctx := context.Background()
+
+	volumesInputs := []*ec2.DescribeVolumesInput{
+		{},
+		{VolumeIds: aws.StringSlice([]string{"vol-1", "vol-2"})},
+		{Filters: []*ec2.Filter{{Name: aws.String("tag-key"), Values: aws.StringSlice([]string{VolumeNameTagKey})}}},
+		{VolumeIds: aws.StringSlice([]string{"vol-1"}), NextToken: aws.String("token")},
+	}
+	for _, input := range volumesInputs {
+		mockEC2.EXPECT().DescribeVolumesWithContext(gomock.Eq(ctx), gomock.Eq(input)).Return(&ec2.DescribeVolumesOutput{}, nil)
+		if _, err := c.DescribeVolumesWithContext(ctx, input); err != nil {
+			t.Fatalf("DescribeVolumesWithContext(%v) failed: expected no error, got: %v", input, err)
+		}
+	}
+
+	instancesInput := &ec2.DescribeInstancesInput{InstanceIds: aws.StringSlice([]string{"i-1"}), MaxResults: aws.Int64(5)}
+	mockEC2.EXPECT().DescribeInstancesWithContext(gomock.Eq(ctx), gomock.Eq(instancesInput)).Return(&ec2.DescribeInstancesOutput{}, nil)
+	if _, err := c.DescribeInstancesWithContext(ctx, instancesInput); err != nil {
+		t.Fatalf("DescribeInstancesWithContext(%v) failed: expected no error, got: %v", instancesInput, err)
+	}
+}
+
+// blockingFetcher records the lookups, and blocks the lookup of blockedID until release is
+// closed. Each ID is found, with itself as the result.
+type blockingFetcher struct {
+	blockedID string
+	started   chan struct{}
+	release   chan struct{}
+
+	mux     sync.Mutex
+	fetched [][]string
+}
+
+func newBlockingFetcher(blockedID string) *blockingFetcher {
+	return &blockingFetcher{
+		blockedID: blockedID,
+		started:   make(chan struct{}),
+		release:   make(chan struct{}),
+	}
+}
+
+func (f *blockingFetcher) fetch(ctx context.Context, ids []string) (map[string]interface{}, error) {
+	f.mux.Lock()
+	f.fetched = append(f.fetched, uniqueSortedStrings(ids))
+	f.mux.Unlock()
+
+	if containsString(ids, f.blockedID) {
+		close(f.started)
+		<-f.release
+	}
+	results := make(map[string]interface{})
+	for _, id := range ids {
+		results[id] = id
+	}
+	return results, nil
+}
+
+func (f *blockingFetcher) requests() [][]string {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return append([][]string(nil), f.fetched...)
+}
+
+// startBlockedLookup looks up the blocked ID, and returns once its request is in flight.
+func startBlockedLookup(t *testing.T, b *batcher, f *blockingFetcher, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := b.get(context.Background(), f.blockedID); err != nil {
+			t.Errorf("get(%q) failed: expected no error, got: %v", f.blockedID, err)
+		}
+	}()
+	<-f.started
+}
+
+// waitForWaiters waits until n callers wait for the pending batch.
+func waitForWaiters(t *testing.T, b *batcher, n int) {
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(time.Millisecond) {
+		b.mux.Lock()
+		waiters := 0
+		if b.pending != nil {
+			waiters = b.pending.waiters
+		}
+		b.mux.Unlock()
+		if waiters == n {
+			return
+		}
+	}
+	t.Fatalf("Timed out waiting for %d callers to wait for the pending batch", n)
+}
+
+func TestBatcherSingleLookup(t *testing.T) {
+	f := newBlockingFetcher("")
+	b := newBatcher(time.Hour, maxBatchSize, f.fetch)
+
+	// Without lookups in flight, the lookup is made without waiting for the window to be over
+	result, err := b.get(context.Background(), "id-1")
+	if err != nil {
+		t.Fatalf("get() failed: expected no error, got: %v", err)
+	}
+	if result != "id-1" {
+		t.Fatalf("get() failed: expected result id-1, got: %v", result)
+	}
+	if requests := f.requests(); fmt.Sprint(requests) != "[[id-1]]" {
+		t.Fatalf("Expected a single request for id-1, got %v", requests)
+	}
+}
+
+func TestBatcherInFlight(t *testing.T) {
+	f := newBlockingFetcher("id-0")
+	b := newBatcher(time.Hour, maxBatchSize, f.fetch)
+	var wg sync.WaitGroup
+	startBlockedLookup(t, b, f, &wg)
+
+	// The lookups made while another one is in flight are made in a single request once it's done
+	ids := []string{"id-1", "id-2", "id-1"}
+	results := make([]interface{}, len(ids))
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			result, err := b.get(context.Background(), id)
+			if err != nil {
+				t.Errorf("get(%q) failed: expected no error, got: %v", id, err)
+			}
+			results[i] = result
+		}(i, id)
+	}
+	waitForWaiters(t, b, len(ids))
+	close(f.release)
+	wg.Wait()
+
+	if requests := f.requests(); fmt.Sprint(requests) != "[[id-0] [id-1 id-2]]" {
+		t.Fatalf("Expected requests [[id-0] [id-1 id-2]], got %v", requests)
+	}
+	for i, id := range ids {
+		if results[i] != id {
+			t.Fatalf("get(%q) failed: expected result %v, got %v", id, id, results[i])
+		}
+	}
+}
+
+func TestBatcherMaxSize(t *testing.T) {
+	f := newBlockingFetcher("id-0")
+	b := newBatcher(time.Hour, 2, f.fetch)
+	var wg sync.WaitGroup
+	startBlockedLookup(t, b, f, &wg)
+	defer close(f.release)
+
+	// The batch is made as soon as it's full, without waiting for the lookups in flight
+	for _, id := range []string{"id-1", "id-2"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			if _, err := b.get(context.Background(), id); err != nil {
+				t.Errorf("get(%q) failed: expected no error, got: %v", id, err)
+			}
+		}(id)
+	}
+	for start := time.Now(); len(f.requests()) < 2; time.Sleep(time.Millisecond) {
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("Timed out waiting for the full batch to be made")
+		}
+	}
+
+	if requests := f.requests(); fmt.Sprint(requests) != "[[id-0] [id-1 id-2]]" {
+		t.Fatalf("Expected requests [[id-0] [id-1 id-2]], got %v", requests)
+	}
+}
+
+func TestBatcherCanceled(t *testing.T) {
+	f := newBlockingFetcher("id-0")
+	b := newBatcher(time.Hour, maxBatchSize, f.fetch)
+	var wg sync.WaitGroup
+	startBlockedLookup(t, b, f, &wg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := b.get(ctx, "id-1"); err != context.Canceled {
+		t.Fatalf("get() failed: expected %v, got: %v", context.Canceled, err)
+	}
+
+	// No request is made once no caller is waiting for it anymore
+	close(f.release)
+	wg.Wait()
+	if requests := f.requests(); fmt.Sprint(requests) != "[[id-0]]" {
+		t.Fatalf("Expected no request to be made for the canceled batch, got %v", requests)
+	}
+}
+
+func TestBatcherContext(t *testing.T) {
+	f := newBlockingFetcher("id-0")
+	b := newBatcher(time.Hour, maxBatchSize, f.fetch)
+	var wg sync.WaitGroup
+	startBlockedLookup(t, b, f, &wg)
+
+	var fetchCtx context.Context
+	b.fetch = func(ctx context.Context, ids []string) (map[string]interface{}, error) {
+		fetchCtx = ctx
+		return f.fetch(ctx, ids)
+	}
+
+	// The request carries the values of the caller that started the batch, and is not canceled
+	// with it while other callers are waiting
+	ctx, cancel := context.WithCancel(logging.WithFields(context.Background(), logging.VolumeIDKey, "id-1"))
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if _, err := b.get(ctx, "id-1"); err != context.Canceled {
+			t.Errorf("get(%q) failed: expected %v, got: %v", "id-1", context.Canceled, err)
+		}
+	}()
+	waitForWaiters(t, b, 1)
+	go func() {
+		defer wg.Done()
+		if _, err := b.get(context.Background(), "id-2"); err != nil {
+			t.Errorf("get(%q) failed: expected no error, got: %v", "id-2", err)
+		}
+	}()
+	waitForWaiters(t, b, 2)
+	cancel()
+	waitForWaiters(t, b, 1)
+	close(f.release)
+	wg.Wait()
+
+	if fetchCtx == nil {
+		t.Fatal("Expected a request to be made for the batch")
+	}
+	if volumeID := logging.Field(fetchCtx, logging.VolumeIDKey); volumeID != "id-1" {
+		t.Fatalf("Expected the request to carry the fields of the first caller, got volume ID %q", volumeID)
+	}
+	if err := fetchCtx.Err(); err != context.Canceled {
+		t.Fatalf("Expected the request context to be canceled once done, got: %v", err)
+	}
+}
+
+// BenchmarkBatcherSingleLookups measures lookups made one at a time, which don't wait for the
+// window to be over.
+func BenchmarkBatcherSingleLookups(b *testing.B) {
+	f := newBlockingFetcher("")
+	bt := newBatcher(DefaultAPIBatchWindow, maxBatchSize, f.fetch)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := bt.get(ctx, fmt.Sprintf("id-%d", i)); err != nil {
+			b.Fatalf("get() failed: %v", err)
+		}
+	}
+	b.ReportMetric(float64(len(f.requests()))/float64(b.N), "requests/op")
+}
+
+// BenchmarkBatcherConcurrentLookups measures concurrent lookups of a slow EC2 API, which are
+// batched while other lookups are in flight.
+func BenchmarkBatcherConcurrentLookups(b *testing.B) {
+	var mux sync.Mutex
+	requests := 0
+	bt := newBatcher(DefaultAPIBatchWindow, maxBatchSize, func(ctx context.Context, ids []string) (map[string]interface{}, error) {
+		mux.Lock()
+		requests++
+		mux.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		return nil, nil
+	})
+	ctx := context.Background()
+
+	var id int64
+	b.SetParallelism(50)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := bt.get(ctx, fmt.Sprintf("id-%d", atomic.AddInt64(&id, 1))); err != nil {
+				b.Errorf("get() failed: %v", err)
+			}
+		}
+	})
+	b.ReportMetric(float64(requests)/float64(b.N), "requests/op")
+}
+
+func uniqueSortedStrings(values []string) []string {
+	set := make(map[string]struct{})
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+	var unique []string
+	for v := range set {
+		unique = append(unique, v)
+	}
+	sort.Strings(unique)
+	return unique
+}
@@ -18,20 +18,28 @@ package cloud
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
-	"github.com/golang/glog"
 	dm "github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/cloud/devicemanager"
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/logging"
 	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/util"
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 const (
@@ -42,6 +50,9 @@ const (
 	// VolumeNameTagKey is the key value that refers to the volume's name.
 	VolumeNameTagKey = "com.amazon.aws.csi.volume"
 
+	// SnapshotNameTagKey is the key value that refers to the snapshot's name.
+	SnapshotNameTagKey = "com.amazon.aws.csi.snapshot"
+
 	// VolumeTypeIO1 represents a provisioned IOPS SSD type of volume.
 	VolumeTypeIO1 = "io1"
 
@@ -54,11 +65,11 @@ const (
 	// VolumeTypeST1 represents a throughput-optimized HDD type of volume.
 	VolumeTypeST1 = "st1"
 
-	// MinTotalIOPS represents the minimum Input Output per second.
-	MinTotalIOPS int64 = 100
+	// VolumeTypeGP3 represents a general purpose SSD type of volume with provisioned IOPS and throughput.
+	VolumeTypeGP3 = "gp3"
 
-	// MaxTotalIOPS represents the maximum Input Output per second.
-	MaxTotalIOPS int64 = 20000
+	// VolumeTypeIO2 represents a durable provisioned IOPS SSD type of volume.
+	VolumeTypeIO2 = "io2"
 
 	// DefaultVolumeType specifies which storage to use for newly created Volumes.
 	DefaultVolumeType = VolumeTypeGP2
@@ -78,22 +89,128 @@ var (
 
 	// ErrAlreadyExists is returned when a resource is already existent.
 	ErrAlreadyExists = errors.New("Resource already exists")
+
+	// ErrInvalidKMSKey is returned when the KMS key used to encrypt a volume
+	// doesn't exist or can't be used by the driver.
+	ErrInvalidKMSKey = errors.New("KMS key is invalid or not accessible")
+
+	// ErrMultiSnapshots is returned when multiple snapshots are found
+	// with the same snapshot name.
+	ErrMultiSnapshots = errors.New("Multiple snapshots with the same name found")
+
+	// ErrInvalidNextToken is returned when a pagination token is not valid or is expired.
+	ErrInvalidNextToken = errors.New("Invalid pagination token")
+
+	// ErrAttachmentTimeout is returned when a volume attachment doesn't reach
+	// the expected state in time. The operation may still succeed if retried.
+	ErrAttachmentTimeout = errors.New("Timed out waiting for volume attachment state")
+
+	// ErrModificationTimeout is returned when a volume modification doesn't reach
+	// the optimizing or completed state in time.
+	ErrModificationTimeout = errors.New("Timed out waiting for volume modification")
+
+	// ErrIdempotentParameterMismatch is returned when a volume with the same name was
+	// already created with different parameters.
+	ErrIdempotentParameterMismatch = errors.New("Volume was already created with different parameters")
+
+	// ErrCreationTimeout is returned when a new volume doesn't become available in time.
+	// The operation may still succeed if retried.
+	ErrCreationTimeout = errors.New("Timed out waiting for volume to become available")
+
+	// ErrInvalidDiskOptions is returned, wrapped with the details, when the options of a new
+	// volume are not valid for its type.
+	ErrInvalidDiskOptions = errors.New("Invalid disk options")
+
+	// ErrCapacityOutOfRange is returned, wrapped with the details, when the size of a new
+	// volume is out of the range of its type.
+	ErrCapacityOutOfRange = errors.New("Capacity is out of range")
 )
 
+// availabilityZonesCacheTTL is how long the available zones of the region are cached.
+const availabilityZonesCacheTTL = 1 * time.Hour
+
+// volumeCreationBackoff is the backoff used to poll the state of a new volume
+// until it's available. It gives up after roughly two minutes.
+var volumeCreationBackoff = wait.Backoff{
+	Duration: 1 * time.Second,
+	Factor:   1.8,
+	Steps:    8,
+}
+
+// attachmentStateBackoff is the backoff used to poll the state of a volume
+// attachment. It gives up after roughly two minutes.
+var attachmentStateBackoff = wait.Backoff{
+	Duration: 1 * time.Second,
+	Factor:   1.8,
+	Steps:    8,
+}
+
+// volumeModificationBackoff is the backoff used to poll the state of a volume
+// modification. It gives up after roughly four minutes.
+var volumeModificationBackoff = wait.Backoff{
+	Duration: 1 * time.Second,
+	Factor:   1.8,
+	Steps:    9,
+}
+
 // Disk represents a EBS volume
 type Disk struct {
 	VolumeID         string
 	CapacityGiB      int64
 	AvailabilityZone string
+	SnapshotID       string
+	Encrypted        bool
+	KmsKeyID         string
 }
 
 // DiskOptions represents parameters to create an EBS volume
 type DiskOptions struct {
-	CapacityBytes    int64
-	Tags             map[string]string
-	VolumeType       string
-	IOPSPerGB        int64
+	CapacityBytes int64
+	Tags          map[string]string
+	VolumeType    string
+	// BlockExpress selects the limits of io2 Block Express volumes for io2 volumes.
+	BlockExpress bool
+	// IOPS and IOPSPerGB are mutually exclusive ways of provisioning the IOPS of the volume.
+	IOPS      int64
+	IOPSPerGB int64
+	// AllowIOPSPerGBIncrease increases the IOPS to the minimum of the volume type instead of
+	// failing when too few IOPS are requested.
+	AllowIOPSPerGBIncrease bool
+	// Throughput is the provisioned throughput of the volume in MiB/s.
+	Throughput       int64
 	AvailabilityZone string
+	SnapshotID       string
+	Encrypted        bool
+	// KmsKeyID is the customer managed key used to encrypt the volume.
+	// The AWS managed key is used when it's empty.
+	KmsKeyID string
+}
+
+// ListDisksResponse represents a page of EBS volumes
+type ListDisksResponse struct {
+	Disks     []*Disk
+	NextToken string
+}
+
+// Snapshot represents an EBS volume snapshot
+type Snapshot struct {
+	SnapshotID     string
+	SourceVolumeID string
+	SizeGiB        int64
+	CreationTime   time.Time
+	ReadyToUse     bool
+	Failed         bool
+}
+
+// SnapshotOptions represents parameters to create an EBS volume snapshot
+type SnapshotOptions struct {
+	Tags map[string]string
+}
+
+// ListSnapshotsResponse represents a page of EBS volume snapshots
+type ListSnapshotsResponse struct {
+	Snapshots []*Snapshot
+	NextToken string
 }
 
 // EC2 abstracts aws.EC2 to facilitate its mocking.
@@ -105,6 +222,12 @@ type EC2 interface {
 	DetachVolumeWithContext(ctx aws.Context, input *ec2.DetachVolumeInput, opts ...request.Option) (*ec2.VolumeAttachment, error)
 	AttachVolumeWithContext(ctx aws.Context, input *ec2.AttachVolumeInput, opts ...request.Option) (*ec2.VolumeAttachment, error)
 	DescribeInstancesWithContext(ctx aws.Context, input *ec2.DescribeInstancesInput, opts ...request.Option) (*ec2.DescribeInstancesOutput, error)
+	CreateSnapshotWithContext(ctx aws.Context, input *ec2.CreateSnapshotInput, opts ...request.Option) (*ec2.Snapshot, error)
+	DeleteSnapshotWithContext(ctx aws.Context, input *ec2.DeleteSnapshotInput, opts ...request.Option) (*ec2.DeleteSnapshotOutput, error)
+	DescribeSnapshotsWithContext(ctx aws.Context, input *ec2.DescribeSnapshotsInput, opts ...request.Option) (*ec2.DescribeSnapshotsOutput, error)
+	ModifyVolumeWithContext(ctx aws.Context, input *ec2.ModifyVolumeInput, opts ...request.Option) (*ec2.ModifyVolumeOutput, error)
+	DescribeVolumesModificationsWithContext(ctx aws.Context, input *ec2.DescribeVolumesModificationsInput, opts ...request.Option) (*ec2.DescribeVolumesModificationsOutput, error)
+	DescribeAvailabilityZonesWithContext(ctx aws.Context, input *ec2.DescribeAvailabilityZonesInput, opts ...request.Option) (*ec2.DescribeAvailabilityZonesOutput, error)
 }
 
 type Cloud interface {
@@ -115,18 +238,86 @@ type Cloud interface {
 	DetachDisk(ctx context.Context, volumeID string, nodeID string) (err error)
 	GetDiskByName(ctx context.Context, name string, capacityBytes int64) (disk *Disk, err error)
 	GetDiskByID(ctx context.Context, volumeID string) (disk *Disk, err error)
-	IsExistInstance(ctx context.Context, nodeID string) (sucess bool)
+	ListDisks(ctx context.Context, maxResults int64, nextToken string) (listDisksResponse *ListDisksResponse, err error)
+	IsExistInstance(ctx context.Context, nodeID string) (exists bool, err error)
+	CreateSnapshot(ctx context.Context, volumeID string, snapshotOptions *SnapshotOptions) (snapshot *Snapshot, err error)
+	DeleteSnapshot(ctx context.Context, snapshotID string) (success bool, err error)
+	GetSnapshotByName(ctx context.Context, name string) (snapshot *Snapshot, err error)
+	GetSnapshotByID(ctx context.Context, snapshotID string) (snapshot *Snapshot, err error)
+	ListSnapshots(ctx context.Context, volumeID string, maxResults int64, nextToken string) (listSnapshotsResponse *ListSnapshotsResponse, err error)
+	ResizeDisk(ctx context.Context, volumeID string, newSizeBytes int64) (newSizeGiB int64, err error)
+	GetAvailabilityZones(ctx context.Context) (zones []string, err error)
 }
 
 type cloud struct {
 	metadata MetadataService
 	ec2      EC2
 	dm       dm.DeviceManager
+
+	// failedCreations counts, per volume name, the volumes deleted because they ended up
+	// in the error state. EC2 returns the same volume for the same ClientToken, so retries
+	// need a new token to create a new volume. The counts are only kept in memory: after a
+	// restart, retries get the deleted volume back and time out, until the volume is
+	// requested under another name, such as by recreating its claim.
+	failedCreationsMux sync.Mutex
+	failedCreations    map[string]int
+
+	// zones caches the available zones of the region until zonesExpiry.
+	zonesMux    sync.Mutex
+	zones       []string
+	zonesExpiry time.Time
 }
 
 var _ Cloud = &cloud{}
 
-func NewCloud() (Cloud, error) {
+// cloudOptions holds the optional settings of the cloud provider.
+type cloudOptions struct {
+	apiQPS         float64
+	apiBurst       int
+	apiMaxRetries  int
+	apiBatchWindow time.Duration
+}
+
+// CloudOption configures optional behavior of the cloud provider.
+type CloudOption func(*cloudOptions)
+
+// WithAPIRateLimit limits the rate of EC2 requests to qps requests per second, with bursts of
+// up to burst requests. Requests are not rate limited when qps is not positive.
+func WithAPIRateLimit(qps float64, burst int) CloudOption {
+	return func(o *cloudOptions) {
+		o.apiQPS = qps
+		o.apiBurst = burst
+	}
+}
+
+// WithAPIMaxRetries sets the number of times failed EC2 requests are retried, including the
+// ones throttled by AWS.
+func WithAPIMaxRetries(maxRetries int) CloudOption {
+	return func(o *cloudOptions) {
+		o.apiMaxRetries = maxRetries
+	}
+}
+
+// WithAPIBatchWindow sets the maximum time during which the lookups of volumes and instances by
+// ID made while another lookup is in flight are collected before being made in a single EC2
+// request. Lookups are not batched when it's not positive.
+func WithAPIBatchWindow(window time.Duration) CloudOption {
+	return func(o *cloudOptions) {
+		o.apiBatchWindow = window
+	}
+}
+
+func NewCloud(options ...CloudOption) (Cloud, error) {
+	opts := &cloudOptions{
+		apiQPS:         DefaultAPIQPS,
+		apiBurst:       DefaultAPIBurst,
+		apiMaxRetries:  DefaultAPIMaxRetries,
+		apiBatchWindow: DefaultAPIBatchWindow,
+	}
+	for _, option := range options {
+		option(opts)
+	}
+
 	sess, err := session.NewSession(&aws.Config{})
 	if err != nil {
 		return nil, fmt.Errorf("unable to initialize AWS session: %v", err)
@@ -134,9 +325,9 @@ func NewCloud() (Cloud, error) {
 
 	svc := ec2metadata.New(sess)
 
-	metadata, err := NewMetadataService(svc)
+	metadata, err := DiscoverMetadataService(svc)
 	if err != nil {
-		return nil, fmt.Errorf("could not get metadata from AWS: %v", err)
+		return nil, fmt.Errorf("could not get metadata: %v", err)
 	}
 
 	provider := []credentials.Provider{
@@ -150,11 +341,21 @@ func NewCloud() (Cloud, error) {
 		Credentials: credentials.NewChainCredentials(provider),
 	}
 	awsConfig = awsConfig.WithCredentialsChainVerboseErrors(true)
+	awsConfig = request.WithRetryer(awsConfig, nonThrottlingRetryer{
+		DefaultRetryer: client.DefaultRetryer{NumMaxRetries: opts.apiMaxRetries},
+	})
+
+	// Batched lookups are rate limited as a single request, and each retry of a request is
+	// observed on its own
+	var ec2Svc EC2 = newRateLimitedEC2(newInstrumentedEC2(ec2.New(session.New(awsConfig))), opts.apiQPS, opts.apiBurst, opts.apiMaxRetries)
+	if opts.apiBatchWindow > 0 {
+		ec2Svc = newBatchingEC2(ec2Svc, opts.apiBatchWindow)
+	}
 
 	return &cloud{
 		metadata: metadata,
 		dm:       dm.NewDeviceManager(),
-		ec2:      ec2.New(session.New(awsConfig)),
+		ec2:      ec2Svc,
 	}, nil
 }
 
@@ -163,28 +364,10 @@ func (c *cloud) GetMetadata() MetadataService {
 }
 
 func (c *cloud) CreateDisk(ctx context.Context, volumeName string, diskOptions *DiskOptions) (*Disk, error) {
-	var (
-		createType string
-		iops       int64
-	)
 	capacityGiB := util.BytesToGiB(diskOptions.CapacityBytes)
-
-	switch diskOptions.VolumeType {
-	case VolumeTypeGP2, VolumeTypeSC1, VolumeTypeST1:
-		createType = diskOptions.VolumeType
-	case VolumeTypeIO1:
-		createType = diskOptions.VolumeType
-		iops = capacityGiB * diskOptions.IOPSPerGB
-		if iops < MinTotalIOPS {
-			iops = MinTotalIOPS
-		}
-		if iops > MaxTotalIOPS {
-			iops = MaxTotalIOPS
-		}
-	case "":
-		createType = DefaultVolumeType
-	default:
-		return nil, fmt.Errorf("invalid AWS VolumeType %q", diskOptions.VolumeType)
+	createType, iops, throughput, err := diskParameters(capacityGiB, diskOptions)
+	if err != nil {
+		return nil, err
 	}
 
 	var tags []*ec2.Tag
@@ -199,7 +382,10 @@ func (c *cloud) CreateDisk(ctx context.Context, volumeName string, diskOptions *
 	zone := diskOptions.AvailabilityZone
 	if zone == "" {
 		zone = c.metadata.GetAvailabilityZone()
-		glog.V(5).Infof("AZ is not provided. Using node AZ [%s]", zone)
+		if zone == "" {
+			return nil, fmt.Errorf("%w: no availability zone is provided and the zone of the driver is unknown", ErrInvalidDiskOptions)
+		}
+		logging.FromContext(ctx).V(5).Infof("AZ is not provided. Using node AZ [%s]", zone)
 	}
 
 	request := &ec2.CreateVolumeInput{
@@ -207,14 +393,34 @@ func (c *cloud) CreateDisk(ctx context.Context, volumeName string, diskOptions *
 		Size:              aws.Int64(capacityGiB),
 		VolumeType:        aws.String(createType),
 		TagSpecifications: []*ec2.TagSpecification{&tagSpec},
+		ClientToken:       aws.String(c.clientToken(volumeName)),
 	}
 	if iops > 0 {
 		request.Iops = aws.Int64(iops)
 	}
+	if throughput > 0 {
+		request.Throughput = aws.Int64(throughput)
+	}
+	if len(diskOptions.SnapshotID) > 0 {
+		request.SnapshotId = aws.String(diskOptions.SnapshotID)
+	}
+	if diskOptions.Encrypted {
+		request.Encrypted = aws.Bool(true)
+		if len(diskOptions.KmsKeyID) > 0 {
+			request.KmsKeyId = aws.String(diskOptions.KmsKeyID)
+		}
+	}
 
 	response, err := c.ec2.CreateVolumeWithContext(ctx, request)
 	if err != nil {
-		return nil, fmt.Errorf("could not create volume in EC2: %v", err)
+		if isAWSErrorInvalidKMSKey(err) {
+			logging.FromContext(ctx).Errorf("CreateVolume failed using KMS key %q: %v", diskOptions.KmsKeyID, err)
+			return nil, ErrInvalidKMSKey
+		}
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "IdempotentParameterMismatch" {
+			return nil, ErrIdempotentParameterMismatch
+		}
+		return nil, fmt.Errorf("could not create volume in EC2: %w", err)
 	}
 
 	volumeID := aws.StringValue(response.VolumeId)
@@ -227,7 +433,37 @@ func (c *cloud) CreateDisk(ctx context.Context, volumeName string, diskOptions *
 		return nil, fmt.Errorf("disk size was not returned by CreateVolume")
 	}
 
-	return &Disk{CapacityGiB: size, VolumeID: volumeID, AvailabilityZone: zone}, nil
+	if err := c.waitForVolumeAvailable(ctx, volumeID); err != nil {
+		if err != errVolumeStateError {
+			return nil, err
+		}
+		c.failedCreationsMux.Lock()
+		if c.failedCreations == nil {
+			c.failedCreations = make(map[string]int)
+		}
+		c.failedCreations[volumeName]++
+		c.failedCreationsMux.Unlock()
+
+		logging.FromContext(ctx).Errorf("Volume %q of %q is in %s state, deleting it", volumeID, volumeName, ec2.VolumeStateError)
+		if _, err := c.DeleteDisk(ctx, volumeID); err != nil && err != ErrNotFound {
+			return nil, fmt.Errorf("volume %q is in %s state and could not be deleted: %v", volumeID, ec2.VolumeStateError, err)
+		}
+		return nil, fmt.Errorf("volume %q is in %s state and was deleted", volumeID, ec2.VolumeStateError)
+	}
+
+	// The volume may have been created by a previous request with the same client token
+	if createdZone := aws.StringValue(response.AvailabilityZone); createdZone != "" {
+		zone = createdZone
+	}
+
+	return &Disk{
+		CapacityGiB:      size,
+		VolumeID:         volumeID,
+		AvailabilityZone: zone,
+		SnapshotID:       diskOptions.SnapshotID,
+		Encrypted:        aws.BoolValue(response.Encrypted),
+		KmsKeyID:         aws.StringValue(response.KmsKeyId),
+	}, nil
 }
 
 func (c *cloud) DeleteDisk(ctx context.Context, volumeID string) (bool, error) {
@@ -238,7 +474,7 @@ func (c *cloud) DeleteDisk(ctx context.Context, volumeID string) (bool, error) {
 				return false, ErrNotFound
 			}
 		}
-		return false, fmt.Errorf("DeleteDisk could not delete volume: %v", err)
+		return false, fmt.Errorf("DeleteDisk could not delete volume: %w", err)
 	}
 	return true, nil
 }
@@ -249,7 +485,7 @@ func (c *cloud) AttachDisk(ctx context.Context, volumeID, nodeID string) (string
 		return "", err
 	}
 
-	device, err := c.dm.NewDevice(instance, volumeID)
+	device, err := c.dm.NewDevice(ctx, instance, volumeID)
 	if err != nil {
 		return "", err
 	}
@@ -269,42 +505,25 @@ func (c *cloud) AttachDisk(ctx context.Context, volumeID, nodeID string) (string
 					return "", ErrAlreadyExists
 				}
 			}
-			return "", fmt.Errorf("could not attach volume %q to node %q: %v", volumeID, nodeID, err)
-		}
-		glog.V(5).Infof("AttachVolume volume=%q instance=%q request returned %v", volumeID, nodeID, resp)
-	}
-
-	// TODO: wait attaching
-	// TODO: this is the only situation where this method returns and the device is not released
-	//attachment, err := disk.waitForAttachmentStatus("attached")
-	//if err != nil {
-	// device.Taint()
-	//if err == wait.ErrWaitTimeout {
-	//c.applyUnSchedulableTaint(nodeName, "Volume stuck in attaching state - node needs reboot to fix impaired state.")
-	//}
-	//return "", err
-	//}
-
-	// Manually release the device so it can be picked again.
-
-	// Double check the attachment to be 100% sure we attached the correct volume at the correct mountpoint
-	// It could happen otherwise that we see the volume attached from a previous/separate AttachVolume call,
-	// which could theoretically be against a different device (or even instance).
-	//if attachment == nil {
-	//// Impossible?
-	//return "", fmt.Errorf("unexpected state: attachment nil after attached %q to %q", diskName, nodeName)
-	//}
-	//if ec2Device != aws.StringValue(attachment.Device) {
-	//return "", fmt.Errorf("disk attachment of %q to %q failed: requested device %q but found %q", diskName, nodeName, ec2Device, aws.StringValue(attachment.Device))
-	//}
-	//if awsInstance.awsID != aws.StringValue(attachment.InstanceId) {
-	//return "", fmt.Errorf("disk attachment of %q to %q failed: requested instance %q but found %q", diskName, nodeName, awsInstance.awsID, aws.StringValue(attachment.InstanceId))
-	//}
-	//return hostDevice, nil
+			return "", fmt.Errorf("could not attach volume %q to node %q: %w", volumeID, nodeID, err)
+		}
+		logging.FromContext(ctx).V(5).Infof("AttachVolume volume=%q instance=%q request returned %v", volumeID, nodeID, resp)
+	}
+
+	// Double check the attachment to be sure we attached the correct volume at the correct device.
+	// Otherwise we could see the volume attached from a previous or separate AttachVolume call,
+	// which could be against a different device or even instance.
+	if err := c.waitForAttachmentState(ctx, volumeID, nodeID, device.Path, ec2.VolumeAttachmentStateAttached); err != nil {
+		// Keep the device name reserved, as it's unknown whether it will end up being used.
+		device.Taint()
+		return "", err
+	}
 
 	return device.Path, nil
 }
 
+// DetachDisk detaches the volume from the node and waits for the detachment to complete.
+// ErrNotFound is returned when the volume doesn't exist or is not attached to the node.
 func (c *cloud) DetachDisk(ctx context.Context, volumeID, nodeID string) error {
 	instance, err := c.getInstance(ctx, nodeID)
 	if err != nil {
@@ -312,14 +531,14 @@ func (c *cloud) DetachDisk(ctx context.Context, volumeID, nodeID string) error {
 	}
 
 	// TODO: check if attached
-	device, err := c.dm.GetDevice(instance, volumeID)
+	device, err := c.dm.GetDevice(ctx, instance, volumeID)
 	if err != nil {
 		return err
 	}
 	defer device.Release(true)
 
 	if !device.IsAlreadyAssigned {
-		glog.Warningf("DetachDisk called on non-attached volume: %s", volumeID)
+		logging.FromContext(ctx).Warningf("DetachDisk called on non-attached volume: %s", volumeID)
 	}
 
 	request := &ec2.DetachVolumeInput{
@@ -329,10 +548,130 @@ func (c *cloud) DetachDisk(ctx context.Context, volumeID, nodeID string) error {
 
 	_, err = c.ec2.DetachVolumeWithContext(ctx, request)
 	if err != nil {
-		return fmt.Errorf("could not detach volume %q from node %q: %v", volumeID, nodeID, err)
+		if isAWSErrorNotAttached(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("could not detach volume %q from node %q: %w", volumeID, nodeID, err)
 	}
 
-	return nil
+	return c.waitForAttachmentState(ctx, volumeID, nodeID, "", ec2.VolumeAttachmentStateDetached)
+}
+
+// errVolumeStateError is returned by waitForVolumeAvailable when the volume is in the error state.
+var errVolumeStateError = errors.New("volume is in error state")
+
+// clientToken returns the ClientToken of the CreateVolume requests of a volume. It's derived from
+// the volume name so that retries return the volume created by the first request, and changes
+// once a volume of that name has been deleted for being in the error state. Such changes are
+// lost when the driver restarts, see failedCreations.
+func (c *cloud) clientToken(volumeName string) string {
+	c.failedCreationsMux.Lock()
+	attempt := c.failedCreations[volumeName]
+	c.failedCreationsMux.Unlock()
+
+	token := volumeName
+	if attempt > 0 {
+		token = fmt.Sprintf("%s-%d", volumeName, attempt)
+	}
+	// ClientToken is limited to 64 ASCII characters, the length of a hex encoded SHA-256 sum
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// waitForVolumeAvailable polls the volume with an exponential backoff until it's available.
+func (c *cloud) waitForVolumeAvailable(ctx context.Context, volumeID string) error {
+	err := wait.ExponentialBackoff(volumeCreationBackoff, func() (bool, error) {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+
+		request := &ec2.DescribeVolumesInput{
+			VolumeIds: []*string{aws.String(volumeID)},
+		}
+		volume, err := c.getVolume(ctx, request)
+		if err != nil {
+			// A new volume may not be visible yet as EC2 is eventually consistent
+			logging.FromContext(ctx).Warningf("Error describing volume %q while waiting for it to be available: %v", volumeID, err)
+			return false, nil
+		}
+
+		state := aws.StringValue(volume.State)
+		logging.FromContext(ctx).V(5).Infof("Volume %q is %s, waiting for %s", volumeID, state, ec2.VolumeStateAvailable)
+		switch state {
+		case ec2.VolumeStateAvailable:
+			return true, nil
+		case ec2.VolumeStateError:
+			return false, errVolumeStateError
+		}
+		return false, nil
+	})
+
+	if err == wait.ErrWaitTimeout || err == context.DeadlineExceeded {
+		return ErrCreationTimeout
+	}
+	return err
+}
+
+// waitForAttachmentState polls the volume with an exponential backoff until its attachment to the
+// node reaches the expected state, which must be either attached or detached. When waiting for the
+// volume to be attached, the attachment must also be to the given device.
+func (c *cloud) waitForAttachmentState(ctx context.Context, volumeID, nodeID, devicePath, expectedState string) error {
+	start := time.Now()
+	defer func() {
+		attachmentWaitDuration.WithLabelValues(expectedState).Observe(time.Since(start).Seconds())
+	}()
+
+	err := wait.ExponentialBackoff(attachmentStateBackoff, func() (bool, error) {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+
+		request := &ec2.DescribeVolumesInput{
+			VolumeIds: []*string{aws.String(volumeID)},
+		}
+		volume, err := c.getVolume(ctx, request)
+		if err != nil {
+			if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "InvalidVolume.NotFound" {
+				err = ErrNotFound
+			}
+			if err == ErrNotFound {
+				// A volume that no longer exists can't be attached anywhere.
+				if expectedState == ec2.VolumeAttachmentStateDetached {
+					return true, nil
+				}
+				return false, err
+			}
+			logging.FromContext(ctx).Warningf("Error describing volume %q while waiting for it to be %s: %v", volumeID, expectedState, err)
+			return false, nil
+		}
+
+		state := ec2.VolumeAttachmentStateDetached
+		for _, attachment := range volume.Attachments {
+			attachmentState := aws.StringValue(attachment.State)
+			instanceID := aws.StringValue(attachment.InstanceId)
+			if instanceID != nodeID {
+				if expectedState == ec2.VolumeAttachmentStateAttached && attachmentState != ec2.VolumeAttachmentStateDetached {
+					return false, fmt.Errorf("volume %q is attached to instance %q instead of %q", volumeID, instanceID, nodeID)
+				}
+				continue
+			}
+			if expectedState == ec2.VolumeAttachmentStateAttached && attachmentState == ec2.VolumeAttachmentStateAttached {
+				if device := aws.StringValue(attachment.Device); device != devicePath {
+					return false, fmt.Errorf("volume %q is attached to instance %q at device %q instead of %q", volumeID, nodeID, device, devicePath)
+				}
+			}
+			state = attachmentState
+		}
+
+		logging.FromContext(ctx).V(5).Infof("Volume %q attachment to instance %q is %s, waiting for %s", volumeID, nodeID, state, expectedState)
+		return state == expectedState, nil
+	})
+
+	if err == wait.ErrWaitTimeout || err == context.DeadlineExceeded {
+		logging.FromContext(ctx).Errorf("Volume %q attachment to instance %q did not become %s in time", volumeID, nodeID, expectedState)
+		return ErrAttachmentTimeout
+	}
+	return err
 }
 
 func (c *cloud) GetDiskByName(ctx context.Context, name string, capacityBytes int64) (*Disk, error) {
@@ -355,10 +694,7 @@ func (c *cloud) GetDiskByName(ctx context.Context, name string, capacityBytes in
 		return nil, ErrDiskExistsDiffSize
 	}
 
-	return &Disk{
-		VolumeID:    aws.StringValue(volume.VolumeId),
-		CapacityGiB: volSizeBytes,
-	}, nil
+	return newDisk(volume), nil
 }
 
 func (c *cloud) GetDiskByID(ctx context.Context, volumeID string) (*Disk, error) {
@@ -373,18 +709,333 @@ func (c *cloud) GetDiskByID(ctx context.Context, volumeID string) (*Disk, error)
 		return nil, err
 	}
 
-	return &Disk{
-		VolumeID:    aws.StringValue(volume.VolumeId),
-		CapacityGiB: aws.Int64Value(volume.Size),
-	}, nil
+	return newDisk(volume), nil
 }
 
-func (c *cloud) IsExistInstance(ctx context.Context, nodeID string) bool {
-	instance, err := c.getInstance(ctx, nodeID)
-	if err != nil || instance == nil {
-		return false
+// ListDisks returns a page of the volumes created by the driver. A maxResults of 0 returns all
+// of them. EC2 pages are requested with at least minPageResults results and truncated to
+// maxResults, in which case the next token resumes within the page.
+func (c *cloud) ListDisks(ctx context.Context, maxResults int64, nextToken string) (*ListDisksResponse, error) {
+	pageToken, offset, err := parseListToken(nextToken)
+	if err != nil {
+		return nil, ErrInvalidNextToken
+	}
+
+	// Only the volumes created by the driver are listed
+	request := &ec2.DescribeVolumesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("tag-key"),
+				Values: []*string{aws.String(VolumeNameTagKey)},
+			},
+		},
+	}
+	if maxResults > 0 {
+		request.MaxResults = aws.Int64(pageResults(maxResults, maxVolumesPageResults))
+	}
+
+	var disks []*Disk
+	for {
+		if len(pageToken) != 0 {
+			request.NextToken = aws.String(pageToken)
+		}
+		response, err := c.ec2.DescribeVolumesWithContext(ctx, request)
+		if err != nil {
+			if awsErr, ok := err.(awserr.Error); ok {
+				if awsErr.Code() == "InvalidPaginationToken" {
+					return nil, ErrInvalidNextToken
+				}
+			}
+			return nil, fmt.Errorf("error listing AWS volumes: %w", err)
+		}
+		start, end, next := pageWindow(len(response.Volumes), offset, maxResults, pageToken, aws.StringValue(response.NextToken))
+		for _, volume := range response.Volumes[start:end] {
+			disks = append(disks, newDisk(volume))
+		}
+
+		// Only the requested page is returned when the caller limits the number of results
+		if maxResults > 0 || next == "" {
+			return &ListDisksResponse{
+				Disks:     disks,
+				NextToken: next,
+			}, nil
+		}
+		pageToken, offset = next, 0
+	}
+}
+
+// IsExistInstance returns whether the instance exists. An error is returned when it can't be
+// determined, such as when the requests are throttled.
+func (c *cloud) IsExistInstance(ctx context.Context, nodeID string) (bool, error) {
+	if _, err := c.getInstance(ctx, nodeID); err != nil {
+		if err == ErrNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// GetAvailabilityZones returns the sorted names of the zones available in the region, in which
+// volumes can be created. They are cached for availabilityZonesCacheTTL. Without the permission
+// to describe them, no zone is returned and no error either, as the zones are unknown.
+func (c *cloud) GetAvailabilityZones(ctx context.Context) ([]string, error) {
+	c.zonesMux.Lock()
+	defer c.zonesMux.Unlock()
+	if time.Now().Before(c.zonesExpiry) {
+		return c.zones, nil
+	}
+
+	request := &ec2.DescribeAvailabilityZonesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("state"),
+				Values: []*string{aws.String(ec2.AvailabilityZoneStateAvailable)},
+			},
+		},
+	}
+	var zones []string
+	response, err := c.ec2.DescribeAvailabilityZonesWithContext(ctx, request)
+	if err != nil {
+		if !isAWSErrorAccessDenied(err) {
+			return nil, fmt.Errorf("could not describe availability zones in EC2: %w", err)
+		}
+		logging.FromContext(ctx).Warningf("Could not describe availability zones in EC2, the requested zones are used as they are: %v", err)
+	} else {
+		for _, zone := range response.AvailabilityZones {
+			if aws.StringValue(zone.State) == ec2.AvailabilityZoneStateAvailable {
+				zones = append(zones, aws.StringValue(zone.ZoneName))
+			}
+		}
+		if len(zones) == 0 {
+			return nil, fmt.Errorf("no availability zone is available in region %q", c.metadata.GetRegion())
+		}
+		sort.Strings(zones)
+	}
+
+	c.zones = zones
+	c.zonesExpiry = time.Now().Add(availabilityZonesCacheTTL)
+	return zones, nil
+}
+
+// ResizeDisk grows the volume to at least newSizeBytes and waits for the modification to be
+// optimizing or completed, at which point the new size can be used. Nothing is done if the
+// volume is already big enough. It returns the size of the volume after the resize.
+func (c *cloud) ResizeDisk(ctx context.Context, volumeID string, newSizeBytes int64) (int64, error) {
+	request := &ec2.DescribeVolumesInput{
+		VolumeIds: []*string{
+			aws.String(volumeID),
+		},
+	}
+	volume, err := c.getVolume(ctx, request)
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "InvalidVolume.NotFound" {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+
+	newSizeGiB := util.BytesToGiB(util.RoundUpBytes(newSizeBytes))
+	oldSizeGiB := aws.Int64Value(volume.Size)
+	if oldSizeGiB >= newSizeGiB {
+		logging.FromContext(ctx).V(5).Infof("Volume %q is already %d GiB, no need to resize it to %d GiB", volumeID, oldSizeGiB, newSizeGiB)
+		return oldSizeGiB, nil
+	}
+
+	modifyRequest := &ec2.ModifyVolumeInput{
+		VolumeId: aws.String(volumeID),
+		Size:     aws.Int64(newSizeGiB),
+	}
+	if _, err := c.ec2.ModifyVolumeWithContext(ctx, modifyRequest); err != nil {
+		// A previous resize may still be in progress, in which case we wait for it instead.
+		if awsErr, ok := err.(awserr.Error); !ok || awsErr.Code() != "IncorrectModificationState" {
+			return 0, fmt.Errorf("could not modify volume %q: %w", volumeID, err)
+		}
+		logging.FromContext(ctx).V(4).Infof("Volume %q is already being modified, waiting for the modification to finish", volumeID)
+	}
+
+	return c.waitForVolumeSize(ctx, volumeID, newSizeGiB)
+}
+
+// waitForVolumeSize polls the modifications of the volume with an exponential backoff until its
+// target size is at least sizeGiB and it's either optimizing or completed.
+func (c *cloud) waitForVolumeSize(ctx context.Context, volumeID string, sizeGiB int64) (int64, error) {
+	var targetSizeGiB int64
+	err := wait.ExponentialBackoff(volumeModificationBackoff, func() (bool, error) {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+
+		request := &ec2.DescribeVolumesModificationsInput{
+			VolumeIds: []*string{aws.String(volumeID)},
+		}
+		response, err := c.ec2.DescribeVolumesModificationsWithContext(ctx, request)
+		if err != nil {
+			logging.FromContext(ctx).Warningf("Error describing modifications of volume %q: %v", volumeID, err)
+			return false, nil
+		}
+
+		for _, modification := range response.VolumesModifications {
+			targetSizeGiB = aws.Int64Value(modification.TargetSize)
+			state := aws.StringValue(modification.ModificationState)
+			if state == ec2.VolumeModificationStateFailed {
+				return false, fmt.Errorf("modification of volume %q failed: %s", volumeID, aws.StringValue(modification.StatusMessage))
+			}
+			if targetSizeGiB < sizeGiB {
+				continue
+			}
+			logging.FromContext(ctx).V(5).Infof("Volume %q modification to %d GiB is %s", volumeID, targetSizeGiB, state)
+			if state == ec2.VolumeModificationStateOptimizing || state == ec2.VolumeModificationStateCompleted {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+
+	if err == wait.ErrWaitTimeout || err == context.DeadlineExceeded {
+		return 0, ErrModificationTimeout
+	}
+	if err != nil {
+		return 0, err
+	}
+	return targetSizeGiB, nil
+}
+
+func (c *cloud) CreateSnapshot(ctx context.Context, volumeID string, snapshotOptions *SnapshotOptions) (*Snapshot, error) {
+	var tags []*ec2.Tag
+	for key, value := range snapshotOptions.Tags {
+		tags = append(tags, &ec2.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	tagSpec := ec2.TagSpecification{
+		ResourceType: aws.String("snapshot"),
+		Tags:         tags,
+	}
+
+	request := &ec2.CreateSnapshotInput{
+		VolumeId:          aws.String(volumeID),
+		Description:       aws.String(fmt.Sprintf("Created by AWS EBS CSI driver for volume %s", volumeID)),
+		TagSpecifications: []*ec2.TagSpecification{&tagSpec},
+	}
+
+	response, err := c.ec2.CreateSnapshotWithContext(ctx, request)
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok {
+			if awsErr.Code() == "InvalidVolume.NotFound" {
+				return nil, ErrNotFound
+			}
+		}
+		return nil, fmt.Errorf("could not create snapshot of volume %q: %w", volumeID, err)
+	}
+
+	if len(aws.StringValue(response.SnapshotId)) == 0 {
+		return nil, fmt.Errorf("snapshot ID was not returned by CreateSnapshot")
+	}
+
+	return newSnapshot(response), nil
+}
+
+func (c *cloud) DeleteSnapshot(ctx context.Context, snapshotID string) (bool, error) {
+	request := &ec2.DeleteSnapshotInput{SnapshotId: aws.String(snapshotID)}
+	if _, err := c.ec2.DeleteSnapshotWithContext(ctx, request); err != nil {
+		if awsErr, ok := err.(awserr.Error); ok {
+			if awsErr.Code() == "InvalidSnapshot.NotFound" {
+				return false, ErrNotFound
+			}
+		}
+		return false, fmt.Errorf("DeleteSnapshot could not delete snapshot: %w", err)
+	}
+	return true, nil
+}
+
+func (c *cloud) GetSnapshotByName(ctx context.Context, name string) (*Snapshot, error) {
+	request := &ec2.DescribeSnapshotsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("tag:" + SnapshotNameTagKey),
+				Values: []*string{aws.String(name)},
+			},
+		},
+	}
+
+	snapshot, err := c.getSnapshot(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSnapshot(snapshot), nil
+}
+
+func (c *cloud) GetSnapshotByID(ctx context.Context, snapshotID string) (*Snapshot, error) {
+	request := &ec2.DescribeSnapshotsInput{
+		SnapshotIds: []*string{
+			aws.String(snapshotID),
+		},
+	}
+
+	snapshot, err := c.getSnapshot(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSnapshot(snapshot), nil
+}
+
+// ListSnapshots returns a page of the snapshots created by the driver, optionally
+// restricted to the ones taken from volumeID. A maxResults of 0 returns all of them. EC2
+// pages are requested with at least minPageResults results and truncated to maxResults, in
+// which case the next token resumes within the page.
+func (c *cloud) ListSnapshots(ctx context.Context, volumeID string, maxResults int64, nextToken string) (*ListSnapshotsResponse, error) {
+	pageToken, offset, err := parseListToken(nextToken)
+	if err != nil {
+		return nil, ErrInvalidNextToken
+	}
+
+	request := &ec2.DescribeSnapshotsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("tag-key"),
+				Values: []*string{aws.String(SnapshotNameTagKey)},
+			},
+		},
+	}
+	if len(volumeID) != 0 {
+		request.Filters = append(request.Filters, &ec2.Filter{
+			Name:   aws.String("volume-id"),
+			Values: []*string{aws.String(volumeID)},
+		})
+	}
+	if maxResults > 0 {
+		request.MaxResults = aws.Int64(pageResults(maxResults, maxSnapshotsPageResults))
+	}
+
+	var snapshots []*Snapshot
+	for {
+		if len(pageToken) != 0 {
+			request.NextToken = aws.String(pageToken)
+		}
+		response, err := c.ec2.DescribeSnapshotsWithContext(ctx, request)
+		if err != nil {
+			if awsErr, ok := err.(awserr.Error); ok {
+				if awsErr.Code() == "InvalidPaginationToken" {
+					return nil, ErrInvalidNextToken
+				}
+			}
+			return nil, fmt.Errorf("error listing AWS snapshots: %w", err)
+		}
+		start, end, next := pageWindow(len(response.Snapshots), offset, maxResults, pageToken, aws.StringValue(response.NextToken))
+		for _, snapshot := range response.Snapshots[start:end] {
+			snapshots = append(snapshots, newSnapshot(snapshot))
+		}
+
+		// Only the requested page is returned when the caller limits the number of results
+		if maxResults > 0 || next == "" {
+			return &ListSnapshotsResponse{
+				Snapshots: snapshots,
+				NextToken: next,
+			}, nil
+		}
+		pageToken, offset = next, 0
 	}
-	return true
 }
 
 func (c *cloud) getVolume(ctx context.Context, request *ec2.DescribeVolumesInput) (*ec2.Volume, error) {
@@ -425,7 +1076,10 @@ func (c *cloud) getInstance(ctx context.Context, nodeID string) (*ec2.Instance,
 	for {
 		response, err := c.ec2.DescribeInstancesWithContext(ctx, request)
 		if err != nil {
-			return nil, fmt.Errorf("error listing AWS instances: %q", err)
+			if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "InvalidInstanceID.NotFound" {
+				return nil, ErrNotFound
+			}
+			return nil, fmt.Errorf("could not describe instance %q: %w", nodeID, err)
 		}
 
 		for _, reservation := range response.Reservations {
@@ -447,3 +1101,97 @@ func (c *cloud) getInstance(ctx context.Context, nodeID string) (*ec2.Instance,
 
 	return instances[0], nil
 }
+
+func (c *cloud) getSnapshot(ctx context.Context, request *ec2.DescribeSnapshotsInput) (*ec2.Snapshot, error) {
+	var snapshots []*ec2.Snapshot
+	var nextToken *string
+
+	for {
+		response, err := c.ec2.DescribeSnapshotsWithContext(ctx, request)
+		if err != nil {
+			if awsErr, ok := err.(awserr.Error); ok {
+				if awsErr.Code() == "InvalidSnapshot.NotFound" {
+					return nil, ErrNotFound
+				}
+			}
+			return nil, err
+		}
+		snapshots = append(snapshots, response.Snapshots...)
+		nextToken = response.NextToken
+		if aws.StringValue(nextToken) == "" {
+			break
+		}
+		request.NextToken = nextToken
+	}
+
+	if l := len(snapshots); l > 1 {
+		return nil, ErrMultiSnapshots
+	} else if l < 1 {
+		return nil, ErrNotFound
+	}
+
+	return snapshots[0], nil
+}
+
+func newDisk(volume *ec2.Volume) *Disk {
+	return &Disk{
+		VolumeID:         aws.StringValue(volume.VolumeId),
+		CapacityGiB:      aws.Int64Value(volume.Size),
+		AvailabilityZone: aws.StringValue(volume.AvailabilityZone),
+		SnapshotID:       aws.StringValue(volume.SnapshotId),
+		Encrypted:        aws.BoolValue(volume.Encrypted),
+		KmsKeyID:         aws.StringValue(volume.KmsKeyId),
+	}
+}
+
+func newSnapshot(snapshot *ec2.Snapshot) *Snapshot {
+	state := aws.StringValue(snapshot.State)
+	return &Snapshot{
+		SnapshotID:     aws.StringValue(snapshot.SnapshotId),
+		SourceVolumeID: aws.StringValue(snapshot.VolumeId),
+		SizeGiB:        aws.Int64Value(snapshot.VolumeSize),
+		CreationTime:   aws.TimeValue(snapshot.StartTime),
+		ReadyToUse:     state == ec2.SnapshotStateCompleted,
+		Failed:         state == ec2.SnapshotStateError,
+	}
+}
+
+// isAWSErrorInvalidKMSKey returns whether err is an EC2 error caused by a KMS key
+// that doesn't exist, is disabled or can't be used with the driver credentials.
+func isAWSErrorInvalidKMSKey(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+	switch code := awsErr.Code(); {
+	case strings.HasPrefix(code, "InvalidKMSKey"), strings.HasPrefix(code, "KMS."), code == "KMSKeyNotAccessible":
+		return true
+	case code == "InvalidParameterValue", code == "InvalidParameterCombination":
+		return strings.Contains(strings.ToLower(awsErr.Message()), "kms")
+	}
+	return false
+}
+
+// isAWSErrorNotAttached returns whether err is an EC2 error returned when detaching a volume
+// that doesn't exist or is not attached to the instance.
+func isAWSErrorNotAttached(err error) bool {
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
+		return false
+	}
+	switch awsErr.Code() {
+	case "IncorrectState", "InvalidVolume.NotFound", "InvalidAttachment.NotFound":
+		return true
+	}
+	return false
+}
+
+// isAWSErrorAccessDenied returns whether err is an EC2 error caused by the driver credentials
+// lacking the permission to make the request.
+func isAWSErrorAccessDenied(err error) bool {
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
+		return false
+	}
+	return awsErr.Code() == "UnauthorizedOperation" || awsErr.Code() == "AccessDenied"
+}
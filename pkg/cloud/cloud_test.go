@@ -18,17 +18,23 @@ package cloud
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/golang/mock/gomock"
 	dm "github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/cloud/devicemanager"
 	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/cloud/mocks"
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/testutil"
 	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/util"
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 func TestCreateDisk(t *testing.T) {
@@ -37,6 +43,9 @@ func TestCreateDisk(t *testing.T) {
 		volumeName  string
 		diskOptions *DiskOptions
 		expDisk     *Disk
+		mockErr     error
+		volStates   []string
+		expDeleted  bool
 		expErr      error
 	}{
 		{
@@ -48,8 +57,9 @@ func TestCreateDisk(t *testing.T) {
 				AvailabilityZone: "",
 			},
 			expDisk: &Disk{
-				VolumeID:    "vol-test",
-				CapacityGiB: 1,
+				VolumeID:         "vol-test",
+				CapacityGiB:      1,
+				AvailabilityZone: "test-az",
 			},
 			expErr: nil,
 		},
@@ -61,12 +71,104 @@ func TestCreateDisk(t *testing.T) {
 				Tags:             map[string]string{VolumeNameTagKey: "vol-test"},
 				AvailabilityZone: "us-west-2",
 			},
+			expDisk: &Disk{
+				VolumeID:         "vol-test",
+				CapacityGiB:      1,
+				AvailabilityZone: "us-west-2",
+			},
+			expErr: nil,
+		},
+		{
+			name:       "success: normal from snapshot",
+			volumeName: "vol-test-name",
+			diskOptions: &DiskOptions{
+				CapacityBytes:    util.GiBToBytes(1),
+				Tags:             map[string]string{VolumeNameTagKey: "vol-test"},
+				AvailabilityZone: "",
+				SnapshotID:       "snap-test-name",
+			},
 			expDisk: &Disk{
 				VolumeID:    "vol-test",
 				CapacityGiB: 1,
+				SnapshotID:  "snap-test-name",
 			},
 			expErr: nil,
 		},
+		{
+			name:       "success: encrypted with KMS key",
+			volumeName: "vol-test-name",
+			diskOptions: &DiskOptions{
+				CapacityBytes:    util.GiBToBytes(1),
+				Tags:             map[string]string{VolumeNameTagKey: "vol-test"},
+				AvailabilityZone: "",
+				Encrypted:        true,
+				KmsKeyID:         "arn:aws:kms:us-east-1:012345678910:key/abcd1234",
+			},
+			expDisk: &Disk{
+				VolumeID:    "vol-test",
+				CapacityGiB: 1,
+				Encrypted:   true,
+				KmsKeyID:    "arn:aws:kms:us-east-1:012345678910:key/abcd1234",
+			},
+			expErr: nil,
+		},
+		{
+			name:       "fail: invalid KMS key",
+			volumeName: "vol-test-name",
+			diskOptions: &DiskOptions{
+				CapacityBytes:    util.GiBToBytes(1),
+				Tags:             map[string]string{VolumeNameTagKey: "vol-test"},
+				AvailabilityZone: "",
+				Encrypted:        true,
+				KmsKeyID:         "arn:aws:kms:us-east-1:012345678910:key/abcd1234",
+			},
+			mockErr: awserr.New("InvalidKMSKey.NotFound", "", nil),
+			expErr:  ErrInvalidKMSKey,
+		},
+		{
+			name:       "success: volume becomes available",
+			volumeName: "vol-test-name",
+			diskOptions: &DiskOptions{
+				CapacityBytes: util.GiBToBytes(1),
+				Tags:          map[string]string{VolumeNameTagKey: "vol-test"},
+			},
+			volStates: []string{ec2.VolumeStateCreating, ec2.VolumeStateAvailable},
+			expDisk: &Disk{
+				VolumeID:    "vol-test",
+				CapacityGiB: 1,
+			},
+		},
+		{
+			name:       "fail: idempotent parameter mismatch",
+			volumeName: "vol-test-name",
+			diskOptions: &DiskOptions{
+				CapacityBytes: util.GiBToBytes(1),
+				Tags:          map[string]string{VolumeNameTagKey: "vol-test"},
+			},
+			mockErr: awserr.New("IdempotentParameterMismatch", "", nil),
+			expErr:  ErrIdempotentParameterMismatch,
+		},
+		{
+			name:       "fail: volume in error state is deleted",
+			volumeName: "vol-test-name",
+			diskOptions: &DiskOptions{
+				CapacityBytes: util.GiBToBytes(1),
+				Tags:          map[string]string{VolumeNameTagKey: "vol-test"},
+			},
+			volStates:  []string{ec2.VolumeStateCreating, ec2.VolumeStateError},
+			expDeleted: true,
+			expErr:     fmt.Errorf("volume \"vol-test\" is in error state and was deleted"),
+		},
+		{
+			name:       "fail: volume does not become available",
+			volumeName: "vol-test-name",
+			diskOptions: &DiskOptions{
+				CapacityBytes: util.GiBToBytes(1),
+				Tags:          map[string]string{VolumeNameTagKey: "vol-test"},
+			},
+			volStates: []string{ec2.VolumeStateCreating, ec2.VolumeStateCreating, ec2.VolumeStateCreating},
+			expErr:    ErrCreationTimeout,
+		},
 		{
 			name:       "fail: CreateVolume returned an error",
 			volumeName: "vol-test-name-error",
@@ -75,10 +177,13 @@ func TestCreateDisk(t *testing.T) {
 				Tags:             map[string]string{VolumeNameTagKey: "vol-test"},
 				AvailabilityZone: "",
 			},
-			expErr: fmt.Errorf("CreateVolume generic error"),
+			mockErr: fmt.Errorf("CreateVolume generic error"),
+			expErr:  fmt.Errorf("could not create volume in EC2: CreateVolume generic error"),
 		},
 	}
 
+	defer setVolumeCreationBackoff(wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3})()
+
 	for _, tc := range testCases {
 		t.Logf("Test case: %s", tc.name)
 		mockCtrl := gomock.NewController(t)
@@ -86,21 +191,45 @@ func TestCreateDisk(t *testing.T) {
 		c := newCloud(mockEC2)
 
 		vol := &ec2.Volume{}
-		if tc.expErr == nil {
+		if tc.mockErr == nil {
 			vol = &ec2.Volume{
-				VolumeId: aws.String(tc.diskOptions.Tags[VolumeNameTagKey]),
-				Size:     aws.Int64(util.BytesToGiB(tc.diskOptions.CapacityBytes)),
+				VolumeId:  aws.String(tc.diskOptions.Tags[VolumeNameTagKey]),
+				Size:      aws.Int64(util.BytesToGiB(tc.diskOptions.CapacityBytes)),
+				Encrypted: aws.Bool(tc.diskOptions.Encrypted),
+			}
+			if len(tc.diskOptions.KmsKeyID) > 0 {
+				vol.KmsKeyId = aws.String(tc.diskOptions.KmsKeyID)
 			}
 		}
 
 		ctx := context.Background()
-		mockEC2.EXPECT().CreateVolumeWithContext(gomock.Eq(ctx), gomock.Any()).Return(vol, tc.expErr)
+		mockEC2.EXPECT().CreateVolumeWithContext(gomock.Eq(ctx), gomock.Any()).Return(vol, tc.mockErr)
+		if tc.mockErr == nil {
+			volStates := tc.volStates
+			if volStates == nil {
+				volStates = []string{ec2.VolumeStateAvailable}
+			}
+			var calls []*gomock.Call
+			for _, state := range volStates {
+				output := &ec2.DescribeVolumesOutput{
+					Volumes: []*ec2.Volume{{VolumeId: vol.VolumeId, State: aws.String(state)}},
+				}
+				calls = append(calls, mockEC2.EXPECT().DescribeVolumesWithContext(gomock.Eq(ctx), gomock.Any()).Return(output, nil))
+			}
+			gomock.InOrder(calls...)
+		}
+		if tc.expDeleted {
+			mockEC2.EXPECT().DeleteVolumeWithContext(gomock.Eq(ctx), gomock.Any()).Return(&ec2.DeleteVolumeOutput{}, nil)
+		}
 
 		disk, err := c.CreateDisk(ctx, tc.volumeName, tc.diskOptions)
 		if err != nil {
 			if tc.expErr == nil {
 				t.Fatalf("CreateDisk() failed: expected no error, got: %v", err)
 			}
+			if tc.expErr.Error() != err.Error() {
+				t.Fatalf("CreateDisk() failed: expected error %q, got: %q", tc.expErr, err)
+			}
 		} else {
 			if tc.expErr != nil {
 				t.Fatal("CreateDisk() failed: expected error, got nothing")
@@ -112,7 +241,140 @@ func TestCreateDisk(t *testing.T) {
 				if tc.expDisk.VolumeID != disk.VolumeID {
 					t.Fatalf("CreateDisk() failed: expected capacity %d, got %v", tc.expDisk.CapacityGiB, disk.CapacityGiB)
 				}
+
+				if tc.expDisk.AvailabilityZone != "" && tc.expDisk.AvailabilityZone != disk.AvailabilityZone {
+					t.Fatalf("CreateDisk() failed: expected zone %q, got %q", tc.expDisk.AvailabilityZone, disk.AvailabilityZone)
+				}
+
+				if tc.expDisk.SnapshotID != disk.SnapshotID {
+					t.Fatalf("CreateDisk() failed: expected snapshot ID %q, got %q", tc.expDisk.SnapshotID, disk.SnapshotID)
+				}
+
+				if tc.expDisk.Encrypted != disk.Encrypted || tc.expDisk.KmsKeyID != disk.KmsKeyID {
+					t.Fatalf("CreateDisk() failed: expected encryption %v/%q, got %v/%q", tc.expDisk.Encrypted, tc.expDisk.KmsKeyID, disk.Encrypted, disk.KmsKeyID)
+				}
+			}
+		}
+
+		mockCtrl.Finish()
+	}
+}
+
+func TestCreateDiskUnknownZone(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockEC2 := mocks.NewMockEC2(mockCtrl)
+	c := newCloud(mockEC2).(*cloud)
+	c.metadata = &metadata{region: "test-region"}
+
+	// Without a zone, the volume can't be created in the zone of the driver
+	_, err := c.CreateDisk(context.Background(), "vol-test-name", &DiskOptions{CapacityBytes: util.GiBToBytes(1)})
+	if !errors.Is(err, ErrInvalidDiskOptions) {
+		t.Fatalf("CreateDisk() failed: expected error %q, got: %v", ErrInvalidDiskOptions, err)
+	}
+}
+
+func TestCreateDiskClientToken(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockEC2 := mocks.NewMockEC2(mockCtrl)
+	c := newCloud(mockEC2).(*cloud)
+	defer setVolumeCreationBackoff(wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 1})()
+
+	token := c.clientToken("vol-test-name")
+	if len(token) != 64 {
+		t.Fatalf("Expected a token of 64 characters, got %q", token)
+	}
+	if c.clientToken("vol-test-name") != token {
+		t.Fatal("Expected the token of a volume name to be deterministic")
+	}
+	if c.clientToken("vol-other-name") == token {
+		t.Fatal("Expected different volume names to have different tokens")
+	}
+
+	// A volume in the error state is deleted, and the next attempt must not get it back
+	ctx := context.Background()
+	volume := &ec2.Volume{VolumeId: aws.String("vol-test"), Size: aws.Int64(1), State: aws.String(ec2.VolumeStateError)}
+	mockEC2.EXPECT().CreateVolumeWithContext(gomock.Eq(ctx), gomock.Any()).DoAndReturn(
+		func(_ aws.Context, input *ec2.CreateVolumeInput) (*ec2.Volume, error) {
+			if clientToken := aws.StringValue(input.ClientToken); clientToken != token {
+				t.Errorf("Expected ClientToken %q to be sent, got %q", token, clientToken)
+			}
+			return volume, nil
+		})
+	mockEC2.EXPECT().DescribeVolumesWithContext(gomock.Eq(ctx), gomock.Any()).Return(&ec2.DescribeVolumesOutput{Volumes: []*ec2.Volume{volume}}, nil)
+	mockEC2.EXPECT().DeleteVolumeWithContext(gomock.Eq(ctx), gomock.Any()).Return(&ec2.DeleteVolumeOutput{}, nil)
+	if _, err := c.CreateDisk(ctx, "vol-test-name", &DiskOptions{CapacityBytes: util.GiBToBytes(1)}); err == nil {
+		t.Fatal("CreateDisk() failed: expected error, got nothing")
+	}
+
+	retryToken := c.clientToken("vol-test-name")
+	if retryToken == token {
+		t.Fatal("Expected the token to change after the volume was deleted")
+	}
+	if c.clientToken("vol-test-name") != retryToken {
+		t.Fatal("Expected the token of the retries to be deterministic")
+	}
+}
+
+func TestCreateDiskProvisionedPerformance(t *testing.T) {
+	testCases := []struct {
+		name          string
+		diskOptions   *DiskOptions
+		expIOPS       int64
+		expThroughput int64
+		expErr        error
+	}{
+		{
+			name:          "success: gp3 with IOPS and throughput",
+			diskOptions:   &DiskOptions{CapacityBytes: util.GiBToBytes(100), VolumeType: VolumeTypeGP3, IOPS: 4000, Throughput: 500},
+			expIOPS:       4000,
+			expThroughput: 500,
+		},
+		{
+			name:        "success: io2 with IOPS per GiB",
+			diskOptions: &DiskOptions{CapacityBytes: util.GiBToBytes(100), VolumeType: VolumeTypeIO2, IOPSPerGB: 100},
+			expIOPS:     10000,
+		},
+		{
+			name:        "fail: IOPS too low",
+			diskOptions: &DiskOptions{CapacityBytes: util.GiBToBytes(100), VolumeType: VolumeTypeIO1, IOPS: 50},
+			expErr:      ErrInvalidDiskOptions,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		mockCtrl := gomock.NewController(t)
+		mockEC2 := mocks.NewMockEC2(mockCtrl)
+		c := newCloud(mockEC2)
+
+		ctx := context.Background()
+		if tc.expErr == nil {
+			volume := &ec2.Volume{VolumeId: aws.String("vol-test"), Size: aws.Int64(100)}
+			mockEC2.EXPECT().CreateVolumeWithContext(gomock.Eq(ctx), gomock.Any()).DoAndReturn(
+				func(_ aws.Context, input *ec2.CreateVolumeInput) (*ec2.Volume, error) {
+					if iops := aws.Int64Value(input.Iops); iops != tc.expIOPS {
+						t.Errorf("Expected %d IOPS to be requested, got %d", tc.expIOPS, iops)
+					}
+					if throughput := aws.Int64Value(input.Throughput); throughput != tc.expThroughput {
+						t.Errorf("Expected %d MiB/s of throughput to be requested, got %d", tc.expThroughput, throughput)
+					}
+					return volume, nil
+				})
+			output := &ec2.DescribeVolumesOutput{
+				Volumes: []*ec2.Volume{{VolumeId: volume.VolumeId, State: aws.String(ec2.VolumeStateAvailable)}},
 			}
+			mockEC2.EXPECT().DescribeVolumesWithContext(gomock.Eq(ctx), gomock.Any()).Return(output, nil)
+		}
+
+		_, err := c.CreateDisk(ctx, "vol-test-name", tc.diskOptions)
+		if tc.expErr != nil {
+			if !errors.Is(err, tc.expErr) {
+				t.Fatalf("CreateDisk() failed: expected error %q, got: %v", tc.expErr, err)
+			}
+		} else if err != nil {
+			t.Fatalf("CreateDisk() failed: expected no error, got: %v", err)
 		}
 
 		mockCtrl.Finish()
@@ -173,23 +435,54 @@ func TestDeleteDisk(t *testing.T) {
 }
 
 func TestAttachDisk(t *testing.T) {
+	defer setAttachmentStateBackoff(wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3})()
+
 	testCases := []struct {
-		name     string
-		volumeID string
-		nodeID   string
-		expErr   error
+		name             string
+		volumeID         string
+		nodeID           string
+		attachmentState  string
+		attachedInstance string
+		attachedDevice   string
+		attachErr        error
+		expErr           error
 	}{
 		{
-			name:     "success: normal",
-			volumeID: "vol-test-1234",
-			nodeID:   "node-1234",
-			expErr:   nil,
+			name:            "success: normal",
+			volumeID:        "vol-test-1234",
+			nodeID:          "node-1234",
+			attachmentState: ec2.VolumeAttachmentStateAttached,
+			expErr:          nil,
 		},
 		{
-			name:     "fail: AttachVolume returned generic error",
-			volumeID: "vol-test-1234",
-			nodeID:   "node-1234",
-			expErr:   fmt.Errorf(""),
+			name:      "fail: AttachVolume returned generic error",
+			volumeID:  "vol-test-1234",
+			nodeID:    "node-1234",
+			attachErr: fmt.Errorf("AttachVolume generic error"),
+			expErr:    fmt.Errorf("could not attach volume \"vol-test-1234\" to node \"node-1234\": AttachVolume generic error"),
+		},
+		{
+			name:            "fail: attachment timed out",
+			volumeID:        "vol-test-1234",
+			nodeID:          "node-1234",
+			attachmentState: ec2.VolumeAttachmentStateAttaching,
+			expErr:          ErrAttachmentTimeout,
+		},
+		{
+			name:            "fail: attached to a different device",
+			volumeID:        "vol-test-1234",
+			nodeID:          "node-1234",
+			attachmentState: ec2.VolumeAttachmentStateAttached,
+			attachedDevice:  "/dev/xvdzz",
+			expErr:          fmt.Errorf("volume \"vol-test-1234\" is attached to instance \"node-1234\" at device \"/dev/xvdzz\""),
+		},
+		{
+			name:             "fail: attached to a different instance",
+			volumeID:         "vol-test-1234",
+			nodeID:           "node-1234",
+			attachmentState:  ec2.VolumeAttachmentStateAttached,
+			attachedInstance: "node-5678",
+			expErr:           fmt.Errorf("volume \"vol-test-1234\" is attached to instance \"node-5678\" instead of \"node-1234\""),
 		},
 	}
 
@@ -199,15 +492,39 @@ func TestAttachDisk(t *testing.T) {
 		mockEC2 := mocks.NewMockEC2(mockCtrl)
 		c := newCloud(mockEC2)
 
+		attachedInstance := tc.nodeID
+		if tc.attachedInstance != "" {
+			attachedInstance = tc.attachedInstance
+		}
+
+		var requestedDevice string
 		ctx := context.Background()
 		mockEC2.EXPECT().DescribeInstancesWithContext(gomock.Eq(ctx), gomock.Any()).Return(newDescribeInstancesOutput(tc.nodeID), nil)
-		mockEC2.EXPECT().AttachVolumeWithContext(gomock.Eq(ctx), gomock.Any()).Return(&ec2.VolumeAttachment{}, tc.expErr)
+		mockEC2.EXPECT().AttachVolumeWithContext(gomock.Eq(ctx), gomock.Any()).DoAndReturn(
+			func(_ aws.Context, input *ec2.AttachVolumeInput, _ ...request.Option) (*ec2.VolumeAttachment, error) {
+				requestedDevice = aws.StringValue(input.Device)
+				return &ec2.VolumeAttachment{}, tc.attachErr
+			})
+		if tc.attachErr == nil {
+			mockEC2.EXPECT().DescribeVolumesWithContext(gomock.Eq(ctx), gomock.Any()).DoAndReturn(
+				func(_ aws.Context, _ *ec2.DescribeVolumesInput, _ ...request.Option) (*ec2.DescribeVolumesOutput, error) {
+					attachedDevice := requestedDevice
+					if tc.attachedDevice != "" {
+						attachedDevice = tc.attachedDevice
+					}
+					return newDescribeVolumesOutput(tc.volumeID, attachedInstance, attachedDevice, tc.attachmentState), nil
+				}).AnyTimes()
+		}
 
+		waitsBefore := testutil.HistogramCount(t, attachmentWaitDuration, ec2.VolumeAttachmentStateAttached)
 		devicePath, err := c.AttachDisk(ctx, tc.volumeID, tc.nodeID)
 		if err != nil {
 			if tc.expErr == nil {
 				t.Fatalf("AttachDisk() failed: expected no error, got: %v", err)
 			}
+			if !strings.HasPrefix(err.Error(), tc.expErr.Error()) {
+				t.Fatalf("AttachDisk() failed: expected error %q, got: %q", tc.expErr, err)
+			}
 		} else {
 			if tc.expErr != nil {
 				t.Fatal("AttachDisk() failed: expected error, got nothing")
@@ -216,29 +533,161 @@ func TestAttachDisk(t *testing.T) {
 				t.Fatal("AttachDisk() failed: expected valid device path, got emtpy string")
 			}
 		}
+		if tc.attachErr == nil && testutil.HistogramCount(t, attachmentWaitDuration, ec2.VolumeAttachmentStateAttached) != waitsBefore+1 {
+			t.Fatal("AttachDisk() failed: expected the wait for the attachment to be observed")
+		}
+
+		// The device must be kept assigned to the volume when the attachment timed out.
+		if tc.expErr == ErrAttachmentTimeout {
+			instance := &ec2.Instance{InstanceId: aws.String(tc.nodeID)}
+			device, err := c.(*cloud).dm.GetDevice(ctx, instance, tc.volumeID)
+			if err != nil {
+				t.Fatalf("GetDevice() failed: expected no error, got: %v", err)
+			}
+			if !device.IsAlreadyAssigned || device.Path != requestedDevice {
+				t.Fatalf("AttachDisk() failed: expected device %q to be kept assigned, got %+v", requestedDevice, device)
+			}
+		}
 
 		mockCtrl.Finish()
 	}
 }
 
+func TestDescribeInstanceErrors(t *testing.T) {
+	throttlingErr := awserr.New("RequestLimitExceeded", "Request limit exceeded.", nil)
+	notFoundErr := awserr.New("InvalidInstanceID.NotFound", "The instance ID 'node-1234' does not exist", nil)
+
+	testCases := []struct {
+		name          string
+		ec2Err        error
+		call          func(c Cloud) error
+		expErr        error
+		expThrottling bool
+	}{
+		{
+			name:   "AttachDisk: throttled",
+			ec2Err: throttlingErr,
+			call: func(c Cloud) error {
+				_, err := c.AttachDisk(context.Background(), "vol-test-1234", "node-1234")
+				return err
+			},
+			expThrottling: true,
+		},
+		{
+			name:   "DetachDisk: throttled",
+			ec2Err: throttlingErr,
+			call: func(c Cloud) error {
+				return c.DetachDisk(context.Background(), "vol-test-1234", "node-1234")
+			},
+			expThrottling: true,
+		},
+		{
+			name:   "IsExistInstance: throttled",
+			ec2Err: throttlingErr,
+			call: func(c Cloud) error {
+				_, err := c.IsExistInstance(context.Background(), "node-1234")
+				return err
+			},
+			expThrottling: true,
+		},
+		{
+			name:   "AttachDisk: instance not found",
+			ec2Err: notFoundErr,
+			call: func(c Cloud) error {
+				_, err := c.AttachDisk(context.Background(), "vol-test-1234", "node-1234")
+				return err
+			},
+			expErr: ErrNotFound,
+		},
+		{
+			name:   "IsExistInstance: instance not found",
+			ec2Err: notFoundErr,
+			call: func(c Cloud) error {
+				exists, err := c.IsExistInstance(context.Background(), "node-1234")
+				if exists {
+					return fmt.Errorf("instance should not exist")
+				}
+				return err
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockEC2 := mocks.NewMockEC2(mockCtrl)
+			c := newCloud(mockEC2)
+
+			mockEC2.EXPECT().DescribeInstancesWithContext(gomock.Any(), gomock.Any()).Return(nil, tc.ec2Err)
+
+			err := tc.call(c)
+			if tc.expThrottling {
+				if !IsThrottlingError(err) {
+					t.Fatalf("Expected throttling error, got: %v", err)
+				}
+				return
+			}
+			if err != tc.expErr {
+				t.Fatalf("Expected error %v, got: %v", tc.expErr, err)
+			}
+		})
+	}
+}
+
 func TestDetachDisk(t *testing.T) {
+	defer setAttachmentStateBackoff(wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3})()
+
 	testCases := []struct {
-		name     string
-		volumeID string
-		nodeID   string
-		expErr   error
+		name            string
+		volumeID        string
+		nodeID          string
+		attachmentState string
+		describeErr     error
+		detachErr       error
+		expErr          error
 	}{
 		{
-			name:     "success: normal",
-			volumeID: "vol-test-1234",
-			nodeID:   "node-1234",
-			expErr:   nil,
+			name:            "success: normal",
+			volumeID:        "vol-test-1234",
+			nodeID:          "node-1234",
+			attachmentState: ec2.VolumeAttachmentStateDetached,
+			expErr:          nil,
 		},
 		{
-			name:     "fail: DetachVolume returned generic error",
-			volumeID: "vol-test-1234",
-			nodeID:   "node-1234",
-			expErr:   fmt.Errorf("DetachVolume generic error"),
+			name:        "success: volume was deleted",
+			volumeID:    "vol-test-1234",
+			nodeID:      "node-1234",
+			describeErr: awserr.New("InvalidVolume.NotFound", "", nil),
+			expErr:      nil,
+		},
+		{
+			name:            "fail: detachment timed out",
+			volumeID:        "vol-test-1234",
+			nodeID:          "node-1234",
+			attachmentState: ec2.VolumeAttachmentStateDetaching,
+			expErr:          ErrAttachmentTimeout,
+		},
+		{
+			name:      "fail: volume not attached",
+			volumeID:  "vol-test-1234",
+			nodeID:    "node-1234",
+			detachErr: awserr.New("IncorrectState", "Volume 'vol-test-1234' is in the 'available' state.", nil),
+			expErr:    ErrNotFound,
+		},
+		{
+			name:      "fail: volume not found",
+			volumeID:  "vol-test-1234",
+			nodeID:    "node-1234",
+			detachErr: awserr.New("InvalidVolume.NotFound", "The volume 'vol-test-1234' does not exist.", nil),
+			expErr:    ErrNotFound,
+		},
+		{
+			name:      "fail: DetachVolume returned generic error",
+			volumeID:  "vol-test-1234",
+			nodeID:    "node-1234",
+			detachErr: fmt.Errorf("DetachVolume generic error"),
+			expErr:    fmt.Errorf("could not detach volume \"vol-test-1234\" from node \"node-1234\": DetachVolume generic error"),
 		},
 	}
 
@@ -250,13 +699,23 @@ func TestDetachDisk(t *testing.T) {
 
 		ctx := context.Background()
 		mockEC2.EXPECT().DescribeInstancesWithContext(gomock.Eq(ctx), gomock.Any()).Return(newDescribeInstancesOutput(tc.nodeID), nil)
-		mockEC2.EXPECT().DetachVolumeWithContext(gomock.Eq(ctx), gomock.Any()).Return(&ec2.VolumeAttachment{}, tc.expErr)
+		mockEC2.EXPECT().DetachVolumeWithContext(gomock.Eq(ctx), gomock.Any()).Return(&ec2.VolumeAttachment{}, tc.detachErr)
+		if tc.detachErr == nil {
+			var resp *ec2.DescribeVolumesOutput
+			if tc.describeErr == nil {
+				resp = newDescribeVolumesOutput(tc.volumeID, tc.nodeID, "/dev/xvdba", tc.attachmentState)
+			}
+			mockEC2.EXPECT().DescribeVolumesWithContext(gomock.Eq(ctx), gomock.Any()).Return(resp, tc.describeErr).AnyTimes()
+		}
 
 		err := c.DetachDisk(ctx, tc.volumeID, tc.nodeID)
 		if err != nil {
 			if tc.expErr == nil {
 				t.Fatalf("DetachDisk() failed: expected no error, got: %v", err)
 			}
+			if tc.expErr.Error() != err.Error() {
+				t.Fatalf("DetachDisk() failed: expected error %q, got: %q", tc.expErr, err)
+			}
 		} else {
 			if tc.expErr != nil {
 				t.Fatal("DetachDisk() failed: expected error, got nothing")
@@ -267,6 +726,117 @@ func TestDetachDisk(t *testing.T) {
 	}
 }
 
+func TestResizeDisk(t *testing.T) {
+	defer setVolumeModificationBackoff(wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3})()
+
+	testCases := []struct {
+		name              string
+		volumeID          string
+		existingSizeGiB   int64
+		newSizeBytes      int64
+		modifyErr         error
+		modificationState string
+		expSizeGiB        int64
+		expErr            error
+	}{
+		{
+			name:              "success: normal",
+			volumeID:          "vol-test",
+			existingSizeGiB:   1,
+			newSizeBytes:      util.GiBToBytes(2),
+			modificationState: ec2.VolumeModificationStateOptimizing,
+			expSizeGiB:        2,
+		},
+		{
+			name:            "success: already big enough",
+			volumeID:        "vol-test",
+			existingSizeGiB: 2,
+			newSizeBytes:    util.GiBToBytes(1),
+			expSizeGiB:      2,
+		},
+		{
+			name:              "success: modification already in progress",
+			volumeID:          "vol-test",
+			existingSizeGiB:   1,
+			newSizeBytes:      util.GiBToBytes(2),
+			modifyErr:         awserr.New("IncorrectModificationState", "", nil),
+			modificationState: ec2.VolumeModificationStateCompleted,
+			expSizeGiB:        2,
+		},
+		{
+			name:            "fail: ModifyVolume returned generic error",
+			volumeID:        "vol-test",
+			existingSizeGiB: 1,
+			newSizeBytes:    util.GiBToBytes(2),
+			modifyErr:       fmt.Errorf("ModifyVolume generic error"),
+			expErr:          fmt.Errorf("could not modify volume \"vol-test\": ModifyVolume generic error"),
+		},
+		{
+			name:              "fail: modification failed",
+			volumeID:          "vol-test",
+			existingSizeGiB:   1,
+			newSizeBytes:      util.GiBToBytes(2),
+			modificationState: ec2.VolumeModificationStateFailed,
+			expErr:            fmt.Errorf("modification of volume \"vol-test\" failed: "),
+		},
+		{
+			name:              "fail: modification timed out",
+			volumeID:          "vol-test",
+			existingSizeGiB:   1,
+			newSizeBytes:      util.GiBToBytes(2),
+			modificationState: ec2.VolumeModificationStateModifying,
+			expErr:            ErrModificationTimeout,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		mockCtrl := gomock.NewController(t)
+		mockEC2 := mocks.NewMockEC2(mockCtrl)
+		c := newCloud(mockEC2)
+
+		ctx := context.Background()
+		volume := &ec2.Volume{
+			VolumeId: aws.String(tc.volumeID),
+			Size:     aws.Int64(tc.existingSizeGiB),
+		}
+		mockEC2.EXPECT().DescribeVolumesWithContext(gomock.Eq(ctx), gomock.Any()).Return(&ec2.DescribeVolumesOutput{Volumes: []*ec2.Volume{volume}}, nil)
+		newSizeGiB := util.BytesToGiB(tc.newSizeBytes)
+		if newSizeGiB > tc.existingSizeGiB {
+			mockEC2.EXPECT().ModifyVolumeWithContext(gomock.Eq(ctx), gomock.Any()).Return(&ec2.ModifyVolumeOutput{}, tc.modifyErr)
+		}
+		if tc.modificationState != "" {
+			modification := &ec2.VolumeModification{
+				VolumeId:          aws.String(tc.volumeID),
+				ModificationState: aws.String(tc.modificationState),
+				TargetSize:        aws.Int64(newSizeGiB),
+			}
+			mockEC2.EXPECT().DescribeVolumesModificationsWithContext(gomock.Eq(ctx), gomock.Any()).Return(&ec2.DescribeVolumesModificationsOutput{
+				VolumesModifications: []*ec2.VolumeModification{modification},
+			}, nil).AnyTimes()
+		}
+
+		sizeGiB, err := c.ResizeDisk(ctx, tc.volumeID, tc.newSizeBytes)
+		if err != nil {
+			if tc.expErr == nil {
+				t.Fatalf("ResizeDisk() failed: expected no error, got: %v", err)
+			}
+			if tc.expErr.Error() != err.Error() {
+				t.Fatalf("ResizeDisk() failed: expected error %q, got: %q", tc.expErr, err)
+			}
+		} else {
+			if tc.expErr != nil {
+				t.Fatal("ResizeDisk() failed: expected error, got nothing")
+			}
+			if sizeGiB != tc.expSizeGiB {
+				t.Fatalf("ResizeDisk() failed: expected size %d, got %d", tc.expSizeGiB, sizeGiB)
+			}
+		}
+
+		mockCtrl.Finish()
+	}
+}
+
 func TestGetDiskByName(t *testing.T) {
 	testCases := []struct {
 		name           string
@@ -372,6 +942,563 @@ func TestGetDiskByID(t *testing.T) {
 	}
 }
 
+func TestListDisks(t *testing.T) {
+	page := []*ec2.Volume{
+		{VolumeId: aws.String("vol-1")},
+		{VolumeId: aws.String("vol-2")},
+		{VolumeId: aws.String("vol-3")},
+	}
+
+	testCases := []struct {
+		name       string
+		maxResults int64
+		nextToken  string
+		outputs    []*ec2.DescribeVolumesOutput
+		// expMaxResults and expPageToken are the MaxResults and NextToken expected to be requested
+		expMaxResults int64
+		expPageToken  string
+		expDisks      []string
+		expNextToken  string
+		expErr        error
+	}{
+		{
+			name: "success: all pages",
+			outputs: []*ec2.DescribeVolumesOutput{
+				{Volumes: []*ec2.Volume{{VolumeId: aws.String("vol-1")}}, NextToken: aws.String("token")},
+				{Volumes: []*ec2.Volume{{VolumeId: aws.String("vol-2")}}},
+			},
+			expPageToken: "token",
+			expDisks:     []string{"vol-1", "vol-2"},
+		},
+		{
+			name:       "success: single page",
+			maxResults: 5,
+			outputs: []*ec2.DescribeVolumesOutput{
+				{Volumes: []*ec2.Volume{{VolumeId: aws.String("vol-1")}}, NextToken: aws.String("token")},
+			},
+			expMaxResults: 5,
+			expDisks:      []string{"vol-1"},
+			expNextToken:  "token",
+		},
+		{
+			name:       "success: max results below the EC2 minimum truncate the page",
+			maxResults: 1,
+			outputs: []*ec2.DescribeVolumesOutput{
+				{Volumes: page, NextToken: aws.String("token")},
+			},
+			expMaxResults: 5,
+			expDisks:      []string{"vol-1"},
+			expNextToken:  "1#",
+		},
+		{
+			name:       "success: truncated page resumed",
+			maxResults: 1,
+			nextToken:  "1#",
+			outputs: []*ec2.DescribeVolumesOutput{
+				{Volumes: page, NextToken: aws.String("token")},
+			},
+			expMaxResults: 5,
+			expDisks:      []string{"vol-2"},
+			expNextToken:  "2#",
+		},
+		{
+			name:       "success: truncated page resumed until its end",
+			maxResults: 1,
+			nextToken:  "2#page-token",
+			outputs: []*ec2.DescribeVolumesOutput{
+				{Volumes: page, NextToken: aws.String("token")},
+			},
+			expMaxResults: 5,
+			expPageToken:  "page-token",
+			expDisks:      []string{"vol-3"},
+			expNextToken:  "token",
+		},
+		{
+			name:      "success: truncated page resumed without limit",
+			nextToken: "2#page-token",
+			outputs: []*ec2.DescribeVolumesOutput{
+				{Volumes: page, NextToken: aws.String("token")},
+				{Volumes: []*ec2.Volume{{VolumeId: aws.String("vol-4")}}},
+			},
+			expPageToken: "token",
+			expDisks:     []string{"vol-3", "vol-4"},
+		},
+		{
+			name:       "success: max results above the EC2 maximum",
+			maxResults: 501,
+			outputs: []*ec2.DescribeVolumesOutput{
+				{Volumes: page},
+			},
+			expMaxResults: 500,
+			expDisks:      []string{"vol-1", "vol-2", "vol-3"},
+		},
+		{
+			name:      "fail: invalid token",
+			nextToken: "invalid-token",
+			outputs:   []*ec2.DescribeVolumesOutput{nil},
+			expErr:    ErrInvalidNextToken,
+		},
+		{
+			name:      "fail: invalid offset",
+			nextToken: "invalid#token",
+			expErr:    ErrInvalidNextToken,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		mockCtrl := gomock.NewController(t)
+		mockEC2 := mocks.NewMockEC2(mockCtrl)
+		c := newCloud(mockEC2)
+
+		ctx := context.Background()
+		var lastInput *ec2.DescribeVolumesInput
+		for _, output := range tc.outputs {
+			var err error
+			if output == nil {
+				err = awserr.New("InvalidPaginationToken", "", nil)
+			}
+			output := output
+			mockEC2.EXPECT().DescribeVolumesWithContext(gomock.Eq(ctx), gomock.Any()).DoAndReturn(
+				func(_ aws.Context, input *ec2.DescribeVolumesInput, _ ...request.Option) (*ec2.DescribeVolumesOutput, error) {
+					lastInput = input
+					return output, err
+				})
+		}
+
+		resp, err := c.ListDisks(ctx, tc.maxResults, tc.nextToken)
+		if err != nil {
+			if err != tc.expErr {
+				t.Fatalf("ListDisks() failed: expected error %v, got: %v", tc.expErr, err)
+			}
+		} else {
+			if tc.expErr != nil {
+				t.Fatal("ListDisks() failed: expected error, got nothing")
+			}
+			var disks []string
+			for _, disk := range resp.Disks {
+				disks = append(disks, disk.VolumeID)
+			}
+			if !reflect.DeepEqual(disks, tc.expDisks) {
+				t.Fatalf("ListDisks() failed: expected disks %v, got %v", tc.expDisks, disks)
+			}
+			if resp.NextToken != tc.expNextToken {
+				t.Fatalf("ListDisks() failed: expected next token %q, got %q", tc.expNextToken, resp.NextToken)
+			}
+			if maxResults := aws.Int64Value(lastInput.MaxResults); maxResults != tc.expMaxResults {
+				t.Fatalf("ListDisks() failed: expected MaxResults %d to be requested, got %d", tc.expMaxResults, maxResults)
+			}
+			if pageToken := aws.StringValue(lastInput.NextToken); pageToken != tc.expPageToken {
+				t.Fatalf("ListDisks() failed: expected NextToken %q to be requested, got %q", tc.expPageToken, pageToken)
+			}
+		}
+
+		mockCtrl.Finish()
+	}
+}
+
+func TestCreateSnapshot(t *testing.T) {
+	testCases := []struct {
+		name            string
+		snapshotName    string
+		snapshotOptions *SnapshotOptions
+		expSnapshot     *Snapshot
+		expErr          error
+	}{
+		{
+			name:         "success: normal",
+			snapshotName: "snap-test-name",
+			snapshotOptions: &SnapshotOptions{
+				Tags: map[string]string{SnapshotNameTagKey: "snap-test-name"},
+			},
+			expSnapshot: &Snapshot{
+				SourceVolumeID: "snap-test-volume",
+			},
+			expErr: nil,
+		},
+		{
+			name:         "fail: CreateSnapshot returned an error",
+			snapshotName: "snap-test-name",
+			snapshotOptions: &SnapshotOptions{
+				Tags: map[string]string{SnapshotNameTagKey: "snap-test-name"},
+			},
+			expErr: fmt.Errorf("CreateSnapshot generic error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		mockCtrl := gomock.NewController(t)
+		mockEC2 := mocks.NewMockEC2(mockCtrl)
+		c := newCloud(mockEC2)
+
+		ec2snapshot := &ec2.Snapshot{}
+		if tc.expErr == nil {
+			ec2snapshot = &ec2.Snapshot{
+				SnapshotId: aws.String(tc.snapshotOptions.Tags[SnapshotNameTagKey]),
+				VolumeId:   aws.String(tc.expSnapshot.SourceVolumeID),
+				State:      aws.String("completed"),
+				StartTime:  aws.Time(time.Now()),
+			}
+		}
+
+		ctx := context.Background()
+		mockEC2.EXPECT().CreateSnapshotWithContext(gomock.Eq(ctx), gomock.Any()).Return(ec2snapshot, tc.expErr)
+
+		snapshot, err := c.CreateSnapshot(ctx, "snap-test-volume", tc.snapshotOptions)
+		if err != nil {
+			if tc.expErr == nil {
+				t.Fatalf("CreateSnapshot() failed: expected no error, got: %v", err)
+			}
+		} else {
+			if tc.expErr != nil {
+				t.Fatal("CreateSnapshot() failed: expected error, got nothing")
+			}
+			if snapshot.SourceVolumeID != tc.expSnapshot.SourceVolumeID {
+				t.Fatalf("CreateSnapshot() failed: expected source volume ID %q, got %q", tc.expSnapshot.SourceVolumeID, snapshot.SourceVolumeID)
+			}
+			if !snapshot.ReadyToUse {
+				t.Fatal("CreateSnapshot() failed: expected completed snapshot to be ready to use")
+			}
+		}
+
+		mockCtrl.Finish()
+	}
+}
+
+func TestDeleteSnapshot(t *testing.T) {
+	testCases := []struct {
+		name       string
+		snapshotID string
+		expResp    bool
+		expErr     error
+	}{
+		{
+			name:       "success: normal",
+			snapshotID: "snap-test-1234",
+			expResp:    true,
+			expErr:     nil,
+		},
+		{
+			name:       "fail: DeleteSnapshot returned generic error",
+			snapshotID: "snap-test-1234",
+			expResp:    false,
+			expErr:     fmt.Errorf("DeleteSnapshot generic error"),
+		},
+		{
+			name:       "fail: DeleteSnapshot returned not found error",
+			snapshotID: "snap-test-1234",
+			expResp:    false,
+			expErr:     awserr.New("InvalidSnapshot.NotFound", "", nil),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		mockCtrl := gomock.NewController(t)
+		mockEC2 := mocks.NewMockEC2(mockCtrl)
+		c := newCloud(mockEC2)
+
+		ctx := context.Background()
+		mockEC2.EXPECT().DeleteSnapshotWithContext(gomock.Eq(ctx), gomock.Any()).Return(&ec2.DeleteSnapshotOutput{}, tc.expErr)
+
+		ok, err := c.DeleteSnapshot(ctx, tc.snapshotID)
+		if err != nil && tc.expErr == nil {
+			t.Fatalf("DeleteSnapshot() failed: expected no error, got: %v", err)
+		}
+
+		if err == nil && tc.expErr != nil {
+			t.Fatal("DeleteSnapshot() failed: expected error, got nothing")
+		}
+
+		if tc.expResp != ok {
+			t.Fatalf("DeleteSnapshot() failed: expected return %v, got %v", tc.expResp, ok)
+		}
+
+		mockCtrl.Finish()
+	}
+}
+
+func TestGetSnapshotByName(t *testing.T) {
+	testCases := []struct {
+		name         string
+		snapshotName string
+		state        string
+		expErr       error
+	}{
+		{
+			name:         "success: normal",
+			snapshotName: "snap-test-name",
+			state:        "completed",
+			expErr:       nil,
+		},
+		{
+			name:         "success: pending snapshot",
+			snapshotName: "snap-test-name",
+			state:        "pending",
+			expErr:       nil,
+		},
+		{
+			name:         "fail: DescribeSnapshots returned generic error",
+			snapshotName: "snap-test-name",
+			expErr:       fmt.Errorf("DescribeSnapshots generic error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		mockCtrl := gomock.NewController(t)
+		mockEC2 := mocks.NewMockEC2(mockCtrl)
+		c := newCloud(mockEC2)
+
+		ec2snapshot := &ec2.Snapshot{
+			SnapshotId: aws.String(tc.snapshotName),
+			VolumeId:   aws.String("snap-test-volume"),
+			State:      aws.String(tc.state),
+		}
+
+		ctx := context.Background()
+		mockEC2.EXPECT().DescribeSnapshotsWithContext(gomock.Eq(ctx), gomock.Any()).Return(&ec2.DescribeSnapshotsOutput{Snapshots: []*ec2.Snapshot{ec2snapshot}}, tc.expErr)
+
+		snapshot, err := c.GetSnapshotByName(ctx, tc.snapshotName)
+		if err != nil {
+			if tc.expErr == nil {
+				t.Fatalf("GetSnapshotByName() failed: expected no error, got: %v", err)
+			}
+		} else {
+			if tc.expErr != nil {
+				t.Fatal("GetSnapshotByName() failed: expected error, got nothing")
+			}
+			if snapshot.ReadyToUse != (tc.state == "completed") {
+				t.Fatalf("GetSnapshotByName() failed: expected ready to use %v for state %q, got %v", tc.state == "completed", tc.state, snapshot.ReadyToUse)
+			}
+		}
+
+		mockCtrl.Finish()
+	}
+}
+
+func TestListSnapshots(t *testing.T) {
+	page := []*ec2.Snapshot{
+		{SnapshotId: aws.String("snap-1")},
+		{SnapshotId: aws.String("snap-2")},
+		{SnapshotId: aws.String("snap-3")},
+	}
+
+	testCases := []struct {
+		name       string
+		maxResults int64
+		nextToken  string
+		outputs    []*ec2.DescribeSnapshotsOutput
+		// expMaxResults and expPageToken are the MaxResults and NextToken expected to be requested
+		expMaxResults int64
+		expPageToken  string
+		expSnapshots  []string
+		expNextToken  string
+		expErr        error
+	}{
+		{
+			name: "success: all pages",
+			outputs: []*ec2.DescribeSnapshotsOutput{
+				{Snapshots: []*ec2.Snapshot{{SnapshotId: aws.String("snap-1")}}, NextToken: aws.String("token")},
+				{Snapshots: []*ec2.Snapshot{{SnapshotId: aws.String("snap-2")}}},
+			},
+			expPageToken: "token",
+			expSnapshots: []string{"snap-1", "snap-2"},
+		},
+		{
+			name:       "success: single page",
+			maxResults: 5,
+			outputs: []*ec2.DescribeSnapshotsOutput{
+				{Snapshots: []*ec2.Snapshot{{SnapshotId: aws.String("snap-1")}}, NextToken: aws.String("token")},
+			},
+			expMaxResults: 5,
+			expSnapshots:  []string{"snap-1"},
+			expNextToken:  "token",
+		},
+		{
+			name:       "success: max results below the EC2 minimum truncate the page",
+			maxResults: 1,
+			outputs: []*ec2.DescribeSnapshotsOutput{
+				{Snapshots: page, NextToken: aws.String("token")},
+			},
+			expMaxResults: 5,
+			expSnapshots:  []string{"snap-1"},
+			expNextToken:  "1#",
+		},
+		{
+			name:       "success: truncated page resumed",
+			maxResults: 2,
+			nextToken:  "1#",
+			outputs: []*ec2.DescribeSnapshotsOutput{
+				{Snapshots: page, NextToken: aws.String("token")},
+			},
+			expMaxResults: 5,
+			expSnapshots:  []string{"snap-2", "snap-3"},
+			expNextToken:  "token",
+		},
+		{
+			name:      "success: truncated page resumed without limit",
+			nextToken: "2#page-token",
+			outputs: []*ec2.DescribeSnapshotsOutput{
+				{Snapshots: page, NextToken: aws.String("token")},
+				{Snapshots: []*ec2.Snapshot{{SnapshotId: aws.String("snap-4")}}},
+			},
+			expPageToken: "token",
+			expSnapshots: []string{"snap-3", "snap-4"},
+		},
+		{
+			name:       "success: max results above the EC2 maximum",
+			maxResults: 1001,
+			outputs: []*ec2.DescribeSnapshotsOutput{
+				{Snapshots: page},
+			},
+			expMaxResults: 1000,
+			expSnapshots:  []string{"snap-1", "snap-2", "snap-3"},
+		},
+		{
+			name:      "fail: invalid token",
+			nextToken: "invalid-token",
+			outputs:   []*ec2.DescribeSnapshotsOutput{nil},
+			expErr:    ErrInvalidNextToken,
+		},
+		{
+			name:      "fail: invalid offset",
+			nextToken: "0#token",
+			expErr:    ErrInvalidNextToken,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		mockCtrl := gomock.NewController(t)
+		mockEC2 := mocks.NewMockEC2(mockCtrl)
+		c := newCloud(mockEC2)
+
+		ctx := context.Background()
+		var lastInput *ec2.DescribeSnapshotsInput
+		for _, output := range tc.outputs {
+			var err error
+			if output == nil {
+				err = awserr.New("InvalidPaginationToken", "", nil)
+			}
+			output := output
+			mockEC2.EXPECT().DescribeSnapshotsWithContext(gomock.Eq(ctx), gomock.Any()).DoAndReturn(
+				func(_ aws.Context, input *ec2.DescribeSnapshotsInput, _ ...request.Option) (*ec2.DescribeSnapshotsOutput, error) {
+					lastInput = input
+					return output, err
+				})
+		}
+
+		resp, err := c.ListSnapshots(ctx, "", tc.maxResults, tc.nextToken)
+		if err != nil {
+			if err != tc.expErr {
+				t.Fatalf("ListSnapshots() failed: expected error %v, got: %v", tc.expErr, err)
+			}
+		} else {
+			if tc.expErr != nil {
+				t.Fatal("ListSnapshots() failed: expected error, got nothing")
+			}
+			var snapshots []string
+			for _, snapshot := range resp.Snapshots {
+				snapshots = append(snapshots, snapshot.SnapshotID)
+			}
+			if !reflect.DeepEqual(snapshots, tc.expSnapshots) {
+				t.Fatalf("ListSnapshots() failed: expected snapshots %v, got %v", tc.expSnapshots, snapshots)
+			}
+			if resp.NextToken != tc.expNextToken {
+				t.Fatalf("ListSnapshots() failed: expected next token %q, got %q", tc.expNextToken, resp.NextToken)
+			}
+			if maxResults := aws.Int64Value(lastInput.MaxResults); maxResults != tc.expMaxResults {
+				t.Fatalf("ListSnapshots() failed: expected MaxResults %d to be requested, got %d", tc.expMaxResults, maxResults)
+			}
+			if pageToken := aws.StringValue(lastInput.NextToken); pageToken != tc.expPageToken {
+				t.Fatalf("ListSnapshots() failed: expected NextToken %q to be requested, got %q", tc.expPageToken, pageToken)
+			}
+		}
+
+		mockCtrl.Finish()
+	}
+}
+
+func TestGetAvailabilityZones(t *testing.T) {
+	testCases := []struct {
+		name     string
+		zones    []*ec2.AvailabilityZone
+		ec2Err   error
+		expZones []string
+		expErr   bool
+	}{
+		{
+			name: "success: available zones sorted",
+			zones: []*ec2.AvailabilityZone{
+				{ZoneName: aws.String("us-west-2c"), State: aws.String(ec2.AvailabilityZoneStateAvailable)},
+				{ZoneName: aws.String("us-west-2a"), State: aws.String(ec2.AvailabilityZoneStateAvailable)},
+				{ZoneName: aws.String("us-west-2b"), State: aws.String(ec2.AvailabilityZoneStateImpaired)},
+			},
+			expZones: []string{"us-west-2a", "us-west-2c"},
+		},
+		{
+			name:   "fail: no available zone",
+			zones:  []*ec2.AvailabilityZone{},
+			expErr: true,
+		},
+		{
+			name:   "success: no zone when DescribeAvailabilityZones is denied",
+			ec2Err: awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation.", nil),
+		},
+		{
+			name:   "fail: DescribeAvailabilityZones returned generic error",
+			ec2Err: fmt.Errorf("DescribeAvailabilityZones generic error"),
+			expErr: true,
+		},
+		{
+			name:   "fail: DescribeAvailabilityZones throttled",
+			ec2Err: awserr.New("RequestLimitExceeded", "Request limit exceeded.", nil),
+			expErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		mockCtrl := gomock.NewController(t)
+		mockEC2 := mocks.NewMockEC2(mockCtrl)
+		c := newCloud(mockEC2)
+
+		ctx := context.Background()
+		// The zones are only described once while they are cached
+		mockEC2.EXPECT().DescribeAvailabilityZonesWithContext(gomock.Eq(ctx), gomock.Any()).Return(
+			&ec2.DescribeAvailabilityZonesOutput{AvailabilityZones: tc.zones},
+			tc.ec2Err,
+		).Times(1)
+
+		zones, err := c.GetAvailabilityZones(ctx)
+		if err != nil {
+			if !tc.expErr {
+				t.Fatalf("GetAvailabilityZones() failed: expected no error, got: %v", err)
+			}
+			mockCtrl.Finish()
+			continue
+		}
+		if tc.expErr {
+			t.Fatal("GetAvailabilityZones() failed: expected error, got nothing")
+		}
+		if !reflect.DeepEqual(zones, tc.expZones) {
+			t.Fatalf("GetAvailabilityZones() failed: expected zones %v, got %v", tc.expZones, zones)
+		}
+
+		zones, err = c.GetAvailabilityZones(ctx)
+		if err != nil {
+			t.Fatalf("GetAvailabilityZones() failed: expected no error, got: %v", err)
+		}
+		if !reflect.DeepEqual(zones, tc.expZones) {
+			t.Fatalf("GetAvailabilityZones() failed: expected cached zones %v, got %v", tc.expZones, zones)
+		}
+
+		mockCtrl.Finish()
+	}
+}
+
 func newCloud(mockEC2 EC2) Cloud {
 	return &cloud{
 		metadata: &metadata{
@@ -384,6 +1511,40 @@ func newCloud(mockEC2 EC2) Cloud {
 	}
 }
 
+func newDescribeVolumesOutput(volumeID, nodeID, device, state string) *ec2.DescribeVolumesOutput {
+	volume := &ec2.Volume{VolumeId: aws.String(volumeID)}
+	if state != ec2.VolumeAttachmentStateDetached {
+		volume.Attachments = []*ec2.VolumeAttachment{{
+			Device:     aws.String(device),
+			InstanceId: aws.String(nodeID),
+			State:      aws.String(state),
+			VolumeId:   aws.String(volumeID),
+		}}
+	}
+	return &ec2.DescribeVolumesOutput{Volumes: []*ec2.Volume{volume}}
+}
+
+// setAttachmentStateBackoff replaces the attachment backoff and returns a function restoring it.
+func setAttachmentStateBackoff(backoff wait.Backoff) func() {
+	orig := attachmentStateBackoff
+	attachmentStateBackoff = backoff
+	return func() { attachmentStateBackoff = orig }
+}
+
+// setVolumeCreationBackoff replaces the creation backoff and returns a function restoring it.
+func setVolumeCreationBackoff(backoff wait.Backoff) func() {
+	orig := volumeCreationBackoff
+	volumeCreationBackoff = backoff
+	return func() { volumeCreationBackoff = orig }
+}
+
+// setVolumeModificationBackoff replaces the modification backoff and returns a function restoring it.
+func setVolumeModificationBackoff(backoff wait.Backoff) func() {
+	orig := volumeModificationBackoff
+	volumeModificationBackoff = backoff
+	return func() { volumeModificationBackoff = orig }
+}
+
 func newDescribeInstancesOutput(nodeID string) *ec2.DescribeInstancesOutput {
 	return &ec2.DescribeInstancesOutput{
 		Reservations: []*ec2.Reservation{{
@@ -17,13 +17,14 @@ limitations under the License.
 package devicemanager
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
-	"github.com/golang/glog"
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/logging"
 )
 
 const devPreffix = "/dev/xvd"
@@ -36,12 +37,14 @@ type Device struct {
 
 	isTainted   bool
 	releaseFunc func() error
+	// logger logs with the fields of the request the device was assigned for
+	logger logging.Logger
 }
 
 func (d *Device) Release(force bool) {
 	if !d.isTainted || force {
 		if err := d.releaseFunc(); err != nil {
-			glog.Errorf("Error releasing device: %v", err)
+			d.logger.Errorf("Error releasing device: %v", err)
 		}
 	}
 }
@@ -54,10 +57,10 @@ type DeviceManager interface {
 	// NewDevice gets the device already assigned to the volume, or assigns an unused device.
 	// If the volume is already assigned, this will return the existing device with IsAlreadyAssigned=true.
 	// Otherwise the device is assigned by finding the first available device, and it is returned with IsAlreadyAssigned=false.
-	NewDevice(instance *ec2.Instance, volumeID string) (device *Device, err error)
+	NewDevice(ctx context.Context, instance *ec2.Instance, volumeID string) (device *Device, err error)
 
 	// GetDevice returns the device already assigned to the volume.
-	GetDevice(instance *ec2.Instance, volumeID string) (device *Device, err error)
+	GetDevice(ctx context.Context, instance *ec2.Instance, volumeID string) (device *Device, err error)
 }
 
 type deviceManager struct {
@@ -105,7 +108,7 @@ func NewDeviceManager() DeviceManager {
 	}
 }
 
-func (d *deviceManager) NewDevice(instance *ec2.Instance, volumeID string) (*Device, error) {
+func (d *deviceManager) NewDevice(ctx context.Context, instance *ec2.Instance, volumeID string) (*Device, error) {
 	nodeID, err := getInstanceID(instance)
 	if err != nil {
 		return nil, err
@@ -115,14 +118,15 @@ func (d *deviceManager) NewDevice(instance *ec2.Instance, volumeID string) (*Dev
 	defer d.mux.Unlock()
 
 	// Get device names being attached and already attached to this instance
-	inUse, err := d.getDeviceNamesInUse(instance, nodeID)
+	inUse, err := d.getDeviceNamesInUse(ctx, instance, nodeID)
 	if err != nil {
 		return nil, fmt.Errorf("could not get devices used in instance %q", nodeID)
 	}
 
 	// Check if this volume is already assigned a device on this machine
 	if path := d.getPath(inUse, volumeID); path != "" {
-		return d.newBlockDevice(instance, volumeID, path, true), nil
+		logging.FromContext(ctx).V(5).Infof("Volume %s is already assigned device %s", volumeID, path)
+		return d.newBlockDevice(ctx, instance, volumeID, path, true), nil
 	}
 
 	// Find the next unused device name
@@ -143,10 +147,11 @@ func (d *deviceManager) NewDevice(instance *ec2.Instance, volumeID string) (*Dev
 	// Deprioritize this name so it's not picked again right away.
 	nameAllocator.Deprioritize(name)
 
-	return d.newBlockDevice(instance, volumeID, devPreffix+name, false), nil
+	logging.FromContext(ctx).V(5).Infof("Assigned device %s to volume %s", devPreffix+name, volumeID)
+	return d.newBlockDevice(ctx, instance, volumeID, devPreffix+name, false), nil
 }
 
-func (d *deviceManager) GetDevice(instance *ec2.Instance, volumeID string) (*Device, error) {
+func (d *deviceManager) GetDevice(ctx context.Context, instance *ec2.Instance, volumeID string) (*Device, error) {
 	nodeID, err := getInstanceID(instance)
 	if err != nil {
 		return nil, err
@@ -155,13 +160,13 @@ func (d *deviceManager) GetDevice(instance *ec2.Instance, volumeID string) (*Dev
 	d.mux.Lock()
 	defer d.mux.Unlock()
 
-	inUse, err := d.getDeviceNamesInUse(instance, nodeID)
+	inUse, err := d.getDeviceNamesInUse(ctx, instance, nodeID)
 	if err != nil {
 		return nil, fmt.Errorf("could not get devices used in instance %q", nodeID)
 	}
 
 	path := d.getPath(inUse, volumeID)
-	device := d.newBlockDevice(instance, volumeID, path, false)
+	device := d.newBlockDevice(ctx, instance, volumeID, path, false)
 
 	if path != "" {
 		device.IsAlreadyAssigned = true
@@ -171,7 +176,7 @@ func (d *deviceManager) GetDevice(instance *ec2.Instance, volumeID string) (*Dev
 	return device, nil
 }
 
-func (d *deviceManager) newBlockDevice(instance *ec2.Instance, volumeID string, path string, isAlreadyAssigned bool) *Device {
+func (d *deviceManager) newBlockDevice(ctx context.Context, instance *ec2.Instance, volumeID string, path string, isAlreadyAssigned bool) *Device {
 	device := &Device{
 		Instance:          instance,
 		Path:              path,
@@ -179,6 +184,7 @@ func (d *deviceManager) newBlockDevice(instance *ec2.Instance, volumeID string,
 		IsAlreadyAssigned: isAlreadyAssigned,
 
 		isTainted: false,
+		logger:    logging.FromContext(ctx),
 	}
 	device.releaseFunc = func() error {
 		return d.release(device)
@@ -214,13 +220,13 @@ func (d *deviceManager) release(device *Device) error {
 		return fmt.Errorf("release on device %q assigned to different volume: %q vs %q", device.Path, device.VolumeID, existingVolumeID)
 	}
 
-	glog.V(5).Infof("Releasing in-process attachment entry: %v -> volume %s", device, device.VolumeID)
+	device.logger.V(5).Infof("Releasing in-process attachment entry: %s -> volume %s", device.Path, device.VolumeID)
 	d.inFlight.Del(nodeID, name)
 
 	return nil
 }
 
-func (d *deviceManager) getDeviceNamesInUse(instance *ec2.Instance, nodeID string) (map[string]string, error) {
+func (d *deviceManager) getDeviceNamesInUse(ctx context.Context, instance *ec2.Instance, nodeID string) (map[string]string, error) {
 	inUse := map[string]string{}
 	for _, blockDevice := range instance.BlockDeviceMappings {
 		name := aws.StringValue(blockDevice.DeviceName)
@@ -231,7 +237,7 @@ func (d *deviceManager) getDeviceNamesInUse(instance *ec2.Instance, nodeID strin
 			name = name[8:]
 		}
 		if len(name) < 1 || len(name) > 2 {
-			glog.Warningf("Unexpected EBS DeviceName: %q", aws.StringValue(blockDevice.DeviceName))
+			logging.FromContext(ctx).Warningf("Unexpected EBS DeviceName: %q", aws.StringValue(blockDevice.DeviceName))
 		}
 		inUse[name] = aws.StringValue(blockDevice.Ebs.VolumeId)
 	}
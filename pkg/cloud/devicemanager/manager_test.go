@@ -17,6 +17,7 @@ limitations under the License.
 package devicemanager
 
 import (
+	"context"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -62,7 +63,7 @@ func TestNewDevice(t *testing.T) {
 			t.Parallel()
 
 			// Should fail if instance is nil
-			dev1, err := dm.NewDevice(nil, tc.volumeID)
+			dev1, err := dm.NewDevice(context.TODO(), nil, tc.volumeID)
 			if err == nil {
 				t.Fatalf("Expected error when nil instance is passed in, got nothing")
 			}
@@ -73,11 +74,11 @@ func TestNewDevice(t *testing.T) {
 			fakeInstance := newFakeInstance(tc.instanceID, tc.existingVolumeID, tc.existingDevicePath)
 
 			// Should create valid Device with valid path
-			dev1, err = dm.NewDevice(fakeInstance, tc.volumeID)
+			dev1, err = dm.NewDevice(context.TODO(), fakeInstance, tc.volumeID)
 			assertDevice(t, dev1, false, err)
 
 			// Devices with same instance and volume should have same paths
-			dev2, err := dm.NewDevice(fakeInstance, tc.volumeID)
+			dev2, err := dm.NewDevice(context.TODO(), fakeInstance, tc.volumeID)
 			assertDevice(t, dev2, true /*IsAlreadyAssigned*/, err)
 			if dev1.Path != dev2.Path {
 				t.Fatalf("Expected equal paths, got %v and %v", dev1.Path, dev2.Path)
@@ -85,7 +86,7 @@ func TestNewDevice(t *testing.T) {
 
 			// Should create new Device with a different path after releasing
 			dev2.Release(false)
-			dev3, err := dm.NewDevice(fakeInstance, tc.volumeID)
+			dev3, err := dm.NewDevice(context.TODO(), fakeInstance, tc.volumeID)
 			assertDevice(t, dev3, false, err)
 			if dev3.Path == dev1.Path {
 				t.Fatalf("Expected equal paths, got %v and %v", dev1.Path, dev2.Path)
@@ -118,11 +119,11 @@ func TestGetDevice(t *testing.T) {
 			fakeInstance := newFakeInstance(tc.instanceID, tc.existingVolumeID, tc.existingDevicePath)
 
 			// Should create valid Device with valid path
-			dev1, err := dm.NewDevice(fakeInstance, tc.volumeID)
+			dev1, err := dm.NewDevice(context.TODO(), fakeInstance, tc.volumeID)
 			assertDevice(t, dev1, false /*IsAlreadyAssigned*/, err)
 
 			// Devices with same instance and volume should have same paths
-			dev2, err := dm.GetDevice(fakeInstance, tc.volumeID)
+			dev2, err := dm.GetDevice(context.TODO(), fakeInstance, tc.volumeID)
 			assertDevice(t, dev2, true /*IsAlreadyAssigned*/, err)
 			if dev1.Path != dev2.Path {
 				t.Fatalf("Expected equal paths, got %v and %v", dev1.Path, dev2.Path)
@@ -154,11 +155,11 @@ func TestReleaseDevice(t *testing.T) {
 			fakeInstance := newFakeInstance(tc.instanceID, tc.existingVolumeID, tc.existingDevicePath)
 
 			// Should get assigned Device after releasing tainted device
-			dev, err := dm.NewDevice(fakeInstance, tc.volumeID)
+			dev, err := dm.NewDevice(context.TODO(), fakeInstance, tc.volumeID)
 			assertDevice(t, dev, false /*IsAlreadyAssigned*/, err)
 			dev.Taint()
 			dev.Release(false)
-			dev2, err := dm.GetDevice(fakeInstance, tc.volumeID)
+			dev2, err := dm.GetDevice(context.TODO(), fakeInstance, tc.volumeID)
 			assertDevice(t, dev2, true /*IsAlreadyAssigned*/, err)
 			if dev2.Path != dev2.Path {
 				t.Fatalf("Expected device to be already assigned, got unassigned")
@@ -166,7 +167,7 @@ func TestReleaseDevice(t *testing.T) {
 
 			// Should release tainted device if force=true is passed in
 			dev2.Release(true)
-			dev3, err := dm.GetDevice(fakeInstance, tc.volumeID)
+			dev3, err := dm.GetDevice(context.TODO(), fakeInstance, tc.volumeID)
 			assertDevice(t, dev3, false /*IsAlreadyAssigned*/, err)
 		})
 	}
@@ -195,14 +196,14 @@ func TestExaustDevices(t *testing.T) {
 			fakeInstance := newFakeInstance(tc.instanceID, tc.existingVolumeID, tc.existingDevicePath)
 
 			// Create one device and save it for later
-			dev, err := dm.NewDevice(fakeInstance, tc.volumeID)
+			dev, err := dm.NewDevice(context.TODO(), fakeInstance, tc.volumeID)
 			assertDevice(t, dev, false /*IsAlreadyAssigned*/, err)
 			dev.Release(true)
 
 			// The maximum number of the ring is 52, so create enough devices
 			// to circle back to the first device gotten, i.e., dev
 			for i := 0; i < 51; i++ {
-				d, err := dm.NewDevice(fakeInstance, tc.volumeID)
+				d, err := dm.NewDevice(context.TODO(), fakeInstance, tc.volumeID)
 				assertDevice(t, d, false, err)
 				// Make sure none of them have the same path as the first device created
 				if d.Path == dev.Path {
@@ -211,7 +212,7 @@ func TestExaustDevices(t *testing.T) {
 				d.Release(true)
 			}
 
-			dev2, err := dm.NewDevice(fakeInstance, tc.volumeID)
+			dev2, err := dm.NewDevice(context.TODO(), fakeInstance, tc.volumeID)
 			assertDevice(t, dev2, false /*IsAlreadyAssigned*/, err)
 
 			//Should be equal to the first device created
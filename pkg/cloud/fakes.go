@@ -20,15 +20,18 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/util"
 )
 
 type FakeCloudProvider struct {
-	disks map[string]*fakeDisk
-	m     *metadata
-	pub   map[string]string
+	disks     map[string]*fakeDisk
+	snapshots []*fakeSnapshot
+	m         *metadata
+	pub       map[string]string
 }
 
 type fakeDisk struct {
@@ -36,11 +39,23 @@ type fakeDisk struct {
 	tags map[string]string
 }
 
+type fakeSnapshot struct {
+	*Snapshot
+	tags map[string]string
+}
+
 func NewFakeCloudProvider() *FakeCloudProvider {
 	return &FakeCloudProvider{
 		disks: make(map[string]*fakeDisk),
 		pub:   make(map[string]string),
-		m:     &metadata{"instanceID", "region", "az"},
+		m: &metadata{
+			instanceID:             "instanceID",
+			instanceType:           "m5.large",
+			region:                 "region",
+			availabilityZone:       "az",
+			numAttachedENIs:        1,
+			numBlockDeviceMappings: 1,
+		},
 	}
 }
 
@@ -52,8 +67,12 @@ func (c *FakeCloudProvider) CreateDisk(ctx context.Context, volumeName string, d
 	r1 := rand.New(rand.NewSource(time.Now().UnixNano()))
 	d := &fakeDisk{
 		Disk: &Disk{
-			VolumeID:    fmt.Sprintf("vol-%d", r1.Uint64()),
-			CapacityGiB: util.BytesToGiB(diskOptions.CapacityBytes),
+			VolumeID:         fmt.Sprintf("vol-%d", r1.Uint64()),
+			CapacityGiB:      util.BytesToGiB(diskOptions.CapacityBytes),
+			AvailabilityZone: diskOptions.AvailabilityZone,
+			SnapshotID:       diskOptions.SnapshotID,
+			Encrypted:        diskOptions.Encrypted,
+			KmsKeyID:         diskOptions.KmsKeyID,
 		},
 		tags: diskOptions.Tags,
 	}
@@ -111,9 +130,138 @@ func (c *FakeCloudProvider) GetDiskByID(ctx context.Context, volumeID string) (*
 	return nil, ErrNotFound
 }
 
-func (c *FakeCloudProvider) IsExistInstance(ctx context.Context, nodeID string) bool {
-	if nodeID != c.m.GetInstanceID() {
-		return false
+func (c *FakeCloudProvider) ListDisks(ctx context.Context, maxResults int64, nextToken string) (*ListDisksResponse, error) {
+	var disks []*Disk
+	for _, f := range c.disks {
+		disks = append(disks, f.Disk)
+	}
+	// Disks are kept in a map, so they are sorted to get stable pages
+	sort.Slice(disks, func(i, j int) bool { return disks[i].VolumeID < disks[j].VolumeID })
+
+	start := 0
+	if len(nextToken) != 0 {
+		var err error
+		start, err = strconv.Atoi(nextToken)
+		if err != nil || start < 0 || start > len(disks) {
+			return nil, ErrInvalidNextToken
+		}
+	}
+	disks = disks[start:]
+
+	var next string
+	if maxResults > 0 && int64(len(disks)) > maxResults {
+		disks = disks[:maxResults]
+		next = strconv.Itoa(start + int(maxResults))
+	}
+
+	return &ListDisksResponse{
+		Disks:     disks,
+		NextToken: next,
+	}, nil
+}
+
+func (c *FakeCloudProvider) IsExistInstance(ctx context.Context, nodeID string) (bool, error) {
+	return nodeID == c.m.GetInstanceID(), nil
+}
+
+func (c *FakeCloudProvider) CreateSnapshot(ctx context.Context, volumeID string, snapshotOptions *SnapshotOptions) (*Snapshot, error) {
+	r1 := rand.New(rand.NewSource(time.Now().UnixNano()))
+	s := &fakeSnapshot{
+		Snapshot: &Snapshot{
+			SnapshotID:     fmt.Sprintf("snap-%d", r1.Uint64()),
+			SourceVolumeID: volumeID,
+			CreationTime:   time.Now(),
+			ReadyToUse:     true,
+		},
+		tags: snapshotOptions.Tags,
+	}
+	for _, d := range c.disks {
+		if d.Disk.VolumeID == volumeID {
+			s.Snapshot.SizeGiB = d.Disk.CapacityGiB
+		}
 	}
-	return true
+	c.snapshots = append(c.snapshots, s)
+	return s.Snapshot, nil
+}
+
+func (c *FakeCloudProvider) DeleteSnapshot(ctx context.Context, snapshotID string) (bool, error) {
+	for i, s := range c.snapshots {
+		if s.Snapshot.SnapshotID == snapshotID {
+			c.snapshots = append(c.snapshots[:i], c.snapshots[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, ErrNotFound
+}
+
+func (c *FakeCloudProvider) GetSnapshotByName(ctx context.Context, name string) (*Snapshot, error) {
+	var snapshots []*fakeSnapshot
+	for _, s := range c.snapshots {
+		if s.tags[SnapshotNameTagKey] == name {
+			snapshots = append(snapshots, s)
+		}
+	}
+	if len(snapshots) > 1 {
+		return nil, ErrMultiSnapshots
+	} else if len(snapshots) == 1 {
+		return snapshots[0].Snapshot, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (c *FakeCloudProvider) GetSnapshotByID(ctx context.Context, snapshotID string) (*Snapshot, error) {
+	for _, s := range c.snapshots {
+		if s.Snapshot.SnapshotID == snapshotID {
+			return s.Snapshot, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// ListSnapshots uses the index of the first snapshot to return as the pagination token.
+func (c *FakeCloudProvider) ListSnapshots(ctx context.Context, volumeID string, maxResults int64, nextToken string) (*ListSnapshotsResponse, error) {
+	var snapshots []*Snapshot
+	for _, s := range c.snapshots {
+		if len(volumeID) == 0 || s.Snapshot.SourceVolumeID == volumeID {
+			snapshots = append(snapshots, s.Snapshot)
+		}
+	}
+
+	start := 0
+	if len(nextToken) != 0 {
+		var err error
+		start, err = strconv.Atoi(nextToken)
+		if err != nil || start < 0 || start > len(snapshots) {
+			return nil, ErrInvalidNextToken
+		}
+	}
+	snapshots = snapshots[start:]
+
+	var next string
+	if maxResults > 0 && int64(len(snapshots)) > maxResults {
+		snapshots = snapshots[:maxResults]
+		next = strconv.Itoa(start + int(maxResults))
+	}
+
+	return &ListSnapshotsResponse{
+		Snapshots: snapshots,
+		NextToken: next,
+	}, nil
+}
+
+func (c *FakeCloudProvider) ResizeDisk(ctx context.Context, volumeID string, newSizeBytes int64) (int64, error) {
+	for _, f := range c.disks {
+		if f.Disk.VolumeID == volumeID {
+			newSizeGiB := util.BytesToGiB(util.RoundUpBytes(newSizeBytes))
+			if f.Disk.CapacityGiB < newSizeGiB {
+				f.Disk.CapacityGiB = newSizeGiB
+			}
+			return f.Disk.CapacityGiB, nil
+		}
+	}
+	return 0, ErrNotFound
+}
+
+func (c *FakeCloudProvider) GetAvailabilityZones(ctx context.Context) ([]string, error) {
+	return []string{c.m.GetAvailabilityZone()}, nil
 }
@@ -0,0 +1,62 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+var (
+	// ErrIMDSUnreachable is returned, wrapped with the details, when EC2 instance metadata
+	// can't be reached, such as outside of EC2.
+	ErrIMDSUnreachable = errors.New("EC2 instance metadata is unreachable")
+
+	// ErrIMDSTokenRejected is returned, wrapped with the details, when EC2 instance metadata
+	// requires a session token and rejects the request, such as when IMDSv2 is enforced and
+	// the token response can't reach a pod because of the hop limit.
+	ErrIMDSTokenRejected = errors.New("EC2 instance metadata session token was rejected")
+)
+
+// imdsError wraps an error of the metadata client of the SDK with ErrIMDSUnreachable or
+// ErrIMDSTokenRejected when it's one of them. The SDK fetches and refreshes IMDSv2 session
+// tokens itself, and falls back to IMDSv1 when they are not supported, so a rejected request
+// is the only sign of a token it couldn't get.
+func imdsError(err error) error {
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) {
+		switch {
+		case reqErr.StatusCode() == http.StatusUnauthorized, reqErr.StatusCode() == http.StatusForbidden:
+			return fmt.Errorf("%w: %v", ErrIMDSTokenRejected, err)
+		case reqErr.StatusCode() >= http.StatusInternalServerError:
+			return fmt.Errorf("%w: %v", ErrIMDSUnreachable, err)
+		}
+	}
+
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		switch awsErr.Code() {
+		case request.ErrCodeRequestError, request.ErrCodeResponseTimeout, request.CanceledErrorCode:
+			return fmt.Errorf("%w: %v", ErrIMDSUnreachable, err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// fakeIMDS is a stand-in for EC2 instance metadata.
+type fakeIMDS struct {
+	// tokenStatus is the status of token requests, which issue a token when it's 200
+	tokenStatus int
+	// requireToken rejects the requests without the token, as with IMDSv2 enforced
+	requireToken bool
+	// status overrides the status of the metadata requests when it's set
+	status int
+}
+
+func (f *fakeIMDS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/latest/api/token" {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if f.tokenStatus != http.StatusOK {
+			w.WriteHeader(f.tokenStatus)
+			return
+		}
+		w.Header().Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+		fmt.Fprint(w, "token")
+		return
+	}
+
+	if f.requireToken && r.Header.Get("X-aws-ec2-metadata-token") != "token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+		return
+	}
+	switch r.URL.Path {
+	case "/latest/meta-data/instance-id":
+		fmt.Fprint(w, stdInstanceID)
+	case "/latest/dynamic/instance-identity/document":
+		fmt.Fprintf(w, `{"instanceId": %q, "instanceType": %q, "region": %q, "availabilityZone": %q}`,
+			stdInstanceID, stdInstanceType, stdRegion, stdAvailabilityZone)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestIMDSError(t *testing.T) {
+	testCases := []struct {
+		name        string
+		imds        *fakeIMDS
+		closeServer bool
+		expInstance string
+		expErr      error
+	}{
+		{
+			name:        "success: IMDSv2 enforced",
+			imds:        &fakeIMDS{tokenStatus: http.StatusOK, requireToken: true},
+			expInstance: stdInstanceID,
+		},
+		{
+			name:        "success: IMDSv1 fallback",
+			imds:        &fakeIMDS{tokenStatus: http.StatusNotFound},
+			expInstance: stdInstanceID,
+		},
+		{
+			name:   "fail: token request forbidden",
+			imds:   &fakeIMDS{tokenStatus: http.StatusForbidden, requireToken: true},
+			expErr: ErrIMDSTokenRejected,
+		},
+		{
+			name:   "fail: instance metadata failing",
+			imds:   &fakeIMDS{tokenStatus: http.StatusOK, status: http.StatusServiceUnavailable},
+			expErr: ErrIMDSUnreachable,
+		},
+		{
+			name:        "fail: instance metadata unreachable",
+			imds:        &fakeIMDS{tokenStatus: http.StatusOK},
+			closeServer: true,
+			expErr:      ErrIMDSUnreachable,
+		},
+		{
+			name:   "fail: other errors are not classified",
+			imds:   &fakeIMDS{tokenStatus: http.StatusOK, status: http.StatusNotFound},
+			expErr: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(tc.imds)
+			defer server.Close()
+			if tc.closeServer {
+				server.Close()
+			}
+
+			sess, err := session.NewSession(&aws.Config{Region: aws.String(stdRegion)})
+			if err != nil {
+				t.Fatalf("Could not create session: %v", err)
+			}
+			svc := ec2metadata.New(sess, &aws.Config{
+				Endpoint:   aws.String(server.URL),
+				HTTPClient: &http.Client{Timeout: time.Second},
+				MaxRetries: aws.Int(0),
+			})
+
+			instanceID, err := svc.GetMetadata("instance-id")
+			if tc.expInstance != "" {
+				if err != nil {
+					t.Fatalf("GetMetadata() failed: expected no error, got: %v", err)
+				}
+				if instanceID != tc.expInstance {
+					t.Fatalf("GetMetadata() failed: expected %q, got %q", tc.expInstance, instanceID)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("GetMetadata() failed: expected error, got nothing")
+			}
+
+			err = imdsError(err)
+			if tc.expErr != nil && !errors.Is(err, tc.expErr) {
+				t.Fatalf("imdsError() failed: expected error %q, got: %v", tc.expErr, err)
+			}
+			if tc.expErr == nil && (errors.Is(err, ErrIMDSUnreachable) || errors.Is(err, ErrIMDSTokenRejected)) {
+				t.Fatalf("imdsError() failed: expected error to be left as is, got: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// serviceAccountDir holds the credentials of the service account of the pod.
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+	// kubernetesRequestTimeout bounds the time taken to get the node from the API server.
+	kubernetesRequestTimeout = 30 * time.Second
+
+	awsProviderIDPrefix = "aws://"
+)
+
+// Labels of Kubernetes nodes, with the deprecated label following the label replacing it.
+var (
+	regionLabels       = []string{"topology.kubernetes.io/region", "failure-domain.beta.kubernetes.io/region"}
+	zoneLabels         = []string{"topology.kubernetes.io/zone", "failure-domain.beta.kubernetes.io/zone"}
+	instanceTypeLabels = []string{"node.kubernetes.io/instance-type", "beta.kubernetes.io/instance-type"}
+)
+
+// kubernetesNode holds the fields of a Kubernetes Node object the metadata is read from.
+type kubernetesNode struct {
+	Metadata struct {
+		Name   string            `json:"name"`
+		Labels map[string]string `json:"labels"`
+	} `json:"metadata"`
+	Spec struct {
+		// ProviderID is aws:///<zone>/<instance ID> for EC2 instances.
+		ProviderID string `json:"providerID"`
+	} `json:"spec"`
+}
+
+// kubernetesClient gets objects from the Kubernetes API server.
+type kubernetesClient struct {
+	host   string
+	token  string
+	client *http.Client
+}
+
+// newInClusterKubernetesClient returns a client authenticated with the service account of the
+// pod it runs in.
+func newInClusterKubernetesClient() (*kubernetesClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a Kubernetes cluster: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+	}
+
+	token, err := ioutil.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("could not read service account token: %v", err)
+	}
+	ca, err := ioutil.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("could not read service account CA certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("could not parse service account CA certificate")
+	}
+
+	return &kubernetesClient{
+		host:  "https://" + net.JoinHostPort(host, port),
+		token: strings.TrimSpace(string(token)),
+		client: &http.Client{
+			Timeout: kubernetesRequestTimeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{RootCAs: pool},
+			},
+		},
+	}, nil
+}
+
+// getNode returns the node with the given name.
+func (c *kubernetesClient) getNode(ctx context.Context, name string) (*kubernetesNode, error) {
+	req, err := http.NewRequest(http.MethodGet, c.host+"/api/v1/nodes/"+url.PathEscape(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not get node %q: %v", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("could not get node %q: %s: %s", name, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	node := &kubernetesNode{}
+	if err := json.NewDecoder(resp.Body).Decode(node); err != nil {
+		return nil, fmt.Errorf("could not decode node %q: %v", name, err)
+	}
+	return node, nil
+}
+
+// NewKubernetesMetadataService returns the metadata of the instance of a Kubernetes node, read
+// from its provider ID and its labels.
+func NewKubernetesMetadataService(nodeName string) (MetadataService, error) {
+	client, err := newInClusterKubernetesClient()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kubernetesRequestTimeout)
+	defer cancel()
+	node, err := client.getNode(ctx, nodeName)
+	if err != nil {
+		return nil, err
+	}
+	return newNodeMetadata(node)
+}
+
+// newNodeMetadata returns the metadata of the instance of a node. The zone and the region are
+// read from the labels of the node, or else derived from its provider ID.
+func newNodeMetadata(node *kubernetesNode) (*metadata, error) {
+	providerZone, instanceID, err := parseProviderID(node.Spec.ProviderID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get instance of node %q: %v", node.Metadata.Name, err)
+	}
+
+	zone := nodeLabel(node, zoneLabels)
+	if zone == "" {
+		zone = providerZone
+	}
+	if zone == "" {
+		return nil, fmt.Errorf("could not get availability zone of node %q", node.Metadata.Name)
+	}
+
+	region := nodeLabel(node, regionLabels)
+	if region == "" {
+		region = regionFromZone(zone)
+	}
+	if region == "" {
+		return nil, fmt.Errorf("could not get region of node %q", node.Metadata.Name)
+	}
+
+	// The instance always has at least one network interface and a root volume
+	return &metadata{
+		instanceID:             instanceID,
+		instanceType:           nodeLabel(node, instanceTypeLabels),
+		region:                 region,
+		availabilityZone:       zone,
+		numAttachedENIs:        1,
+		numBlockDeviceMappings: 1,
+	}, nil
+}
+
+// nodeLabel returns the value of the first of the labels the node has.
+func nodeLabel(node *kubernetesNode, labels []string) string {
+	for _, label := range labels {
+		if value := node.Metadata.Labels[label]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// parseProviderID returns the zone, which may be empty, and the instance ID of an AWS provider
+// ID, such as aws:///us-west-2a/i-0123456789abcdef0.
+func parseProviderID(providerID string) (zone, instanceID string, err error) {
+	if !strings.HasPrefix(providerID, awsProviderIDPrefix) {
+		return "", "", fmt.Errorf("invalid AWS provider ID %q", providerID)
+	}
+	parts := strings.Split(strings.TrimPrefix(providerID, awsProviderIDPrefix), "/")
+	instanceID = parts[len(parts)-1]
+	if instanceID == "" {
+		return "", "", fmt.Errorf("invalid AWS provider ID %q", providerID)
+	}
+	if len(parts) >= 2 {
+		zone = parts[len(parts)-2]
+	}
+	return zone, instanceID, nil
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewNodeMetadata(t *testing.T) {
+	testCases := []struct {
+		name            string
+		providerID      string
+		labels          map[string]string
+		expInstanceID   string
+		expInstanceType string
+		expRegion       string
+		expZone         string
+		expErr          bool
+	}{
+		{
+			name:       "success: labels",
+			providerID: "aws:///us-west-2a/i-0123456789abcdef0",
+			labels: map[string]string{
+				"topology.kubernetes.io/region":    "us-west-2",
+				"topology.kubernetes.io/zone":      "us-west-2b",
+				"node.kubernetes.io/instance-type": "m5.large",
+			},
+			expInstanceID:   "i-0123456789abcdef0",
+			expInstanceType: "m5.large",
+			expRegion:       "us-west-2",
+			expZone:         "us-west-2b",
+		},
+		{
+			name:       "success: deprecated labels",
+			providerID: "aws:///us-west-2a/i-0123456789abcdef0",
+			labels: map[string]string{
+				"failure-domain.beta.kubernetes.io/region": "us-west-2",
+				"failure-domain.beta.kubernetes.io/zone":   "us-west-2c",
+				"beta.kubernetes.io/instance-type":         "c5.xlarge",
+			},
+			expInstanceID:   "i-0123456789abcdef0",
+			expInstanceType: "c5.xlarge",
+			expRegion:       "us-west-2",
+			expZone:         "us-west-2c",
+		},
+		{
+			name:          "success: provider ID only",
+			providerID:    "aws:///us-west-2a/i-0123456789abcdef0",
+			expInstanceID: "i-0123456789abcdef0",
+			expRegion:     "us-west-2",
+			expZone:       "us-west-2a",
+		},
+		{
+			name:       "success: provider ID without zone",
+			providerID: "aws:////i-0123456789abcdef0",
+			labels: map[string]string{
+				"topology.kubernetes.io/zone": "us-west-2b",
+			},
+			expInstanceID: "i-0123456789abcdef0",
+			expRegion:     "us-west-2",
+			expZone:       "us-west-2b",
+		},
+		{
+			name:       "fail: no zone",
+			providerID: "aws:////i-0123456789abcdef0",
+			expErr:     true,
+		},
+		{
+			name:       "fail: not an AWS provider ID",
+			providerID: "gce://project/us-central1-a/instance-1",
+			expErr:     true,
+		},
+		{
+			name:       "fail: no instance ID",
+			providerID: "aws:///us-west-2a/",
+			expErr:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			node := &kubernetesNode{}
+			node.Metadata.Name = "node-1"
+			node.Metadata.Labels = tc.labels
+			node.Spec.ProviderID = tc.providerID
+
+			m, err := newNodeMetadata(node)
+			if err != nil {
+				if !tc.expErr {
+					t.Fatalf("newNodeMetadata() failed: expected no error, got %v", err)
+				}
+				return
+			}
+			if tc.expErr {
+				t.Fatal("newNodeMetadata() failed: expected error, got nothing")
+			}
+			if m.GetInstanceID() != tc.expInstanceID {
+				t.Fatalf("GetInstanceID() failed: expected %v, got %v", tc.expInstanceID, m.GetInstanceID())
+			}
+			if m.GetInstanceType() != tc.expInstanceType {
+				t.Fatalf("GetInstanceType() failed: expected %v, got %v", tc.expInstanceType, m.GetInstanceType())
+			}
+			if m.GetRegion() != tc.expRegion {
+				t.Fatalf("GetRegion() failed: expected %v, got %v", tc.expRegion, m.GetRegion())
+			}
+			if m.GetAvailabilityZone() != tc.expZone {
+				t.Fatalf("GetAvailabilityZone() failed: expected %v, got %v", tc.expZone, m.GetAvailabilityZone())
+			}
+		})
+	}
+}
+
+func TestKubernetesClientGetNode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			http.Error(w, `{"kind":"Status","reason":"Unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/v1/nodes/node-1" {
+			http.Error(w, `{"kind":"Status","reason":"NotFound"}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"kind": "Node",
+			"metadata": {"name": "node-1", "labels": {"topology.kubernetes.io/zone": "us-west-2b"}},
+			"spec": {"providerID": "aws:///us-west-2b/i-0123456789abcdef0"}
+		}`))
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name     string
+		nodeName string
+		token    string
+		expErr   bool
+	}{
+		{
+			name:     "success: normal",
+			nodeName: "node-1",
+			token:    "test-token",
+		},
+		{
+			name:     "fail: node not found",
+			nodeName: "node-2",
+			token:    "test-token",
+			expErr:   true,
+		},
+		{
+			name:     "fail: unauthorized",
+			nodeName: "node-1",
+			token:    "other-token",
+			expErr:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &kubernetesClient{host: server.URL, token: tc.token, client: server.Client()}
+			node, err := c.getNode(context.Background(), tc.nodeName)
+			if err != nil {
+				if !tc.expErr {
+					t.Fatalf("getNode() failed: expected no error, got %v", err)
+				}
+				return
+			}
+			if tc.expErr {
+				t.Fatal("getNode() failed: expected error, got nothing")
+			}
+			if node.Metadata.Name != tc.nodeName {
+				t.Fatalf("getNode() failed: expected node %q, got %q", tc.nodeName, node.Metadata.Name)
+			}
+			if node.Spec.ProviderID != "aws:///us-west-2b/i-0123456789abcdef0" {
+				t.Fatalf("getNode() failed: unexpected provider ID %q", node.Spec.ProviderID)
+			}
+			if zone := node.Metadata.Labels["topology.kubernetes.io/zone"]; zone != "us-west-2b" {
+				t.Fatalf("getNode() failed: unexpected zone label %q", zone)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import "strings"
+
+const (
+	// nitroMaxAttachments is the number of attachments shared by network interfaces,
+	// EBS volumes and NVMe instance store volumes on Nitro instances.
+	nitroMaxAttachments = 28
+
+	// defaultMaxEBSAttachments is the number of EBS volumes that can safely be attached
+	// to other instances. Linux supports more, but it can cause boot failures.
+	defaultMaxEBSAttachments = 39
+)
+
+// nitroInstanceFamilies are the instance families built on the Nitro system.
+var nitroInstanceFamilies = map[string]bool{
+	"a1": true, "c5": true, "c5a": true, "c5ad": true, "c5d": true, "c5n": true,
+	"c6g": true, "c6gd": true, "c6gn": true, "c6i": true, "d3": true, "d3en": true,
+	"g4ad": true, "g4dn": true, "g5": true, "i3en": true, "inf1": true, "m5": true,
+	"m5a": true, "m5ad": true, "m5d": true, "m5dn": true, "m5n": true, "m5zn": true,
+	"m6a": true, "m6g": true, "m6gd": true, "m6i": true, "p3dn": true, "p4d": true,
+	"r5": true, "r5a": true, "r5ad": true, "r5b": true, "r5d": true, "r5dn": true,
+	"r5n": true, "r6g": true, "r6gd": true, "r6i": true, "t3": true, "t3a": true,
+	"t4g": true, "x2gd": true, "z1d": true,
+}
+
+// nitroInstanceTypes are the instance types built on the Nitro system
+// whose family is not, like the bare metal instances of older families.
+var nitroInstanceTypes = map[string]bool{
+	"i3.metal": true,
+}
+
+// isNitroInstanceType returns whether the instance type is built on the Nitro system.
+func isNitroInstanceType(instanceType string) bool {
+	if nitroInstanceTypes[instanceType] {
+		return true
+	}
+	family := strings.SplitN(instanceType, ".", 2)[0]
+	// High memory instances are all bare metal Nitro instances
+	return nitroInstanceFamilies[family] || strings.HasPrefix(family, "u-")
+}
+
+// nvmeInstanceStoreVolumes are the numbers of NVMe instance store volumes of the Nitro instance
+// types that come with some. They are attached whether or not they are used.
+var nvmeInstanceStoreVolumes = map[string]int{
+	"c5ad.large": 1, "c5ad.xlarge": 1, "c5ad.2xlarge": 1, "c5ad.4xlarge": 2, "c5ad.8xlarge": 2, "c5ad.12xlarge": 2, "c5ad.16xlarge": 2, "c5ad.24xlarge": 2,
+	"c5d.large": 1, "c5d.xlarge": 1, "c5d.2xlarge": 1, "c5d.4xlarge": 1, "c5d.9xlarge": 1, "c5d.12xlarge": 2, "c5d.18xlarge": 2, "c5d.24xlarge": 4, "c5d.metal": 4,
+	"c6gd.medium": 1, "c6gd.large": 1, "c6gd.xlarge": 1, "c6gd.2xlarge": 1, "c6gd.4xlarge": 1, "c6gd.8xlarge": 1, "c6gd.12xlarge": 2, "c6gd.16xlarge": 2, "c6gd.metal": 2,
+	"d3.xlarge": 3, "d3.2xlarge": 6, "d3.4xlarge": 12, "d3.8xlarge": 24,
+	"d3en.xlarge": 2, "d3en.2xlarge": 4, "d3en.4xlarge": 8, "d3en.6xlarge": 12, "d3en.8xlarge": 16, "d3en.12xlarge": 24,
+	"g4ad.xlarge": 1, "g4ad.2xlarge": 1, "g4ad.4xlarge": 1, "g4ad.8xlarge": 1, "g4ad.16xlarge": 2,
+	"g4dn.xlarge": 1, "g4dn.2xlarge": 1, "g4dn.4xlarge": 1, "g4dn.8xlarge": 1, "g4dn.12xlarge": 1, "g4dn.16xlarge": 1, "g4dn.metal": 2,
+	"g5.xlarge": 1, "g5.2xlarge": 1, "g5.4xlarge": 1, "g5.8xlarge": 1, "g5.12xlarge": 1, "g5.16xlarge": 1, "g5.24xlarge": 1, "g5.48xlarge": 2,
+	"i3.metal": 8, "i3en.large": 1, "i3en.xlarge": 1, "i3en.2xlarge": 2, "i3en.3xlarge": 1, "i3en.6xlarge": 2, "i3en.12xlarge": 4, "i3en.24xlarge": 8, "i3en.metal": 8,
+	"m5ad.large": 1, "m5ad.xlarge": 1, "m5ad.2xlarge": 1, "m5ad.4xlarge": 2, "m5ad.8xlarge": 2, "m5ad.12xlarge": 2, "m5ad.16xlarge": 4, "m5ad.24xlarge": 4,
+	"m5d.large": 1, "m5d.xlarge": 1, "m5d.2xlarge": 1, "m5d.4xlarge": 2, "m5d.8xlarge": 2, "m5d.12xlarge": 2, "m5d.16xlarge": 4, "m5d.24xlarge": 4, "m5d.metal": 4,
+	"m5dn.large": 1, "m5dn.xlarge": 1, "m5dn.2xlarge": 1, "m5dn.4xlarge": 2, "m5dn.8xlarge": 2, "m5dn.12xlarge": 2, "m5dn.16xlarge": 4, "m5dn.24xlarge": 4, "m5dn.metal": 4,
+	"m6gd.medium": 1, "m6gd.large": 1, "m6gd.xlarge": 1, "m6gd.2xlarge": 1, "m6gd.4xlarge": 1, "m6gd.8xlarge": 1, "m6gd.12xlarge": 2, "m6gd.16xlarge": 2, "m6gd.metal": 2,
+	"p3dn.24xlarge": 2, "p4d.24xlarge": 8,
+	"r5ad.large": 1, "r5ad.xlarge": 1, "r5ad.2xlarge": 1, "r5ad.4xlarge": 2, "r5ad.8xlarge": 2, "r5ad.12xlarge": 2, "r5ad.16xlarge": 4, "r5ad.24xlarge": 4,
+	"r5d.large": 1, "r5d.xlarge": 1, "r5d.2xlarge": 1, "r5d.4xlarge": 2, "r5d.8xlarge": 2, "r5d.12xlarge": 2, "r5d.16xlarge": 4, "r5d.24xlarge": 4, "r5d.metal": 4,
+	"r5dn.large": 1, "r5dn.xlarge": 1, "r5dn.2xlarge": 1, "r5dn.4xlarge": 2, "r5dn.8xlarge": 2, "r5dn.12xlarge": 2, "r5dn.16xlarge": 4, "r5dn.24xlarge": 4, "r5dn.metal": 4,
+	"r6gd.medium": 1, "r6gd.large": 1, "r6gd.xlarge": 1, "r6gd.2xlarge": 1, "r6gd.4xlarge": 1, "r6gd.8xlarge": 1, "r6gd.12xlarge": 2, "r6gd.16xlarge": 2, "r6gd.metal": 2,
+	"x2gd.medium": 1, "x2gd.large": 1, "x2gd.xlarge": 1, "x2gd.2xlarge": 1, "x2gd.4xlarge": 1, "x2gd.8xlarge": 1, "x2gd.12xlarge": 2, "x2gd.16xlarge": 2, "x2gd.metal": 2,
+	"z1d.large": 1, "z1d.xlarge": 1, "z1d.2xlarge": 1, "z1d.3xlarge": 1, "z1d.6xlarge": 1, "z1d.12xlarge": 2, "z1d.metal": 2,
+}
+
+// GetMaxEBSAttachments returns the number of EBS volumes, including the root volume,
+// that can be attached to an instance of the given type with numENIs network interfaces.
+// On Nitro instances the volumes share their attachment budget with the network interfaces
+// and the NVMe instance store volumes.
+//
+// This is an approximation: instance types missing from nvmeInstanceStoreVolumes are assumed
+// to have no NVMe instance store volume, and the Nitro budget is applied to all of them,
+// although some bare metal and recent instance types have different limits.
+func GetMaxEBSAttachments(instanceType string, numENIs int) int {
+	if isNitroInstanceType(instanceType) {
+		return nitroMaxAttachments - numENIs - nvmeInstanceStoreVolumes[instanceType]
+	}
+	return defaultMaxEBSAttachments
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import "testing"
+
+func TestGetMaxEBSAttachments(t *testing.T) {
+	testCases := []struct {
+		name         string
+		instanceType string
+		numENIs      int
+		expMax       int
+	}{
+		{
+			name:         "nitro instance",
+			instanceType: "m5.large",
+			numENIs:      1,
+			expMax:       27,
+		},
+		{
+			name:         "nitro instance with several network interfaces",
+			instanceType: "c5n.18xlarge",
+			numENIs:      4,
+			expMax:       24,
+		},
+		{
+			name:         "nitro instance with instance store volumes",
+			instanceType: "m5d.4xlarge",
+			numENIs:      1,
+			expMax:       25,
+		},
+		{
+			name:         "nitro instance with many instance store volumes",
+			instanceType: "d3en.12xlarge",
+			numENIs:      2,
+			expMax:       2,
+		},
+		{
+			name:         "nitro bare metal instance of a xen family",
+			instanceType: "i3.metal",
+			numENIs:      1,
+			expMax:       19,
+		},
+		{
+			name:         "high memory instance",
+			instanceType: "u-6tb1.metal",
+			numENIs:      1,
+			expMax:       27,
+		},
+		{
+			name:         "xen instance",
+			instanceType: "m4.large",
+			numENIs:      2,
+			expMax:       39,
+		},
+		{
+			name:         "unknown instance type",
+			instanceType: "",
+			numENIs:      1,
+			expMax:       39,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		if max := GetMaxEBSAttachments(tc.instanceType, tc.numENIs); max != tc.expMax {
+			t.Fatalf("GetMaxEBSAttachments() failed: expected %d, got %d", tc.expMax, max)
+		}
+	}
+}
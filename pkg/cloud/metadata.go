@@ -17,27 +17,38 @@ limitations under the License.
 package cloud
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/golang/glog"
 )
 
 type EC2Metadata interface {
 	Available() bool
 	GetInstanceIdentityDocument() (ec2metadata.EC2InstanceIdentityDocument, error)
+	GetMetadata(p string) (string, error)
 }
 
 // MetadataService represents AWS metadata service.
 type MetadataService interface {
 	GetInstanceID() string
+	GetInstanceType() string
 	GetRegion() string
 	GetAvailabilityZone() string
+	GetNumAttachedENIs() int
+	GetNumBlockDeviceMappings() int
 }
 
 type metadata struct {
-	instanceID       string
-	region           string
-	availabilityZone string
+	instanceID             string
+	instanceType           string
+	region                 string
+	availabilityZone       string
+	numAttachedENIs        int
+	numBlockDeviceMappings int
 }
 
 var _ MetadataService = &metadata{}
@@ -47,6 +58,11 @@ func (m *metadata) GetInstanceID() string {
 	return m.instanceID
 }
 
+// GetInstanceType returns the type of the instance, or an empty string if it's unknown.
+func (m *metadata) GetInstanceType() string {
+	return m.instanceType
+}
+
 // GetRegion returns the region Zone which the instance is in.
 func (m *metadata) GetRegion() string {
 	return m.region
@@ -57,15 +73,97 @@ func (m *metadata) GetAvailabilityZone() string {
 	return m.availabilityZone
 }
 
+// GetNumAttachedENIs returns the number of network interfaces attached to the instance.
+func (m *metadata) GetNumAttachedENIs() int {
+	return m.numAttachedENIs
+}
+
+// GetNumBlockDeviceMappings returns the number of EBS volumes the instance was launched with,
+// including its root volume. Volumes attached afterwards are not included.
+func (m *metadata) GetNumBlockDeviceMappings() int {
+	return m.numBlockDeviceMappings
+}
+
+const (
+	// RegionEnvVar, AvailabilityZoneEnvVar, InstanceIDEnvVar and InstanceTypeEnvVar are the
+	// environment variables the metadata is read from when EC2 instance metadata is not available.
+	RegionEnvVar           = "AWS_REGION"
+	AvailabilityZoneEnvVar = "AWS_AVAILABILITY_ZONE"
+	InstanceIDEnvVar       = "AWS_INSTANCE_ID"
+	InstanceTypeEnvVar     = "AWS_INSTANCE_TYPE"
+
+	// NodeNameEnvVar is the environment variable holding the name of the Kubernetes node the
+	// metadata is read from when EC2 instance metadata is not available.
+	NodeNameEnvVar = "KUBE_NODE_NAME"
+)
+
+// DiscoverMetadataService returns the metadata of EC2 instance metadata. When instance metadata
+// is unreachable, such as outside of EC2 or from a pod when the hop limit is 1, the metadata is
+// read from the Kubernetes node named by NodeNameEnvVar when it's set, or else from the
+// environment variables. Other errors, such as a rejected session token, are returned.
+func DiscoverMetadataService(svc EC2Metadata) (MetadataService, error) {
+	m, err := newEC2Metadata(svc)
+	if err == nil || !errors.Is(err, ErrIMDSUnreachable) {
+		return m, err
+	}
+
+	if nodeName := os.Getenv(NodeNameEnvVar); nodeName != "" {
+		glog.Infof("%v, reading metadata from Kubernetes node %q", err, nodeName)
+		return NewKubernetesMetadataService(nodeName)
+	}
+	glog.Infof("%v, reading metadata from environment variables", err)
+	return NewEnvMetadataService()
+}
+
+// NewEnvMetadataService returns the metadata set by the environment variables. The region is
+// required, but can be derived from the availability zone. The other fields are empty when
+// their variable is not set, which is enough to run the controller.
+func NewEnvMetadataService() (MetadataService, error) {
+	zone := os.Getenv(AvailabilityZoneEnvVar)
+	region := os.Getenv(RegionEnvVar)
+	if region == "" {
+		region = regionFromZone(zone)
+	}
+	if region == "" {
+		return nil, fmt.Errorf("could not get region from environment variables %s or %s", RegionEnvVar, AvailabilityZoneEnvVar)
+	}
+
+	return &metadata{
+		instanceID:             os.Getenv(InstanceIDEnvVar),
+		instanceType:           os.Getenv(InstanceTypeEnvVar),
+		region:                 region,
+		availabilityZone:       zone,
+		numAttachedENIs:        1,
+		numBlockDeviceMappings: 1,
+	}, nil
+}
+
+// regionFromZone returns the region of a standard availability zone, such as us-west-2 for
+// us-west-2a, or an empty string if the zone is empty.
+func regionFromZone(zone string) string {
+	if len(zone) < 2 {
+		return ""
+	}
+	last := zone[len(zone)-1]
+	if last < 'a' || last > 'z' {
+		return ""
+	}
+	return zone[:len(zone)-1]
+}
+
 // NewMetadataService returns a new MetadataServiceImplementation.
 func NewMetadataService(svc EC2Metadata) (MetadataService, error) {
 	if !svc.Available() {
 		return nil, fmt.Errorf("EC2 instance metadata is not available")
 	}
+	return newEC2Metadata(svc)
+}
 
+// newEC2Metadata returns the metadata read from the available EC2 instance metadata.
+func newEC2Metadata(svc EC2Metadata) (MetadataService, error) {
 	doc, err := svc.GetInstanceIdentityDocument()
 	if err != nil {
-		return nil, fmt.Errorf("could not get EC2 instance identity metadata")
+		return nil, fmt.Errorf("could not get EC2 instance identity metadata: %w", imdsError(err))
 	}
 
 	if len(doc.InstanceID) == 0 {
@@ -80,9 +178,31 @@ func NewMetadataService(svc EC2Metadata) (MetadataService, error) {
 		return nil, fmt.Errorf("could not get valid EC2 availavility zone")
 	}
 
+	// The instance always has at least one network interface and a root volume
+	numAttachedENIs := 1
+	if macs, err := svc.GetMetadata("network/interfaces/macs/"); err != nil {
+		glog.Warningf("Could not get network interfaces from EC2 instance metadata: %v", err)
+	} else if n := len(strings.Fields(macs)); n > 0 {
+		numAttachedENIs = n
+	}
+
+	numBlockDeviceMappings := 1
+	if mappings, err := svc.GetMetadata("block-device-mapping/"); err != nil {
+		glog.Warningf("Could not get block device mappings from EC2 instance metadata: %v", err)
+	} else {
+		for _, mapping := range strings.Fields(mappings) {
+			if strings.HasPrefix(mapping, "ebs") {
+				numBlockDeviceMappings++
+			}
+		}
+	}
+
 	return &metadata{
-		instanceID:       doc.InstanceID,
-		region:           doc.Region,
-		availabilityZone: doc.AvailabilityZone,
+		instanceID:             doc.InstanceID,
+		instanceType:           doc.InstanceType,
+		region:                 doc.Region,
+		availabilityZone:       doc.AvailabilityZone,
+		numAttachedENIs:        numAttachedENIs,
+		numBlockDeviceMappings: numBlockDeviceMappings,
 	}, nil
 }
@@ -17,16 +17,21 @@ limitations under the License.
 package cloud
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/golang/mock/gomock"
 	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/cloud/mocks"
 )
 
 var (
 	stdInstanceID       = "instance-1"
+	stdInstanceType     = "m5.large"
 	stdRegion           = "instance-1"
 	stdAvailabilityZone = "az-1"
 )
@@ -37,6 +42,11 @@ func TestNewMetadataService(t *testing.T) {
 		isAvailable      bool
 		isPartial        bool
 		identityDocument ec2metadata.EC2InstanceIdentityDocument
+		macs             string
+		mappings         string
+		metadataErr      error
+		expENIs          int
+		expMappings      int
 		err              error
 	}{
 		{
@@ -44,10 +54,29 @@ func TestNewMetadataService(t *testing.T) {
 			isAvailable: true,
 			identityDocument: ec2metadata.EC2InstanceIdentityDocument{
 				InstanceID:       stdInstanceID,
+				InstanceType:     stdInstanceType,
 				Region:           stdRegion,
 				AvailabilityZone: stdAvailabilityZone,
 			},
-			err: nil,
+			macs:        "0e:00:00:00:00:01/\n0e:00:00:00:00:02/",
+			mappings:    "ami\nebs1\nebs2\nephemeral0\nroot",
+			expENIs:     2,
+			expMappings: 3,
+			err:         nil,
+		},
+		{
+			name:        "success: interfaces and mappings not available",
+			isAvailable: true,
+			identityDocument: ec2metadata.EC2InstanceIdentityDocument{
+				InstanceID:       stdInstanceID,
+				InstanceType:     stdInstanceType,
+				Region:           stdRegion,
+				AvailabilityZone: stdAvailabilityZone,
+			},
+			metadataErr: fmt.Errorf("404 Not Found"),
+			expENIs:     1,
+			expMappings: 1,
+			err:         nil,
 		},
 		{
 			name:        "fail: metadata not available",
@@ -113,6 +142,10 @@ func TestNewMetadataService(t *testing.T) {
 		if tc.isAvailable {
 			mockEC2Metadata.EXPECT().GetInstanceIdentityDocument().Return(tc.identityDocument, tc.err)
 		}
+		if tc.isAvailable && tc.err == nil && !tc.isPartial {
+			mockEC2Metadata.EXPECT().GetMetadata("network/interfaces/macs/").Return(tc.macs, tc.metadataErr)
+			mockEC2Metadata.EXPECT().GetMetadata("block-device-mapping/").Return(tc.mappings, tc.metadataErr)
+		}
 
 		m, err := NewMetadataService(mockEC2Metadata)
 		if tc.isAvailable && tc.err == nil && !tc.isPartial {
@@ -124,6 +157,18 @@ func TestNewMetadataService(t *testing.T) {
 				t.Fatalf("GetInstanceID() failed: expected %v, got %v", tc.identityDocument.InstanceID, m.GetInstanceID())
 			}
 
+			if m.GetInstanceType() != tc.identityDocument.InstanceType {
+				t.Fatalf("GetInstanceType() failed: expected %v, got %v", tc.identityDocument.InstanceType, m.GetInstanceType())
+			}
+
+			if m.GetNumAttachedENIs() != tc.expENIs {
+				t.Fatalf("GetNumAttachedENIs() failed: expected %v, got %v", tc.expENIs, m.GetNumAttachedENIs())
+			}
+
+			if m.GetNumBlockDeviceMappings() != tc.expMappings {
+				t.Fatalf("GetNumBlockDeviceMappings() failed: expected %v, got %v", tc.expMappings, m.GetNumBlockDeviceMappings())
+			}
+
 			if m.GetRegion() != tc.identityDocument.Region {
 				t.Fatalf("GetRegion() failed: expected %v, got %v", tc.identityDocument.Region, m.GetRegion())
 			}
@@ -140,3 +185,153 @@ func TestNewMetadataService(t *testing.T) {
 		mockCtrl.Finish()
 	}
 }
+
+func TestNewEnvMetadataService(t *testing.T) {
+	testCases := []struct {
+		name      string
+		env       map[string]string
+		expRegion string
+		expZone   string
+		expErr    bool
+	}{
+		{
+			name: "success: all variables",
+			env: map[string]string{
+				RegionEnvVar:           "us-west-2",
+				AvailabilityZoneEnvVar: "us-west-2b",
+				InstanceIDEnvVar:       stdInstanceID,
+				InstanceTypeEnvVar:     stdInstanceType,
+			},
+			expRegion: "us-west-2",
+			expZone:   "us-west-2b",
+		},
+		{
+			name:      "success: region only",
+			env:       map[string]string{RegionEnvVar: "us-west-2"},
+			expRegion: "us-west-2",
+		},
+		{
+			name:      "success: region derived from zone",
+			env:       map[string]string{AvailabilityZoneEnvVar: "us-west-2b"},
+			expRegion: "us-west-2",
+			expZone:   "us-west-2b",
+		},
+		{
+			name:   "fail: no region",
+			env:    map[string]string{InstanceIDEnvVar: stdInstanceID},
+			expErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, key := range []string{RegionEnvVar, AvailabilityZoneEnvVar, InstanceIDEnvVar, InstanceTypeEnvVar} {
+				t.Setenv(key, tc.env[key])
+			}
+
+			m, err := NewEnvMetadataService()
+			if err != nil {
+				if !tc.expErr {
+					t.Fatalf("NewEnvMetadataService() failed: expected no error, got %v", err)
+				}
+				return
+			}
+			if tc.expErr {
+				t.Fatal("NewEnvMetadataService() failed: expected error, got nothing")
+			}
+			if m.GetRegion() != tc.expRegion {
+				t.Fatalf("GetRegion() failed: expected %v, got %v", tc.expRegion, m.GetRegion())
+			}
+			if m.GetAvailabilityZone() != tc.expZone {
+				t.Fatalf("GetAvailabilityZone() failed: expected %v, got %v", tc.expZone, m.GetAvailabilityZone())
+			}
+			if m.GetInstanceID() != tc.env[InstanceIDEnvVar] {
+				t.Fatalf("GetInstanceID() failed: expected %v, got %v", tc.env[InstanceIDEnvVar], m.GetInstanceID())
+			}
+			if m.GetInstanceType() != tc.env[InstanceTypeEnvVar] {
+				t.Fatalf("GetInstanceType() failed: expected %v, got %v", tc.env[InstanceTypeEnvVar], m.GetInstanceType())
+			}
+		})
+	}
+}
+
+func TestDiscoverMetadataService(t *testing.T) {
+	unreachableErr := awserr.New(request.ErrCodeRequestError, "send request failed", errors.New("dial tcp 169.254.169.254:80: i/o timeout"))
+
+	testCases := []struct {
+		name      string
+		imdsErr   error
+		nodeName  string
+		region    string
+		expRegion string
+		expErr    error
+	}{
+		{
+			name:      "success: EC2 instance metadata",
+			nodeName:  "node-1",
+			region:    "us-east-1",
+			expRegion: stdRegion,
+		},
+		{
+			name:      "success: environment variables when unreachable",
+			imdsErr:   unreachableErr,
+			region:    "us-east-1",
+			expRegion: "us-east-1",
+		},
+		{
+			name:     "fail: Kubernetes node outside of a cluster",
+			imdsErr:  unreachableErr,
+			nodeName: "node-1",
+			region:   "us-east-1",
+			expErr:   errors.New("not running in a Kubernetes cluster"),
+		},
+		{
+			name:    "fail: unreachable without fallback",
+			imdsErr: unreachableErr,
+			expErr:  errors.New("could not get region"),
+		},
+		{
+			name:     "fail: token rejected is not a reason to fall back",
+			imdsErr:  awserr.NewRequestFailure(awserr.New("EC2MetadataError", "failed to make EC2Metadata request", nil), 401, ""),
+			nodeName: "node-1",
+			region:   "us-east-1",
+			expErr:   ErrIMDSTokenRejected,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(NodeNameEnvVar, tc.nodeName)
+			t.Setenv(RegionEnvVar, tc.region)
+			t.Setenv(AvailabilityZoneEnvVar, "")
+			t.Setenv("KUBERNETES_SERVICE_HOST", "")
+
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockEC2Metadata := mocks.NewMockEC2Metadata(mockCtrl)
+			mockEC2Metadata.EXPECT().GetInstanceIdentityDocument().Return(ec2metadata.EC2InstanceIdentityDocument{
+				InstanceID:       stdInstanceID,
+				Region:           stdRegion,
+				AvailabilityZone: stdAvailabilityZone,
+			}, tc.imdsErr)
+			mockEC2Metadata.EXPECT().GetMetadata(gomock.Any()).Return("", nil).AnyTimes()
+
+			m, err := DiscoverMetadataService(mockEC2Metadata)
+			if err != nil {
+				if tc.expErr == nil {
+					t.Fatalf("DiscoverMetadataService() failed: expected no error, got %v", err)
+				}
+				if !errors.Is(err, tc.expErr) && !strings.Contains(err.Error(), tc.expErr.Error()) {
+					t.Fatalf("DiscoverMetadataService() failed: expected error %q, got %q", tc.expErr, err)
+				}
+				return
+			}
+			if tc.expErr != nil {
+				t.Fatalf("DiscoverMetadataService() failed: expected error %q, got nothing", tc.expErr)
+			}
+			if m.GetRegion() != tc.expRegion {
+				t.Fatalf("GetRegion() failed: expected %v, got %v", tc.expRegion, m.GetRegion())
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// durationBuckets are the buckets of the durations, in seconds, which range from the latency
+// of a request to the minutes taken for some volumes to be attached.
+var durationBuckets = prometheus.ExponentialBuckets(0.01, 2.5, 12)
+
+var (
+	// ec2RequestDuration observes the latency of the EC2 requests, each retry being observed
+	// on its own.
+	ec2RequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "aws_ebs_csi_ec2_request_duration_seconds",
+			Help:    "Latency of EC2 requests in seconds.",
+			Buckets: durationBuckets,
+		},
+		[]string{"request"},
+	)
+
+	// ec2RequestErrors counts the failed EC2 requests by AWS error code.
+	ec2RequestErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aws_ebs_csi_ec2_request_errors_total",
+			Help: "Number of failed EC2 requests.",
+		},
+		[]string{"request", "code"},
+	)
+
+	// throttledRequests counts the EC2 requests throttled by AWS, including the ones that
+	// succeeded once retried.
+	throttledRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aws_ebs_csi_ec2_throttled_requests_total",
+			Help: "Number of EC2 requests throttled by AWS.",
+		},
+		[]string{"request"},
+	)
+
+	// attachmentWaitDuration observes the time spent waiting for volumes to be attached or
+	// detached once requested, whether they reached the state in time or not.
+	attachmentWaitDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "aws_ebs_csi_attachment_wait_duration_seconds",
+			Help:    "Time spent waiting for volumes to be attached or detached in seconds.",
+			Buckets: durationBuckets,
+		},
+		[]string{"state"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(ec2RequestDuration, ec2RequestErrors, throttledRequests, attachmentWaitDuration)
+}
+
+// errorCode returns the AWS error code of err, or "Unknown" if it's not an AWS error.
+func errorCode(err error) string {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return awsErr.Code()
+	}
+	return "Unknown"
+}
+
+// instrumentedEC2 decorates EC2 to observe the latency and errors of the requests.
+type instrumentedEC2 struct {
+	ec2 EC2
+}
+
+var _ EC2 = &instrumentedEC2{}
+
+func newInstrumentedEC2(svc EC2) *instrumentedEC2 {
+	return &instrumentedEC2{ec2: svc}
+}
+
+// observe records the metrics of a request that started at start and failed with err, if any.
+func (c *instrumentedEC2) observe(name string, start time.Time, err error) {
+	ec2RequestDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		ec2RequestErrors.WithLabelValues(name, errorCode(err)).Inc()
+	}
+}
+
+func (c *instrumentedEC2) DescribeVolumesWithContext(ctx aws.Context, input *ec2.DescribeVolumesInput, opts ...request.Option) (*ec2.DescribeVolumesOutput, error) {
+	start := time.Now()
+	output, err := c.ec2.DescribeVolumesWithContext(ctx, input, opts...)
+	c.observe("DescribeVolumes", start, err)
+	return output, err
+}
+
+func (c *instrumentedEC2) CreateVolumeWithContext(ctx aws.Context, input *ec2.CreateVolumeInput, opts ...request.Option) (*ec2.Volume, error) {
+	start := time.Now()
+	output, err := c.ec2.CreateVolumeWithContext(ctx, input, opts...)
+	c.observe("CreateVolume", start, err)
+	return output, err
+}
+
+func (c *instrumentedEC2) DeleteVolumeWithContext(ctx aws.Context, input *ec2.DeleteVolumeInput, opts ...request.Option) (*ec2.DeleteVolumeOutput, error) {
+	start := time.Now()
+	output, err := c.ec2.DeleteVolumeWithContext(ctx, input, opts...)
+	c.observe("DeleteVolume", start, err)
+	return output, err
+}
+
+func (c *instrumentedEC2) DetachVolumeWithContext(ctx aws.Context, input *ec2.DetachVolumeInput, opts ...request.Option) (*ec2.VolumeAttachment, error) {
+	start := time.Now()
+	output, err := c.ec2.DetachVolumeWithContext(ctx, input, opts...)
+	c.observe("DetachVolume", start, err)
+	return output, err
+}
+
+func (c *instrumentedEC2) AttachVolumeWithContext(ctx aws.Context, input *ec2.AttachVolumeInput, opts ...request.Option) (*ec2.VolumeAttachment, error) {
+	start := time.Now()
+	output, err := c.ec2.AttachVolumeWithContext(ctx, input, opts...)
+	c.observe("AttachVolume", start, err)
+	return output, err
+}
+
+func (c *instrumentedEC2) DescribeInstancesWithContext(ctx aws.Context, input *ec2.DescribeInstancesInput, opts ...request.Option) (*ec2.DescribeInstancesOutput, error) {
+	start := time.Now()
+	output, err := c.ec2.DescribeInstancesWithContext(ctx, input, opts...)
+	c.observe("DescribeInstances", start, err)
+	return output, err
+}
+
+func (c *instrumentedEC2) CreateSnapshotWithContext(ctx aws.Context, input *ec2.CreateSnapshotInput, opts ...request.Option) (*ec2.Snapshot, error) {
+	start := time.Now()
+	output, err := c.ec2.CreateSnapshotWithContext(ctx, input, opts...)
+	c.observe("CreateSnapshot", start, err)
+	return output, err
+}
+
+func (c *instrumentedEC2) DeleteSnapshotWithContext(ctx aws.Context, input *ec2.DeleteSnapshotInput, opts ...request.Option) (*ec2.DeleteSnapshotOutput, error) {
+	start := time.Now()
+	output, err := c.ec2.DeleteSnapshotWithContext(ctx, input, opts...)
+	c.observe("DeleteSnapshot", start, err)
+	return output, err
+}
+
+func (c *instrumentedEC2) DescribeSnapshotsWithContext(ctx aws.Context, input *ec2.DescribeSnapshotsInput, opts ...request.Option) (*ec2.DescribeSnapshotsOutput, error) {
+	start := time.Now()
+	output, err := c.ec2.DescribeSnapshotsWithContext(ctx, input, opts...)
+	c.observe("DescribeSnapshots", start, err)
+	return output, err
+}
+
+func (c *instrumentedEC2) ModifyVolumeWithContext(ctx aws.Context, input *ec2.ModifyVolumeInput, opts ...request.Option) (*ec2.ModifyVolumeOutput, error) {
+	start := time.Now()
+	output, err := c.ec2.ModifyVolumeWithContext(ctx, input, opts...)
+	c.observe("ModifyVolume", start, err)
+	return output, err
+}
+
+func (c *instrumentedEC2) DescribeVolumesModificationsWithContext(ctx aws.Context, input *ec2.DescribeVolumesModificationsInput, opts ...request.Option) (*ec2.DescribeVolumesModificationsOutput, error) {
+	start := time.Now()
+	output, err := c.ec2.DescribeVolumesModificationsWithContext(ctx, input, opts...)
+	c.observe("DescribeVolumesModifications", start, err)
+	return output, err
+}
+
+func (c *instrumentedEC2) DescribeAvailabilityZonesWithContext(ctx aws.Context, input *ec2.DescribeAvailabilityZonesInput, opts ...request.Option) (*ec2.DescribeAvailabilityZonesOutput, error) {
+	start := time.Now()
+	output, err := c.ec2.DescribeAvailabilityZonesWithContext(ctx, input, opts...)
+	c.observe("DescribeAvailabilityZones", start, err)
+	return output, err
+}
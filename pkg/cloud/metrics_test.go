@@ -0,0 +1,81 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/golang/mock/gomock"
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/cloud/mocks"
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/testutil"
+)
+
+func TestInstrumentedEC2(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mockErr error
+		expCode string
+	}{
+		{
+			name: "success",
+		},
+		{
+			name:    "fail: AWS error",
+			mockErr: awserr.New("IncorrectState", "", nil),
+			expCode: "IncorrectState",
+		},
+		{
+			name:    "fail: other error",
+			mockErr: fmt.Errorf("AttachVolume generic error"),
+			expCode: "Unknown",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		mockCtrl := gomock.NewController(t)
+		mockEC2 := mocks.NewMockEC2(mockCtrl)
+		c := newInstrumentedEC2(mockEC2)
+
+		ctx := context.Background()
+		mockEC2.EXPECT().AttachVolumeWithContext(gomock.Eq(ctx), gomock.Any()).Return(&ec2.VolumeAttachment{}, tc.mockErr)
+
+		requestsBefore := testutil.HistogramCount(t, ec2RequestDuration, "AttachVolume")
+		var errorsBefore float64
+		if tc.expCode != "" {
+			errorsBefore = testutil.CounterValue(t, ec2RequestErrors, "AttachVolume", tc.expCode)
+		}
+		if _, err := c.AttachVolumeWithContext(ctx, &ec2.AttachVolumeInput{}); err != tc.mockErr {
+			t.Fatalf("AttachVolumeWithContext() failed: expected error %v, got: %v", tc.mockErr, err)
+		}
+
+		if requests := testutil.HistogramCount(t, ec2RequestDuration, "AttachVolume") - requestsBefore; requests != 1 {
+			t.Fatalf("Expected the latency of 1 request to be observed, got %d", requests)
+		}
+		if tc.expCode != "" {
+			if errs := testutil.CounterValue(t, ec2RequestErrors, "AttachVolume", tc.expCode) - errorsBefore; errs != 1 {
+				t.Fatalf("Expected 1 error with code %q to be counted, got %v", tc.expCode, errs)
+			}
+		}
+
+		mockCtrl.Finish()
+	}
+}
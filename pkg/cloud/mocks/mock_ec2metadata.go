@@ -57,3 +57,16 @@ func (m *MockEC2Metadata) GetInstanceIdentityDocument() (ec2metadata.EC2Instance
 func (mr *MockEC2MetadataMockRecorder) GetInstanceIdentityDocument() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInstanceIdentityDocument", reflect.TypeOf((*MockEC2Metadata)(nil).GetInstanceIdentityDocument))
 }
+
+// GetMetadata mocks base method
+func (m *MockEC2Metadata) GetMetadata(arg0 string) (string, error) {
+	ret := m.ctrl.Call(m, "GetMetadata", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMetadata indicates an expected call of GetMetadata
+func (mr *MockEC2MetadataMockRecorder) GetMetadata(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMetadata", reflect.TypeOf((*MockEC2Metadata)(nil).GetMetadata), arg0)
+}
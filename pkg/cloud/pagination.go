@@ -0,0 +1,79 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// minPageResults is the smallest MaxResults EC2 accepts when listing volumes or snapshots.
+	minPageResults = 5
+	// maxVolumesPageResults is the largest MaxResults EC2 accepts when listing volumes.
+	maxVolumesPageResults = 500
+	// maxSnapshotsPageResults is the largest MaxResults EC2 accepts when listing snapshots.
+	maxSnapshotsPageResults = 1000
+)
+
+// pageResults returns the MaxResults of the EC2 request listing up to maxResults results. EC2
+// only accepts a range of page sizes, so fewer results than requested may be returned when it's
+// above the range, and the page is truncated when it's below.
+func pageResults(maxResults, maxPageResults int64) int64 {
+	if maxResults < minPageResults {
+		return minPageResults
+	}
+	if maxResults > maxPageResults {
+		return maxPageResults
+	}
+	return maxResults
+}
+
+// Listings that stop within a page of EC2 results, because fewer results were requested than
+// EC2 returns, resume from a token made of the offset of the next result in the page, followed
+// by listTokenSeparator and the EC2 token of the page. EC2 tokens never contain the separator.
+const listTokenSeparator = "#"
+
+// parseListToken returns the EC2 token of the page a listing resumes from, and the offset of
+// the next result in that page. Tokens returned by EC2 are used as they are.
+func parseListToken(token string) (string, int, error) {
+	i := strings.Index(token, listTokenSeparator)
+	if i < 0 {
+		return token, 0, nil
+	}
+	offset, err := strconv.Atoi(token[:i])
+	if err != nil || offset <= 0 {
+		return "", 0, fmt.Errorf("invalid offset in pagination token %q", token)
+	}
+	return token[i+len(listTokenSeparator):], offset, nil
+}
+
+// pageWindow returns the range of the count results of the EC2 page at pageToken to return,
+// starting at offset and limited to maxResults when it's positive, and the token to resume
+// from. That's the next result of the page if it was truncated, or else nextPageToken.
+func pageWindow(count, offset int, maxResults int64, pageToken, nextPageToken string) (int, int, string) {
+	start := offset
+	if start > count {
+		start = count
+	}
+	if maxResults > 0 && int64(count-start) > maxResults {
+		end := start + int(maxResults)
+		return start, end, strconv.Itoa(end) + listTokenSeparator + pageToken
+	}
+	return start, count, nextPageToken
+}
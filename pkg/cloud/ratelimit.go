@@ -0,0 +1,295 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/logging"
+)
+
+const (
+	// DefaultAPIQPS is the default sustained rate of EC2 requests per second.
+	DefaultAPIQPS = 10
+
+	// DefaultAPIBurst is the default number of EC2 requests that can be made at once above the QPS.
+	DefaultAPIBurst = 20
+
+	// DefaultAPIMaxRetries is the default number of times a failed EC2 request is retried.
+	DefaultAPIMaxRetries = 8
+
+	// throttlingRetryBaseDelay and throttlingRetryMaxDelay bound the delay between the retries
+	// of a throttled request, which doubles after each retry.
+	throttlingRetryBaseDelay = 500 * time.Millisecond
+	throttlingRetryMaxDelay  = 30 * time.Second
+)
+
+// IsThrottlingError returns whether err was caused by AWS throttling the requests of the driver.
+func IsThrottlingError(err error) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && request.IsErrorThrottle(awsErr)
+}
+
+// tokenBucket limits the rate of requests to qps, while allowing bursts of up to burst requests.
+type tokenBucket struct {
+	qps   float64
+	burst float64
+
+	mux    sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(qps float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		qps:    qps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until a request can be made or the context is done.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	b.mux.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.qps
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	// The token is taken right away so that waiting requests are served in order
+	b.tokens--
+	delay := time.Duration(-b.tokens / b.qps * float64(time.Second))
+	b.mux.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.mux.Lock()
+		b.tokens++
+		b.mux.Unlock()
+		return ctx.Err()
+	}
+}
+
+// rateLimitedEC2 decorates EC2 with client-side rate limiting, and retries the requests throttled
+// by AWS with a jittered exponential backoff.
+type rateLimitedEC2 struct {
+	ec2        EC2
+	limiter    *tokenBucket
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+}
+
+var _ EC2 = &rateLimitedEC2{}
+
+// newRateLimitedEC2 returns the decorated EC2. Requests are not rate limited when qps is not positive.
+func newRateLimitedEC2(svc EC2, qps float64, burst, maxRetries int) *rateLimitedEC2 {
+	c := &rateLimitedEC2{
+		ec2:        svc,
+		maxRetries: maxRetries,
+		baseDelay:  throttlingRetryBaseDelay,
+		maxDelay:   throttlingRetryMaxDelay,
+	}
+	if qps > 0 {
+		if burst < 1 {
+			burst = 1
+		}
+		c.limiter = newTokenBucket(qps, burst)
+	}
+	return c
+}
+
+// call makes the request, retrying it as long as it's throttled and retries are left.
+func (c *rateLimitedEC2) call(ctx context.Context, name string, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		if c.limiter != nil {
+			if err := c.limiter.wait(ctx); err != nil {
+				return err
+			}
+		}
+
+		err := fn()
+		if !IsThrottlingError(err) {
+			return err
+		}
+		throttledRequests.WithLabelValues(name).Inc()
+		if attempt >= c.maxRetries {
+			logging.FromContext(ctx).Errorf("%s request was throttled, giving up after %d retries: %v", name, attempt, err)
+			return err
+		}
+
+		delay := c.retryDelay(attempt)
+		logging.FromContext(ctx).V(4).Infof("%s request was throttled, retrying in %v: %v", name, delay, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// retryDelay returns the delay before the given retry. The delay doubles after each retry up to
+// the maximum delay, and half of it is random so that throttled requests don't retry all at once.
+func (c *rateLimitedEC2) retryDelay(attempt int) time.Duration {
+	delay := c.maxDelay
+	if attempt < 32 && c.baseDelay<<uint(attempt) < c.maxDelay {
+		delay = c.baseDelay << uint(attempt)
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func (c *rateLimitedEC2) DescribeVolumesWithContext(ctx aws.Context, input *ec2.DescribeVolumesInput, opts ...request.Option) (*ec2.DescribeVolumesOutput, error) {
+	var output *ec2.DescribeVolumesOutput
+	err := c.call(ctx, "DescribeVolumes", func() (err error) {
+		output, err = c.ec2.DescribeVolumesWithContext(ctx, input, opts...)
+		return err
+	})
+	return output, err
+}
+
+func (c *rateLimitedEC2) CreateVolumeWithContext(ctx aws.Context, input *ec2.CreateVolumeInput, opts ...request.Option) (*ec2.Volume, error) {
+	var output *ec2.Volume
+	err := c.call(ctx, "CreateVolume", func() (err error) {
+		output, err = c.ec2.CreateVolumeWithContext(ctx, input, opts...)
+		return err
+	})
+	return output, err
+}
+
+func (c *rateLimitedEC2) DeleteVolumeWithContext(ctx aws.Context, input *ec2.DeleteVolumeInput, opts ...request.Option) (*ec2.DeleteVolumeOutput, error) {
+	var output *ec2.DeleteVolumeOutput
+	err := c.call(ctx, "DeleteVolume", func() (err error) {
+		output, err = c.ec2.DeleteVolumeWithContext(ctx, input, opts...)
+		return err
+	})
+	return output, err
+}
+
+func (c *rateLimitedEC2) DetachVolumeWithContext(ctx aws.Context, input *ec2.DetachVolumeInput, opts ...request.Option) (*ec2.VolumeAttachment, error) {
+	var output *ec2.VolumeAttachment
+	err := c.call(ctx, "DetachVolume", func() (err error) {
+		output, err = c.ec2.DetachVolumeWithContext(ctx, input, opts...)
+		return err
+	})
+	return output, err
+}
+
+func (c *rateLimitedEC2) AttachVolumeWithContext(ctx aws.Context, input *ec2.AttachVolumeInput, opts ...request.Option) (*ec2.VolumeAttachment, error) {
+	var output *ec2.VolumeAttachment
+	err := c.call(ctx, "AttachVolume", func() (err error) {
+		output, err = c.ec2.AttachVolumeWithContext(ctx, input, opts...)
+		return err
+	})
+	return output, err
+}
+
+func (c *rateLimitedEC2) DescribeInstancesWithContext(ctx aws.Context, input *ec2.DescribeInstancesInput, opts ...request.Option) (*ec2.DescribeInstancesOutput, error) {
+	var output *ec2.DescribeInstancesOutput
+	err := c.call(ctx, "DescribeInstances", func() (err error) {
+		output, err = c.ec2.DescribeInstancesWithContext(ctx, input, opts...)
+		return err
+	})
+	return output, err
+}
+
+func (c *rateLimitedEC2) CreateSnapshotWithContext(ctx aws.Context, input *ec2.CreateSnapshotInput, opts ...request.Option) (*ec2.Snapshot, error) {
+	var output *ec2.Snapshot
+	err := c.call(ctx, "CreateSnapshot", func() (err error) {
+		output, err = c.ec2.CreateSnapshotWithContext(ctx, input, opts...)
+		return err
+	})
+	return output, err
+}
+
+func (c *rateLimitedEC2) DeleteSnapshotWithContext(ctx aws.Context, input *ec2.DeleteSnapshotInput, opts ...request.Option) (*ec2.DeleteSnapshotOutput, error) {
+	var output *ec2.DeleteSnapshotOutput
+	err := c.call(ctx, "DeleteSnapshot", func() (err error) {
+		output, err = c.ec2.DeleteSnapshotWithContext(ctx, input, opts...)
+		return err
+	})
+	return output, err
+}
+
+func (c *rateLimitedEC2) DescribeSnapshotsWithContext(ctx aws.Context, input *ec2.DescribeSnapshotsInput, opts ...request.Option) (*ec2.DescribeSnapshotsOutput, error) {
+	var output *ec2.DescribeSnapshotsOutput
+	err := c.call(ctx, "DescribeSnapshots", func() (err error) {
+		output, err = c.ec2.DescribeSnapshotsWithContext(ctx, input, opts...)
+		return err
+	})
+	return output, err
+}
+
+func (c *rateLimitedEC2) ModifyVolumeWithContext(ctx aws.Context, input *ec2.ModifyVolumeInput, opts ...request.Option) (*ec2.ModifyVolumeOutput, error) {
+	var output *ec2.ModifyVolumeOutput
+	err := c.call(ctx, "ModifyVolume", func() (err error) {
+		output, err = c.ec2.ModifyVolumeWithContext(ctx, input, opts...)
+		return err
+	})
+	return output, err
+}
+
+func (c *rateLimitedEC2) DescribeVolumesModificationsWithContext(ctx aws.Context, input *ec2.DescribeVolumesModificationsInput, opts ...request.Option) (*ec2.DescribeVolumesModificationsOutput, error) {
+	var output *ec2.DescribeVolumesModificationsOutput
+	err := c.call(ctx, "DescribeVolumesModifications", func() (err error) {
+		output, err = c.ec2.DescribeVolumesModificationsWithContext(ctx, input, opts...)
+		return err
+	})
+	return output, err
+}
+
+func (c *rateLimitedEC2) DescribeAvailabilityZonesWithContext(ctx aws.Context, input *ec2.DescribeAvailabilityZonesInput, opts ...request.Option) (*ec2.DescribeAvailabilityZonesOutput, error) {
+	var output *ec2.DescribeAvailabilityZonesOutput
+	err := c.call(ctx, "DescribeAvailabilityZones", func() (err error) {
+		output, err = c.ec2.DescribeAvailabilityZonesWithContext(ctx, input, opts...)
+		return err
+	})
+	return output, err
+}
+
+// nonThrottlingRetryer is the retryer of the SDK, except that throttled requests are left to
+// rateLimitedEC2 so that they are not retried twice.
+type nonThrottlingRetryer struct {
+	client.DefaultRetryer
+}
+
+func (r nonThrottlingRetryer) ShouldRetry(req *request.Request) bool {
+	if req.IsErrorThrottle() {
+		return false
+	}
+	return r.DefaultRetryer.ShouldRetry(req)
+}
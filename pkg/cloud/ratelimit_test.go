@@ -0,0 +1,190 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/golang/mock/gomock"
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/cloud/mocks"
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/testutil"
+)
+
+func TestIsThrottlingError(t *testing.T) {
+	testCases := []struct {
+		name   string
+		err    error
+		expRes bool
+	}{
+		{
+			name:   "request limit exceeded",
+			err:    awserr.New("RequestLimitExceeded", "Request limit exceeded.", nil),
+			expRes: true,
+		},
+		{
+			name:   "wrapped throttling error",
+			err:    fmt.Errorf("could not attach volume: %w", awserr.New("Throttling", "Rate exceeded", nil)),
+			expRes: true,
+		},
+		{
+			name:   "other AWS error",
+			err:    awserr.New("InvalidVolume.NotFound", "", nil),
+			expRes: false,
+		},
+		{
+			name:   "other error",
+			err:    fmt.Errorf("RequestLimitExceeded"),
+			expRes: false,
+		},
+		{
+			name:   "no error",
+			expRes: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if res := IsThrottlingError(tc.err); res != tc.expRes {
+				t.Fatalf("IsThrottlingError() failed: expected %v, got %v", tc.expRes, res)
+			}
+		})
+	}
+}
+
+func TestRateLimitedEC2(t *testing.T) {
+	throttlingErr := awserr.New("RequestLimitExceeded", "Request limit exceeded.", nil)
+
+	testCases := []struct {
+		name         string
+		errs         []error
+		maxRetries   int
+		expErr       error
+		expThrottled float64
+	}{
+		{
+			name: "success: not throttled",
+			errs: []error{nil},
+		},
+		{
+			name:         "success: throttled then retried",
+			errs:         []error{throttlingErr, throttlingErr, nil},
+			maxRetries:   3,
+			expThrottled: 2,
+		},
+		{
+			name:         "fail: still throttled after the retries",
+			errs:         []error{throttlingErr, throttlingErr, throttlingErr},
+			maxRetries:   2,
+			expErr:       throttlingErr,
+			expThrottled: 3,
+		},
+		{
+			name:       "fail: other errors are not retried",
+			errs:       []error{fmt.Errorf("DescribeVolumes generic error")},
+			maxRetries: 3,
+			expErr:     fmt.Errorf("DescribeVolumes generic error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		mockCtrl := gomock.NewController(t)
+		mockEC2 := mocks.NewMockEC2(mockCtrl)
+		c := newRateLimitedEC2(mockEC2, 0, 0, tc.maxRetries)
+		c.baseDelay = time.Millisecond
+		c.maxDelay = 2 * time.Millisecond
+
+		ctx := context.Background()
+		var calls []*gomock.Call
+		for _, err := range tc.errs {
+			output := &ec2.DescribeVolumesOutput{}
+			if err != nil {
+				output = nil
+			}
+			calls = append(calls, mockEC2.EXPECT().DescribeVolumesWithContext(gomock.Eq(ctx), gomock.Any()).Return(output, err))
+		}
+		gomock.InOrder(calls...)
+
+		throttledBefore := testutil.CounterValue(t, throttledRequests, "DescribeVolumes")
+		_, err := c.DescribeVolumesWithContext(ctx, &ec2.DescribeVolumesInput{})
+		if err != nil {
+			if tc.expErr == nil {
+				t.Fatalf("DescribeVolumesWithContext() failed: expected no error, got: %v", err)
+			}
+			if tc.expErr.Error() != err.Error() {
+				t.Fatalf("DescribeVolumesWithContext() failed: expected error %q, got: %q", tc.expErr, err)
+			}
+		} else if tc.expErr != nil {
+			t.Fatal("DescribeVolumesWithContext() failed: expected error, got nothing")
+		}
+
+		if throttled := testutil.CounterValue(t, throttledRequests, "DescribeVolumes") - throttledBefore; throttled != tc.expThrottled {
+			t.Fatalf("Expected %v throttled requests to be counted, got %v", tc.expThrottled, throttled)
+		}
+
+		mockCtrl.Finish()
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	c := newRateLimitedEC2(nil, 0, 0, 0)
+	c.baseDelay = 100 * time.Millisecond
+	c.maxDelay = time.Second
+
+	for attempt, maxDelay := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		maxDelay *= time.Millisecond
+		for i := 0; i < 10; i++ {
+			if delay := c.retryDelay(attempt); delay < maxDelay/2 || delay > maxDelay {
+				t.Fatalf("Expected delay of retry %d to be between %v and %v, got %v", attempt, maxDelay/2, maxDelay, delay)
+			}
+		}
+	}
+	if delay := c.retryDelay(100); delay > c.maxDelay {
+		t.Fatalf("Expected delay to be capped at %v, got %v", c.maxDelay, delay)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	bucket := newTokenBucket(100, 2)
+	ctx := context.Background()
+
+	// The burst is served right away, and the next requests at the QPS
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := bucket.wait(ctx); err != nil {
+			t.Fatalf("wait() failed: expected no error, got: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Fatalf("Expected requests above the burst to be delayed, took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	bucket = newTokenBucket(0.001, 1)
+	if err := bucket.wait(ctx); err != nil {
+		t.Fatalf("wait() failed: expected the burst to be served, got: %v", err)
+	}
+	if err := bucket.wait(ctx); err != context.Canceled {
+		t.Fatalf("wait() failed: expected %v, got: %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"fmt"
+)
+
+// volumeTypeLimits holds the constraints of the size, IOPS and throughput of a volume type.
+// See https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/ebs-volume-types.html for details
+type volumeTypeLimits struct {
+	minSizeGiB int64
+	maxSizeGiB int64
+
+	// minIOPS and maxIOPS bound the IOPS of the volume types with provisioned IOPS, and are
+	// zero for the other types.
+	minIOPS       int64
+	maxIOPS       int64
+	maxIOPSPerGiB int64
+	// baselineIOPS is the IOPS of a volume whose IOPS are not provisioned. The IOPS of the
+	// volume types without baseline must always be provisioned.
+	baselineIOPS int64
+
+	// minThroughput and maxThroughput bound the throughput in MiB/s of the volume types with
+	// provisioned throughput, and are zero for the other types.
+	minThroughput        int64
+	maxThroughput        int64
+	maxThroughputPerIOPS float64
+}
+
+var volumeTypesLimits = map[string]volumeTypeLimits{
+	VolumeTypeGP2: {
+		minSizeGiB: 1,
+		maxSizeGiB: 16384,
+	},
+	VolumeTypeGP3: {
+		minSizeGiB:           1,
+		maxSizeGiB:           16384,
+		minIOPS:              3000,
+		maxIOPS:              16000,
+		maxIOPSPerGiB:        500,
+		baselineIOPS:         3000,
+		minThroughput:        125,
+		maxThroughput:        1000,
+		maxThroughputPerIOPS: 0.25,
+	},
+	VolumeTypeIO1: {
+		minSizeGiB:    4,
+		maxSizeGiB:    16384,
+		minIOPS:       100,
+		maxIOPS:       64000,
+		maxIOPSPerGiB: 50,
+	},
+	VolumeTypeIO2: {
+		minSizeGiB:    4,
+		maxSizeGiB:    16384,
+		minIOPS:       100,
+		maxIOPS:       64000,
+		maxIOPSPerGiB: 500,
+	},
+	VolumeTypeSC1: {
+		minSizeGiB: 125,
+		maxSizeGiB: 16384,
+	},
+	VolumeTypeST1: {
+		minSizeGiB: 125,
+		maxSizeGiB: 16384,
+	},
+}
+
+// io2BlockExpressLimits are the limits applied to io2 volumes instead of the io2 limits when
+// DiskOptions.BlockExpress is set, which is the blockExpress parameter.
+var io2BlockExpressLimits = volumeTypeLimits{
+	minSizeGiB:    4,
+	maxSizeGiB:    65536,
+	minIOPS:       100,
+	maxIOPS:       256000,
+	maxIOPSPerGiB: 1000,
+}
+
+// diskParameters returns the volume type, IOPS and throughput of a new volume of the given
+// size, once checked against the limits of its type. The IOPS and the throughput are zero
+// when they are not provisioned.
+//
+// IOPS above the limits of the type are decreased to the maximum. IOPS below the minimum are
+// rejected, unless the options allow them to be increased to the minimum. IOPS are ignored by
+// the types without provisioned IOPS, while throughput is rejected by the types without
+// provisioned throughput.
+func diskParameters(capacityGiB int64, o *DiskOptions) (volumeType string, iops, throughput int64, err error) {
+	volumeType = o.VolumeType
+	if volumeType == "" {
+		volumeType = DefaultVolumeType
+	}
+	limits, ok := volumeTypesLimits[volumeType]
+	if !ok {
+		return "", 0, 0, fmt.Errorf("%w: invalid volume type %q", ErrInvalidDiskOptions, o.VolumeType)
+	}
+	if o.BlockExpress {
+		if volumeType != VolumeTypeIO2 {
+			return "", 0, 0, fmt.Errorf("%w: volume type %q can't be Block Express", ErrInvalidDiskOptions, volumeType)
+		}
+		limits = io2BlockExpressLimits
+	}
+
+	if capacityGiB < limits.minSizeGiB || capacityGiB > limits.maxSizeGiB {
+		return "", 0, 0, fmt.Errorf("%w: %d GiB is out of the %d-%d GiB range of volume type %q",
+			ErrCapacityOutOfRange, capacityGiB, limits.minSizeGiB, limits.maxSizeGiB, volumeType)
+	}
+
+	if o.IOPS > 0 && o.IOPSPerGB > 0 {
+		return "", 0, 0, fmt.Errorf("%w: IOPS and IOPS per GiB can't both be set", ErrInvalidDiskOptions)
+	}
+	requestedIOPS := o.IOPS
+	if o.IOPSPerGB > 0 {
+		requestedIOPS = capacityGiB * o.IOPSPerGB
+	}
+	switch {
+	case limits.maxIOPS == 0:
+		// IOPS are ignored by the volume types without provisioned IOPS, as they always were
+	case requestedIOPS == 0:
+		if limits.baselineIOPS == 0 {
+			iops = limits.minIOPS
+		}
+	default:
+		if iops, err = limits.capIOPS(volumeType, capacityGiB, requestedIOPS, o.AllowIOPSPerGBIncrease); err != nil {
+			return "", 0, 0, err
+		}
+	}
+
+	if o.Throughput > 0 {
+		if limits.maxThroughput == 0 {
+			return "", 0, 0, fmt.Errorf("%w: volume type %q doesn't support provisioned throughput", ErrInvalidDiskOptions, volumeType)
+		}
+		if o.Throughput < limits.minThroughput || o.Throughput > limits.maxThroughput {
+			return "", 0, 0, fmt.Errorf("%w: throughput of %d MiB/s is out of the %d-%d MiB/s range of volume type %q",
+				ErrInvalidDiskOptions, o.Throughput, limits.minThroughput, limits.maxThroughput, volumeType)
+		}
+		effectiveIOPS := iops
+		if effectiveIOPS == 0 {
+			effectiveIOPS = limits.baselineIOPS
+		}
+		if float64(o.Throughput) > limits.maxThroughputPerIOPS*float64(effectiveIOPS) {
+			return "", 0, 0, fmt.Errorf("%w: throughput of %d MiB/s is above the maximum of %v MiB/s per IOPS for %d IOPS",
+				ErrInvalidDiskOptions, o.Throughput, limits.maxThroughputPerIOPS, effectiveIOPS)
+		}
+		throughput = o.Throughput
+	}
+
+	return volumeType, iops, throughput, nil
+}
+
+// capIOPS returns the requested IOPS within the limits of the volume type.
+func (l volumeTypeLimits) capIOPS(volumeType string, capacityGiB, iops int64, allowIncrease bool) (int64, error) {
+	if iops < l.minIOPS {
+		if !allowIncrease {
+			return 0, fmt.Errorf("%w: %d IOPS is below the minimum of %d IOPS of volume type %q",
+				ErrInvalidDiskOptions, iops, l.minIOPS, volumeType)
+		}
+		iops = l.minIOPS
+	}
+	if iops > l.maxIOPS {
+		iops = l.maxIOPS
+	}
+	// Small volumes can always get the minimum IOPS, whatever their size
+	maxIOPS := capacityGiB * l.maxIOPSPerGiB
+	if maxIOPS < l.minIOPS {
+		maxIOPS = l.minIOPS
+	}
+	if iops > maxIOPS {
+		iops = maxIOPS
+	}
+	return iops, nil
+}
@@ -0,0 +1,308 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDiskParameters(t *testing.T) {
+	testCases := []struct {
+		name          string
+		capacityGiB   int64
+		options       *DiskOptions
+		expType       string
+		expIOPS       int64
+		expThroughput int64
+		expErr        error
+	}{
+		// gp2
+		{
+			name:        "gp2: default type",
+			capacityGiB: 10,
+			options:     &DiskOptions{},
+			expType:     VolumeTypeGP2,
+		},
+		{
+			name:        "gp2: maximum size",
+			capacityGiB: 16384,
+			options:     &DiskOptions{VolumeType: VolumeTypeGP2},
+			expType:     VolumeTypeGP2,
+		},
+		{
+			name:        "gp2: too big",
+			capacityGiB: 16385,
+			options:     &DiskOptions{VolumeType: VolumeTypeGP2},
+			expErr:      ErrCapacityOutOfRange,
+		},
+		{
+			name:        "gp2: IOPS ignored",
+			capacityGiB: 10,
+			options:     &DiskOptions{VolumeType: VolumeTypeGP2, IOPSPerGB: 10},
+			expType:     VolumeTypeGP2,
+		},
+		{
+			name:        "gp2: throughput not supported",
+			capacityGiB: 10,
+			options:     &DiskOptions{VolumeType: VolumeTypeGP2, Throughput: 250},
+			expErr:      ErrInvalidDiskOptions,
+		},
+		// gp3
+		{
+			name:        "gp3: baseline IOPS and throughput",
+			capacityGiB: 1,
+			options:     &DiskOptions{VolumeType: VolumeTypeGP3},
+			expType:     VolumeTypeGP3,
+		},
+		{
+			name:          "gp3: IOPS and throughput",
+			capacityGiB:   100,
+			options:       &DiskOptions{VolumeType: VolumeTypeGP3, IOPS: 4000, Throughput: 500},
+			expType:       VolumeTypeGP3,
+			expIOPS:       4000,
+			expThroughput: 500,
+		},
+		{
+			name:          "gp3: throughput with baseline IOPS",
+			capacityGiB:   100,
+			options:       &DiskOptions{VolumeType: VolumeTypeGP3, Throughput: 750},
+			expType:       VolumeTypeGP3,
+			expThroughput: 750,
+		},
+		{
+			name:        "gp3: IOPS capped to the maximum per GiB",
+			capacityGiB: 10,
+			options:     &DiskOptions{VolumeType: VolumeTypeGP3, IOPS: 16000},
+			expType:     VolumeTypeGP3,
+			expIOPS:     5000,
+		},
+		{
+			name:        "gp3: IOPS capped to the maximum",
+			capacityGiB: 100,
+			options:     &DiskOptions{VolumeType: VolumeTypeGP3, IOPSPerGB: 200},
+			expType:     VolumeTypeGP3,
+			expIOPS:     16000,
+		},
+		{
+			name:        "gp3: small volume gets the minimum IOPS",
+			capacityGiB: 1,
+			options:     &DiskOptions{VolumeType: VolumeTypeGP3, IOPS: 3000},
+			expType:     VolumeTypeGP3,
+			expIOPS:     3000,
+		},
+		{
+			name:        "gp3: IOPS below the minimum",
+			capacityGiB: 100,
+			options:     &DiskOptions{VolumeType: VolumeTypeGP3, IOPS: 1000},
+			expErr:      ErrInvalidDiskOptions,
+		},
+		{
+			name:        "gp3: IOPS below the minimum increased",
+			capacityGiB: 100,
+			options:     &DiskOptions{VolumeType: VolumeTypeGP3, IOPS: 1000, AllowIOPSPerGBIncrease: true},
+			expType:     VolumeTypeGP3,
+			expIOPS:     3000,
+		},
+		{
+			name:        "gp3: throughput below the minimum",
+			capacityGiB: 100,
+			options:     &DiskOptions{VolumeType: VolumeTypeGP3, Throughput: 100},
+			expErr:      ErrInvalidDiskOptions,
+		},
+		{
+			name:        "gp3: throughput above the maximum",
+			capacityGiB: 100,
+			options:     &DiskOptions{VolumeType: VolumeTypeGP3, IOPS: 16000, Throughput: 1001},
+			expErr:      ErrInvalidDiskOptions,
+		},
+		{
+			name:        "gp3: throughput above the maximum per IOPS",
+			capacityGiB: 100,
+			options:     &DiskOptions{VolumeType: VolumeTypeGP3, Throughput: 1000},
+			expErr:      ErrInvalidDiskOptions,
+		},
+		{
+			name:        "gp3: IOPS and IOPS per GiB",
+			capacityGiB: 100,
+			options:     &DiskOptions{VolumeType: VolumeTypeGP3, IOPS: 4000, IOPSPerGB: 40},
+			expErr:      ErrInvalidDiskOptions,
+		},
+		// io1
+		{
+			name:        "io1: IOPS per GiB",
+			capacityGiB: 100,
+			options:     &DiskOptions{VolumeType: VolumeTypeIO1, IOPSPerGB: 10},
+			expType:     VolumeTypeIO1,
+			expIOPS:     1000,
+		},
+		{
+			name:        "io1: minimum IOPS when not provisioned",
+			capacityGiB: 100,
+			options:     &DiskOptions{VolumeType: VolumeTypeIO1},
+			expType:     VolumeTypeIO1,
+			expIOPS:     100,
+		},
+		{
+			name:        "io1: IOPS per GiB too low",
+			capacityGiB: 5,
+			options:     &DiskOptions{VolumeType: VolumeTypeIO1, IOPSPerGB: 10},
+			expErr:      ErrInvalidDiskOptions,
+		},
+		{
+			name:        "io1: IOPS per GiB too low increased",
+			capacityGiB: 5,
+			options:     &DiskOptions{VolumeType: VolumeTypeIO1, IOPSPerGB: 10, AllowIOPSPerGBIncrease: true},
+			expType:     VolumeTypeIO1,
+			expIOPS:     100,
+		},
+		{
+			name:        "io1: IOPS capped to the maximum per GiB",
+			capacityGiB: 100,
+			options:     &DiskOptions{VolumeType: VolumeTypeIO1, IOPS: 10000},
+			expType:     VolumeTypeIO1,
+			expIOPS:     5000,
+		},
+		{
+			name:        "io1: IOPS capped to the maximum",
+			capacityGiB: 2000,
+			options:     &DiskOptions{VolumeType: VolumeTypeIO1, IOPSPerGB: 50},
+			expType:     VolumeTypeIO1,
+			expIOPS:     64000,
+		},
+		{
+			name:        "io1: too small",
+			capacityGiB: 1,
+			options:     &DiskOptions{VolumeType: VolumeTypeIO1},
+			expErr:      ErrCapacityOutOfRange,
+		},
+		// io2
+		{
+			name:        "io2: IOPS capped to the maximum per GiB",
+			capacityGiB: 10,
+			options:     &DiskOptions{VolumeType: VolumeTypeIO2, IOPS: 10000},
+			expType:     VolumeTypeIO2,
+			expIOPS:     5000,
+		},
+		{
+			name:        "io2: IOPS capped to the maximum",
+			capacityGiB: 1000,
+			options:     &DiskOptions{VolumeType: VolumeTypeIO2, IOPS: 100000},
+			expType:     VolumeTypeIO2,
+			expIOPS:     64000,
+		},
+		{
+			name:        "io2: too big",
+			capacityGiB: 20000,
+			options:     &DiskOptions{VolumeType: VolumeTypeIO2},
+			expErr:      ErrCapacityOutOfRange,
+		},
+		{
+			name:        "io2: throughput not supported",
+			capacityGiB: 100,
+			options:     &DiskOptions{VolumeType: VolumeTypeIO2, Throughput: 500},
+			expErr:      ErrInvalidDiskOptions,
+		},
+		// io2 Block Express
+		{
+			name:        "io2 Block Express: IOPS above the io2 maximum",
+			capacityGiB: 1000,
+			options:     &DiskOptions{VolumeType: VolumeTypeIO2, BlockExpress: true, IOPS: 100000},
+			expType:     VolumeTypeIO2,
+			expIOPS:     100000,
+		},
+		{
+			name:        "io2 Block Express: IOPS capped to the maximum",
+			capacityGiB: 1000,
+			options:     &DiskOptions{VolumeType: VolumeTypeIO2, BlockExpress: true, IOPSPerGB: 1000},
+			expType:     VolumeTypeIO2,
+			expIOPS:     256000,
+		},
+		{
+			name:        "io2 Block Express: size above the io2 maximum",
+			capacityGiB: 65536,
+			options:     &DiskOptions{VolumeType: VolumeTypeIO2, BlockExpress: true},
+			expType:     VolumeTypeIO2,
+			expIOPS:     100,
+		},
+		{
+			name:        "io2 Block Express: too big",
+			capacityGiB: 65537,
+			options:     &DiskOptions{VolumeType: VolumeTypeIO2, BlockExpress: true},
+			expErr:      ErrCapacityOutOfRange,
+		},
+		{
+			name:        "io2 Block Express: not io2",
+			capacityGiB: 100,
+			options:     &DiskOptions{VolumeType: VolumeTypeIO1, BlockExpress: true},
+			expErr:      ErrInvalidDiskOptions,
+		},
+		// sc1 and st1
+		{
+			name:        "sc1: minimum size",
+			capacityGiB: 125,
+			options:     &DiskOptions{VolumeType: VolumeTypeSC1},
+			expType:     VolumeTypeSC1,
+		},
+		{
+			name:        "st1: IOPS ignored",
+			capacityGiB: 500,
+			options:     &DiskOptions{VolumeType: VolumeTypeST1, IOPS: 1000},
+			expType:     VolumeTypeST1,
+		},
+		{
+			name:        "st1: too small",
+			capacityGiB: 100,
+			options:     &DiskOptions{VolumeType: VolumeTypeST1},
+			expErr:      ErrCapacityOutOfRange,
+		},
+		{
+			name:        "invalid type",
+			capacityGiB: 100,
+			options:     &DiskOptions{VolumeType: "gp9"},
+			expErr:      ErrInvalidDiskOptions,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			volumeType, iops, throughput, err := diskParameters(tc.capacityGiB, tc.options)
+			if err != nil {
+				if tc.expErr == nil {
+					t.Fatalf("diskParameters() failed: expected no error, got: %v", err)
+				}
+				if !errors.Is(err, tc.expErr) {
+					t.Fatalf("diskParameters() failed: expected error %q, got: %q", tc.expErr, err)
+				}
+				return
+			}
+			if tc.expErr != nil {
+				t.Fatalf("diskParameters() failed: expected error %q, got nothing", tc.expErr)
+			}
+
+			if volumeType != tc.expType {
+				t.Fatalf("Expected volume type %q, got %q", tc.expType, volumeType)
+			}
+			if iops != tc.expIOPS {
+				t.Fatalf("Expected %d IOPS, got %d", tc.expIOPS, iops)
+			}
+			if throughput != tc.expThroughput {
+				t.Fatalf("Expected throughput %d MiB/s, got %d MiB/s", tc.expThroughput, throughput)
+			}
+		})
+	}
+}
@@ -18,22 +18,32 @@ package driver
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
 
-	csi "github.com/container-storage-interface/spec/lib/go/csi/v0"
-	"github.com/golang/glog"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/cloud"
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/logging"
 	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/util"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
 func (d *Driver) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
-	glog.V(4).Infof("CreateVolume: called with args %#v", req)
 	volName := req.GetName()
 	if len(volName) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Volume name not provided")
 	}
 
+	key := volumeNameKey(volName)
+	if ok := d.inFlight.insert(key); !ok {
+		return nil, errOperationInFlight(volName)
+	}
+	defer d.inFlight.delete(key)
+
 	volSize := cloud.DefaultVolumeSize
 	if req.GetCapacityRange() != nil {
 		volSize = req.GetCapacityRange().GetRequiredBytes()
@@ -55,6 +65,40 @@ func (d *Driver) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest)
 		return nil, status.Error(codes.InvalidArgument, "Volume capabilities not supported")
 	}
 
+	params, err := parseVolumeParameters(req.GetParameters())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid parameters: %v", err)
+	}
+
+	var snapshotID string
+	if volumeSource := req.GetVolumeContentSource(); volumeSource != nil {
+		sourceSnapshot := volumeSource.GetSnapshot()
+		if sourceSnapshot == nil || len(sourceSnapshot.GetSnapshotId()) == 0 {
+			return nil, status.Error(codes.InvalidArgument, "Unsupported volume content source")
+		}
+		snapshotID = sourceSnapshot.GetSnapshotId()
+
+		snapshot, err := d.cloud.GetSnapshotByID(ctx, snapshotID)
+		if err != nil {
+			if err == cloud.ErrNotFound {
+				return nil, status.Errorf(codes.NotFound, "Snapshot %q not found", snapshotID)
+			}
+			return nil, status.Errorf(cloudErrorCode(err), "Could not get snapshot with ID %q: %v", snapshotID, err)
+		}
+
+		// A volume restored from a snapshot can't be smaller than the snapshot itself
+		snapshotSizeBytes := util.GiBToBytes(snapshot.SizeGiB)
+		if volSizeBytes < snapshotSizeBytes {
+			if req.GetCapacityRange().GetRequiredBytes() > 0 {
+				return nil, status.Errorf(codes.OutOfRange, "Requested volume size %d is less than the size %d of snapshot %q", volSizeBytes, snapshotSizeBytes, snapshotID)
+			}
+			volSizeBytes = snapshotSizeBytes
+			if (maxVolSize > 0) && (maxVolSize < volSizeBytes) {
+				return nil, status.Errorf(codes.OutOfRange, "Size %d of snapshot %q exceeds the limit specified", snapshotSizeBytes, snapshotID)
+			}
+		}
+	}
+
 	disk, err := d.cloud.GetDiskByName(ctx, volName, volSizeBytes)
 	if err != nil {
 		switch err {
@@ -64,54 +108,103 @@ func (d *Driver) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest)
 		case cloud.ErrDiskExistsDiffSize:
 			return nil, status.Error(codes.AlreadyExists, err.Error())
 		default:
-			return nil, status.Error(codes.Internal, err.Error())
+			return nil, status.Error(cloudErrorCode(err), err.Error())
 		}
 	}
 
 	// volume exists already
 	if disk != nil {
-		return newCreateVolumeResponse(disk), nil
+		if disk.SnapshotID != snapshotID {
+			return nil, status.Errorf(codes.AlreadyExists, "Volume %q already exists with a different content source", volName)
+		}
+		if !params.matchesEncryption(disk) {
+			return nil, status.Errorf(codes.AlreadyExists, "Volume %q already exists with different encryption settings", volName)
+		}
+		return newCreateVolumeResponse(disk, params.volumeAttributes()), nil
 	}
 
 	// create a new volume
-	zone := pickAvailabilityZone(req.GetAccessibilityRequirements())
+	zones, err := d.cloud.GetAvailabilityZones(ctx)
+	if err != nil {
+		return nil, status.Errorf(cloudErrorCode(err), "Could not get availability zones: %v", err)
+	}
+	zone, err := pickAvailabilityZone(req.GetAccessibilityRequirements(), volName, zones)
+	if err != nil {
+		return nil, status.Errorf(codes.ResourceExhausted, "Could not create volume %q: %v", volName, err)
+	}
+	if zone == "" {
+		logging.FromContext(ctx).V(4).Infof("CreateVolume: no availability zone requested, creating volume %q in the zone of the driver", volName)
+	}
 	opts := &cloud.DiskOptions{
-		CapacityBytes:    volSizeBytes,
-		AvailabilityZone: zone,
-		Tags:             map[string]string{cloud.VolumeNameTagKey: volName},
+		CapacityBytes:          volSizeBytes,
+		VolumeType:             params.volumeType,
+		BlockExpress:           params.blockExpress,
+		IOPSPerGB:              params.iopsPerGB,
+		IOPS:                   params.iops,
+		AllowIOPSPerGBIncrease: params.allowIOPSPerGBIncrease,
+		Throughput:             params.throughput,
+		Encrypted:              params.encrypted,
+		KmsKeyID:               params.kmsKeyID,
+		AvailabilityZone:       zone,
+		SnapshotID:             snapshotID,
+		Tags:                   map[string]string{cloud.VolumeNameTagKey: volName},
 	}
 	disk, err = d.cloud.CreateDisk(ctx, volName, opts)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Could not create volume %q: %v", volName, err)
+		switch err {
+		case cloud.ErrInvalidKMSKey:
+			return nil, status.Errorf(codes.InvalidArgument, "Could not create volume %q: %v", volName, err)
+		case cloud.ErrIdempotentParameterMismatch:
+			return nil, status.Errorf(codes.AlreadyExists, "Could not create volume %q: %v", volName, err)
+		case cloud.ErrCreationTimeout:
+			return nil, status.Errorf(codes.DeadlineExceeded, "Could not create volume %q: %v", volName, err)
+		}
+		if errors.Is(err, cloud.ErrInvalidDiskOptions) {
+			return nil, status.Errorf(codes.InvalidArgument, "Could not create volume %q: %v", volName, err)
+		}
+		if errors.Is(err, cloud.ErrCapacityOutOfRange) {
+			return nil, status.Errorf(codes.OutOfRange, "Could not create volume %q: %v", volName, err)
+		}
+		return nil, status.Errorf(cloudErrorCode(err), "Could not create volume %q: %v", volName, err)
 	}
-	return newCreateVolumeResponse(disk), nil
+	return newCreateVolumeResponse(disk, params.volumeAttributes()), nil
 }
 
 func (d *Driver) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
-	glog.V(4).Infof("DeleteVolume: called with args: %#v", req)
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
 	}
 
+	key := volumeIDKey(volumeID)
+	if ok := d.inFlight.insert(key); !ok {
+		return nil, errOperationInFlight(volumeID)
+	}
+	defer d.inFlight.delete(key)
+
 	if _, err := d.cloud.DeleteDisk(ctx, volumeID); err != nil {
 		if err == cloud.ErrNotFound {
-			glog.V(4).Info("DeleteVolume: volume not found, returning with success")
+			logging.FromContext(ctx).V(4).Infof("DeleteVolume: volume not found, returning with success")
 			return &csi.DeleteVolumeResponse{}, nil
 		}
-		return nil, status.Errorf(codes.Internal, "Could not delete volume ID %q: %v", volumeID, err)
+		return nil, status.Errorf(cloudErrorCode(err), "Could not delete volume ID %q: %v", volumeID, err)
 	}
 
 	return &csi.DeleteVolumeResponse{}, nil
 }
 
 func (d *Driver) ControllerPublishVolume(ctx context.Context, req *csi.ControllerPublishVolumeRequest) (*csi.ControllerPublishVolumeResponse, error) {
-	glog.V(4).Infof("ControllerPublishVolume: called with args %#v", req)
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
 	}
 
+	key := volumeIDKey(volumeID)
+	if ok := d.inFlight.insert(key); !ok {
+		return nil, errOperationInFlight(volumeID)
+	}
+	defer d.inFlight.delete(key)
+
 	nodeID := req.GetNodeId()
 	if len(nodeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Node ID not provided")
@@ -127,7 +220,11 @@ func (d *Driver) ControllerPublishVolume(ctx context.Context, req *csi.Controlle
 		return nil, status.Error(codes.InvalidArgument, "Volume capability not supported")
 	}
 
-	if !d.cloud.IsExistInstance(ctx, nodeID) {
+	exists, err := d.cloud.IsExistInstance(ctx, nodeID)
+	if err != nil {
+		return nil, status.Errorf(cloudErrorCode(err), "Could not get instance %q: %v", nodeID, err)
+	}
+	if !exists {
 		return nil, status.Errorf(codes.NotFound, "Instance %q not found", nodeID)
 	}
 
@@ -135,7 +232,7 @@ func (d *Driver) ControllerPublishVolume(ctx context.Context, req *csi.Controlle
 		if err == cloud.ErrNotFound {
 			return nil, status.Error(codes.NotFound, "Volume not found")
 		}
-		return nil, status.Errorf(codes.Internal, "Could not get volume with ID %q: %v", volumeID, err)
+		return nil, status.Errorf(cloudErrorCode(err), "Could not get volume with ID %q: %v", volumeID, err)
 	}
 
 	devicePath, err := d.cloud.AttachDisk(ctx, volumeID, nodeID)
@@ -143,36 +240,51 @@ func (d *Driver) ControllerPublishVolume(ctx context.Context, req *csi.Controlle
 		if err == cloud.ErrAlreadyExists {
 			return nil, status.Error(codes.AlreadyExists, err.Error())
 		}
-		return nil, status.Errorf(codes.Internal, "Could not attach volume %q to node %q: %v", volumeID, nodeID, err)
+		if err == cloud.ErrAttachmentTimeout {
+			return nil, status.Errorf(codes.DeadlineExceeded, "Could not attach volume %q to node %q: %v", volumeID, nodeID, err)
+		}
+		return nil, status.Errorf(cloudErrorCode(err), "Could not attach volume %q to node %q: %v", volumeID, nodeID, err)
 	}
-	glog.V(5).Infof("ControllerPublishVolume: volume %s attached to node %s through device %s", volumeID, nodeID, devicePath)
+	logging.FromContext(ctx).V(5).Infof("ControllerPublishVolume: volume %s attached to node %s through device %s", volumeID, nodeID, devicePath)
 
 	pvInfo := map[string]string{"devicePath": devicePath}
-	return &csi.ControllerPublishVolumeResponse{PublishInfo: pvInfo}, nil
+	return &csi.ControllerPublishVolumeResponse{PublishContext: pvInfo}, nil
 }
 
 func (d *Driver) ControllerUnpublishVolume(ctx context.Context, req *csi.ControllerUnpublishVolumeRequest) (*csi.ControllerUnpublishVolumeResponse, error) {
-	glog.V(4).Infof("ControllerUnpublishVolume: called with args %#v", req)
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
 	}
 
+	key := volumeIDKey(volumeID)
+	if ok := d.inFlight.insert(key); !ok {
+		return nil, errOperationInFlight(volumeID)
+	}
+	defer d.inFlight.delete(key)
+
 	nodeID := req.GetNodeId()
 	if len(nodeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Node ID not provided")
 	}
 
 	if err := d.cloud.DetachDisk(ctx, volumeID, nodeID); err != nil {
-		return nil, status.Errorf(codes.Internal, "Could not detach volume %q from node %q: %v", volumeID, nodeID, err)
+		if err == cloud.ErrNotFound {
+			// The volume was already detached from the node, or deleted
+			logging.FromContext(ctx).V(5).Infof("ControllerUnpublishVolume: volume %s is not attached to node %s", volumeID, nodeID)
+			return &csi.ControllerUnpublishVolumeResponse{}, nil
+		}
+		if err == cloud.ErrAttachmentTimeout {
+			return nil, status.Errorf(codes.DeadlineExceeded, "Could not detach volume %q from node %q: %v", volumeID, nodeID, err)
+		}
+		return nil, status.Errorf(cloudErrorCode(err), "Could not detach volume %q from node %q: %v", volumeID, nodeID, err)
 	}
-	glog.V(5).Infof("ControllerUnpublishVolume: volume %s detached from node %s", volumeID, nodeID)
+	logging.FromContext(ctx).V(5).Infof("ControllerUnpublishVolume: volume %s detached from node %s", volumeID, nodeID)
 
 	return &csi.ControllerUnpublishVolumeResponse{}, nil
 }
 
 func (d *Driver) ControllerGetCapabilities(ctx context.Context, req *csi.ControllerGetCapabilitiesRequest) (*csi.ControllerGetCapabilitiesResponse, error) {
-	glog.V(4).Infof("ControllerGetCapabilities: called with args %#v", req)
 	var caps []*csi.ControllerServiceCapability
 	for _, cap := range d.controllerCaps {
 		c := &csi.ControllerServiceCapability{
@@ -188,17 +300,29 @@ func (d *Driver) ControllerGetCapabilities(ctx context.Context, req *csi.Control
 }
 
 func (d *Driver) GetCapacity(ctx context.Context, req *csi.GetCapacityRequest) (*csi.GetCapacityResponse, error) {
-	glog.V(4).Infof("GetCapacity: called with args %#v", req)
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
 func (d *Driver) ListVolumes(ctx context.Context, req *csi.ListVolumesRequest) (*csi.ListVolumesResponse, error) {
-	glog.V(4).Infof("ListVolumes: called with args %#v", req)
-	return nil, status.Error(codes.Unimplemented, "")
+	maxEntries := req.GetMaxEntries()
+	if maxEntries < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid max entries %d", maxEntries)
+	}
+
+	resp, err := d.cloud.ListDisks(ctx, int64(maxEntries), req.GetStartingToken())
+	if err != nil {
+		switch err {
+		case cloud.ErrInvalidNextToken:
+			return nil, status.Error(codes.Aborted, err.Error())
+		default:
+			return nil, status.Errorf(cloudErrorCode(err), "Could not list volumes: %v", err)
+		}
+	}
+
+	return newListVolumesResponse(resp), nil
 }
 
 func (d *Driver) ValidateVolumeCapabilities(ctx context.Context, req *csi.ValidateVolumeCapabilitiesRequest) (*csi.ValidateVolumeCapabilitiesResponse, error) {
-	glog.V(4).Infof("ValidateVolumeCapabilities: called with args %#v", req)
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
@@ -213,17 +337,77 @@ func (d *Driver) ValidateVolumeCapabilities(ctx context.Context, req *csi.Valida
 		if err == cloud.ErrNotFound {
 			return nil, status.Error(codes.NotFound, "Volume not found")
 		}
-		return nil, status.Errorf(codes.Internal, "Could not get volume with ID %q: %v", volumeID, err)
+		return nil, status.Errorf(cloudErrorCode(err), "Could not get volume with ID %q: %v", volumeID, err)
 	}
 
-	found := d.isValidVolumeCapabilities(volCaps)
+	var confirmed *csi.ValidateVolumeCapabilitiesResponse_Confirmed
+	if d.isValidVolumeCapabilities(volCaps) {
+		confirmed = &csi.ValidateVolumeCapabilitiesResponse_Confirmed{VolumeCapabilities: volCaps}
+	}
 	return &csi.ValidateVolumeCapabilitiesResponse{
-		Supported: found,
+		Confirmed: confirmed,
+	}, nil
+}
+
+func (d *Driver) ControllerExpandVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest) (*csi.ControllerExpandVolumeResponse, error) {
+	volumeID := req.GetVolumeId()
+	if len(volumeID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
+	}
+
+	capRange := req.GetCapacityRange()
+	if capRange == nil {
+		return nil, status.Error(codes.InvalidArgument, "Capacity range not provided")
+	}
+
+	newSize := util.RoundUpBytes(capRange.GetRequiredBytes())
+	maxVolSize := capRange.GetLimitBytes()
+	if (maxVolSize > 0) && (maxVolSize < newSize) {
+		return nil, status.Error(codes.InvalidArgument, "After round-up, volume size exceeds the limit specified")
+	}
+
+	key := volumeIDKey(volumeID)
+	if ok := d.inFlight.insert(key); !ok {
+		return nil, errOperationInFlight(volumeID)
+	}
+	defer d.inFlight.delete(key)
+
+	actualSizeGiB, err := d.cloud.ResizeDisk(ctx, volumeID, newSize)
+	if err != nil {
+		switch err {
+		case cloud.ErrNotFound:
+			return nil, status.Errorf(codes.NotFound, "Volume %q not found", volumeID)
+		case cloud.ErrModificationTimeout:
+			return nil, status.Errorf(codes.DeadlineExceeded, "Could not resize volume %q: %v", volumeID, err)
+		}
+		return nil, status.Errorf(cloudErrorCode(err), "Could not resize volume %q: %v", volumeID, err)
+	}
+
+	// The filesystem is grown by the node, which is a no-op for raw block volumes
+	return &csi.ControllerExpandVolumeResponse{
+		CapacityBytes:         util.GiBToBytes(actualSizeGiB),
+		NodeExpansionRequired: true,
 	}, nil
 }
 
+// cloudErrorCode returns the code of an unexpected cloud provider error. Requests throttled by
+// AWS are reported as unavailable so that the CO retries them later.
+func cloudErrorCode(err error) codes.Code {
+	if cloud.IsThrottlingError(err) {
+		return codes.Unavailable
+	}
+	return codes.Internal
+}
+
 func (d *Driver) isValidVolumeCapabilities(volCaps []*csi.VolumeCapability) bool {
 	hasSupport := func(cap *csi.VolumeCapability) bool {
+		// Volumes can either be used as raw block devices or have a filesystem mounted.
+		if cap.GetBlock() == nil && cap.GetMount() == nil {
+			return false
+		}
+		if fsType := cap.GetMount().GetFsType(); len(fsType) != 0 && !isSupportedFsType(fsType) {
+			return false
+		}
 		for _, c := range d.volumeCaps {
 			if c.GetMode() == cap.AccessMode.GetMode() {
 				return true
@@ -242,48 +426,252 @@ func (d *Driver) isValidVolumeCapabilities(volCaps []*csi.VolumeCapability) bool
 }
 
 func (d *Driver) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshotRequest) (*csi.CreateSnapshotResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	snapshotName := req.GetName()
+	if len(snapshotName) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Snapshot name not provided")
+	}
+
+	key := snapshotNameKey(snapshotName)
+	if ok := d.inFlight.insert(key); !ok {
+		return nil, errOperationInFlight(snapshotName)
+	}
+	defer d.inFlight.delete(key)
+
+	volumeID := req.GetSourceVolumeId()
+	if len(volumeID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Snapshot volume source ID not provided")
+	}
+
+	snapshot, err := d.cloud.GetSnapshotByName(ctx, snapshotName)
+	if err != nil && err != cloud.ErrNotFound {
+		return nil, status.Errorf(cloudErrorCode(err), "Could not get snapshot with name %q: %v", snapshotName, err)
+	}
+
+	// snapshot exists already
+	if snapshot != nil {
+		if snapshot.SourceVolumeID != volumeID {
+			return nil, status.Errorf(codes.AlreadyExists, "Snapshot %q already exists for a different source volume", snapshotName)
+		}
+		if snapshot.Failed {
+			return nil, status.Errorf(codes.Internal, "Snapshot %q failed and must be deleted", snapshotName)
+		}
+		return newCreateSnapshotResponse(snapshot), nil
+	}
+
+	// create a new snapshot
+	opts := &cloud.SnapshotOptions{
+		Tags: map[string]string{cloud.SnapshotNameTagKey: snapshotName},
+	}
+	snapshot, err = d.cloud.CreateSnapshot(ctx, volumeID, opts)
+	if err != nil {
+		if err == cloud.ErrNotFound {
+			return nil, status.Errorf(codes.NotFound, "Source volume %q not found", volumeID)
+		}
+		return nil, status.Errorf(cloudErrorCode(err), "Could not create snapshot %q: %v", snapshotName, err)
+	}
+	return newCreateSnapshotResponse(snapshot), nil
 }
 
 func (d *Driver) DeleteSnapshot(ctx context.Context, req *csi.DeleteSnapshotRequest) (*csi.DeleteSnapshotResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	snapshotID := req.GetSnapshotId()
+	if len(snapshotID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Snapshot ID not provided")
+	}
+
+	key := snapshotIDKey(snapshotID)
+	if ok := d.inFlight.insert(key); !ok {
+		return nil, errOperationInFlight(snapshotID)
+	}
+	defer d.inFlight.delete(key)
+
+	if _, err := d.cloud.DeleteSnapshot(ctx, snapshotID); err != nil {
+		if err == cloud.ErrNotFound {
+			logging.FromContext(ctx).V(4).Infof("DeleteSnapshot: snapshot not found, returning with success")
+			return &csi.DeleteSnapshotResponse{}, nil
+		}
+		return nil, status.Errorf(cloudErrorCode(err), "Could not delete snapshot ID %q: %v", snapshotID, err)
+	}
+
+	return &csi.DeleteSnapshotResponse{}, nil
 }
 
 func (d *Driver) ListSnapshots(ctx context.Context, req *csi.ListSnapshotsRequest) (*csi.ListSnapshotsResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	snapshotID := req.GetSnapshotId()
+	if len(snapshotID) != 0 {
+		snapshot, err := d.cloud.GetSnapshotByID(ctx, snapshotID)
+		if err != nil {
+			if err == cloud.ErrNotFound {
+				return &csi.ListSnapshotsResponse{}, nil
+			}
+			return nil, status.Errorf(cloudErrorCode(err), "Could not get snapshot ID %q: %v", snapshotID, err)
+		}
+		if volumeID := req.GetSourceVolumeId(); len(volumeID) != 0 && snapshot.SourceVolumeID != volumeID {
+			return &csi.ListSnapshotsResponse{}, nil
+		}
+		return newListSnapshotsResponse(&cloud.ListSnapshotsResponse{Snapshots: []*cloud.Snapshot{snapshot}}), nil
+	}
+
+	maxEntries := req.GetMaxEntries()
+	if maxEntries < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid max entries %d", maxEntries)
+	}
+
+	resp, err := d.cloud.ListSnapshots(ctx, req.GetSourceVolumeId(), int64(maxEntries), req.GetStartingToken())
+	if err != nil {
+		switch err {
+		case cloud.ErrInvalidNextToken:
+			return nil, status.Error(codes.Aborted, err.Error())
+		default:
+			return nil, status.Errorf(cloudErrorCode(err), "Could not list snapshots: %v", err)
+		}
+	}
+
+	return newListSnapshotsResponse(resp), nil
 }
 
-// pickAvailabilityZone selects 1 zone given topology requirement.
-// if not found, empty string is returned.
-func pickAvailabilityZone(requirement *csi.TopologyRequirement) string {
-	if requirement == nil {
-		return ""
+// pickAvailabilityZone selects the zone of a new volume among the valid zones of the region.
+// The first valid preferred zone is picked, as preferred zones are ordered. Otherwise, the zone
+// is picked among the valid requisite zones by hashing the volume name. This spreads volumes
+// across zones, while retries of the same volume pick the same zone. When the valid zones are
+// unknown, all the requested zones are valid. No zone is picked if the requirement names none,
+// so that the volume is created in the zone of the driver, and an error is returned if it names
+// no valid zone.
+func pickAvailabilityZone(requirement *csi.TopologyRequirement, volumeName string, validZones []string) (string, error) {
+	valid := make(map[string]bool, len(validZones))
+	for _, zone := range validZones {
+		valid[zone] = true
+	}
+	isValid := func(zone string) bool {
+		return len(validZones) == 0 || valid[zone]
 	}
+
+	var named []string
 	for _, topology := range requirement.GetPreferred() {
-		zone, exists := topology.GetSegments()[topologyKey]
-		if exists {
-			return zone
+		if zone, exists := zoneFromSegments(topology.GetSegments()); exists {
+			if isValid(zone) {
+				return zone, nil
+			}
+			named = append(named, zone)
 		}
 	}
+
+	candidates := map[string]bool{}
 	for _, topology := range requirement.GetRequisite() {
-		zone, exists := topology.GetSegments()[topologyKey]
-		if exists {
-			return zone
+		if zone, exists := zoneFromSegments(topology.GetSegments()); exists {
+			if isValid(zone) {
+				candidates[zone] = true
+			}
+			named = append(named, zone)
 		}
 	}
-	return ""
+	if len(named) == 0 {
+		return "", nil
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("none of the requested availability zones %v is valid in the region", named)
+	}
+
+	zones := make([]string, 0, len(candidates))
+	for zone := range candidates {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	hash := fnv.New32a()
+	hash.Write([]byte(volumeName))
+	return zones[hash.Sum32()%uint32(len(zones))], nil
 }
 
-func newCreateVolumeResponse(disk *cloud.Disk) *csi.CreateVolumeResponse {
+// zoneFromSegments returns the zone of topology segments, which is set with either topology key.
+func zoneFromSegments(segments map[string]string) (string, bool) {
+	if zone, exists := segments[wellKnownTopologyKey]; exists {
+		return zone, true
+	}
+	zone, exists := segments[topologyKey]
+	return zone, exists
+}
+
+// newZoneTopology returns the topology of a zone, with both topology keys.
+func newZoneTopology(zone string) *csi.Topology {
+	return &csi.Topology{
+		Segments: map[string]string{
+			topologyKey:          zone,
+			wellKnownTopologyKey: zone,
+		},
+	}
+}
+
+func newCreateVolumeResponse(disk *cloud.Disk, attributes map[string]string) *csi.CreateVolumeResponse {
 	return &csi.CreateVolumeResponse{
-		Volume: &csi.Volume{
-			Id:            disk.VolumeID,
-			CapacityBytes: util.GiBToBytes(disk.CapacityGiB),
-			AccessibleTopology: []*csi.Topology{
-				&csi.Topology{
-					Segments: map[string]string{topologyKey: disk.AvailabilityZone},
+		Volume: newCSIVolume(disk, attributes),
+	}
+}
+
+func newListVolumesResponse(resp *cloud.ListDisksResponse) *csi.ListVolumesResponse {
+	var entries []*csi.ListVolumesResponse_Entry
+	for _, disk := range resp.Disks {
+		entries = append(entries, &csi.ListVolumesResponse_Entry{
+			Volume: newCSIVolume(disk, nil),
+		})
+	}
+	return &csi.ListVolumesResponse{
+		Entries:   entries,
+		NextToken: resp.NextToken,
+	}
+}
+
+// newCSIVolume converts a cloud disk into its CSI representation.
+func newCSIVolume(disk *cloud.Disk, attributes map[string]string) *csi.Volume {
+	var src *csi.VolumeContentSource
+	if disk.SnapshotID != "" {
+		src = &csi.VolumeContentSource{
+			Type: &csi.VolumeContentSource_Snapshot{
+				Snapshot: &csi.VolumeContentSource_SnapshotSource{
+					SnapshotId: disk.SnapshotID,
 				},
 			},
+		}
+	}
+
+	return &csi.Volume{
+		VolumeId:           disk.VolumeID,
+		CapacityBytes:      util.GiBToBytes(disk.CapacityGiB),
+		VolumeContext:      attributes,
+		AccessibleTopology: []*csi.Topology{newZoneTopology(disk.AvailabilityZone)},
+		ContentSource:      src,
+	}
+}
+
+func newCreateSnapshotResponse(snapshot *cloud.Snapshot) *csi.CreateSnapshotResponse {
+	return &csi.CreateSnapshotResponse{
+		Snapshot: newCSISnapshot(snapshot),
+	}
+}
+
+func newListSnapshotsResponse(resp *cloud.ListSnapshotsResponse) *csi.ListSnapshotsResponse {
+	var entries []*csi.ListSnapshotsResponse_Entry
+	for _, snapshot := range resp.Snapshots {
+		entries = append(entries, &csi.ListSnapshotsResponse_Entry{
+			Snapshot: newCSISnapshot(snapshot),
+		})
+	}
+	return &csi.ListSnapshotsResponse{
+		Entries:   entries,
+		NextToken: resp.NextToken,
+	}
+}
+
+// newCSISnapshot converts a cloud snapshot into its CSI representation. EBS snapshots are
+// point-in-time as soon as they are created, but they are only ready to use once EC2 marks them
+// as completed.
+func newCSISnapshot(snapshot *cloud.Snapshot) *csi.Snapshot {
+	return &csi.Snapshot{
+		SnapshotId:     snapshot.SnapshotID,
+		SourceVolumeId: snapshot.SourceVolumeID,
+		SizeBytes:      util.GiBToBytes(snapshot.SizeGiB),
+		CreationTime: &timestamp.Timestamp{
+			Seconds: snapshot.CreationTime.Unix(),
+			Nanos:   int32(snapshot.CreationTime.Nanosecond()),
 		},
+		ReadyToUse: snapshot.ReadyToUse,
 	}
 }
@@ -18,9 +18,12 @@ package driver
 
 import (
 	"context"
+	"fmt"
+	"reflect"
 	"testing"
 
-	csi "github.com/container-storage-interface/spec/lib/go/csi/v0"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/cloud"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -58,10 +61,46 @@ func TestCreateVolume(t *testing.T) {
 			},
 			expVol: &csi.Volume{
 				CapacityBytes: stdVolSize,
-				Id:            "vol-test",
-				Attributes:    nil,
+				VolumeId:      "vol-test",
+				VolumeContext: nil,
 			},
 		},
+		{
+			name: "success accessibility requirement",
+			req: &csi.CreateVolumeRequest{
+				Name:               "random-vol-name",
+				CapacityRange:      stdCapRange,
+				VolumeCapabilities: stdVolCap,
+				AccessibilityRequirements: &csi.TopologyRequirement{
+					Requisite: []*csi.Topology{
+						{Segments: map[string]string{topologyKey: "unknown-az"}},
+						{Segments: map[string]string{topologyKey: "az"}},
+					},
+				},
+			},
+			expVol: &csi.Volume{
+				CapacityBytes: stdVolSize,
+				VolumeId:      "vol-test",
+				VolumeContext: nil,
+				AccessibleTopology: []*csi.Topology{
+					{Segments: map[string]string{topologyKey: "az", wellKnownTopologyKey: "az"}},
+				},
+			},
+		},
+		{
+			name: "fail accessibility requirement with unknown zones",
+			req: &csi.CreateVolumeRequest{
+				Name:               "random-vol-name",
+				CapacityRange:      stdCapRange,
+				VolumeCapabilities: stdVolCap,
+				AccessibilityRequirements: &csi.TopologyRequirement{
+					Requisite: []*csi.Topology{
+						{Segments: map[string]string{topologyKey: "unknown-az"}},
+					},
+				},
+			},
+			expErrCode: codes.ResourceExhausted,
+		},
 		{
 			name: "fail no name",
 			req: &csi.CreateVolumeRequest{
@@ -72,6 +111,23 @@ func TestCreateVolume(t *testing.T) {
 			},
 			expErrCode: codes.InvalidArgument,
 		},
+		{
+			name: "fail unsupported fsType in volume capability",
+			req: &csi.CreateVolumeRequest{
+				Name:          "random-vol-name",
+				CapacityRange: stdCapRange,
+				VolumeCapabilities: []*csi.VolumeCapability{
+					{
+						AccessType: &csi.VolumeCapability_Mount{
+							Mount: &csi.VolumeCapability_MountVolume{FsType: "vfat"},
+						},
+						AccessMode: stdVolCap[0].AccessMode,
+					},
+				},
+				Parameters: stdParams,
+			},
+			expErrCode: codes.InvalidArgument,
+		},
 		{
 			name: "success same name and same capacity",
 			req: &csi.CreateVolumeRequest{
@@ -88,8 +144,8 @@ func TestCreateVolume(t *testing.T) {
 			},
 			expVol: &csi.Volume{
 				CapacityBytes: stdVolSize,
-				Id:            "vol-test",
-				Attributes:    nil,
+				VolumeId:      "vol-test",
+				VolumeContext: nil,
 			},
 		},
 		{
@@ -117,9 +173,73 @@ func TestCreateVolume(t *testing.T) {
 			},
 			expVol: &csi.Volume{
 				CapacityBytes: cloud.DefaultVolumeSize,
-				Id:            "vol-test",
-				Attributes:    nil,
+				VolumeId:      "vol-test",
+				VolumeContext: nil,
+			},
+		},
+		{
+			name: "success with parameters",
+			req: &csi.CreateVolumeRequest{
+				Name:               "vol-test",
+				CapacityRange:      stdCapRange,
+				VolumeCapabilities: stdVolCap,
+				Parameters: map[string]string{
+					VolumeTypeKey: "io1",
+					IopsPerGBKey:  "10",
+					FsTypeKey:     "xfs",
+				},
+			},
+			expVol: &csi.Volume{
+				CapacityBytes: stdVolSize,
+				VolumeId:      "vol-test",
+				VolumeContext: map[string]string{FsTypeKey: "xfs"},
+			},
+		},
+		{
+			name: "fail same name and different encryption",
+			req: &csi.CreateVolumeRequest{
+				Name:               "test-vol",
+				CapacityRange:      stdCapRange,
+				VolumeCapabilities: stdVolCap,
+				Parameters:         stdParams,
+			},
+			extraReq: &csi.CreateVolumeRequest{
+				Name:               "test-vol",
+				CapacityRange:      stdCapRange,
+				VolumeCapabilities: stdVolCap,
+				Parameters:         map[string]string{EncryptedKey: "true"},
+			},
+			expErrCode: codes.AlreadyExists,
+		},
+		{
+			name: "success same name and encrypted disk for unencrypted request",
+			req: &csi.CreateVolumeRequest{
+				Name:               "test-vol",
+				CapacityRange:      stdCapRange,
+				VolumeCapabilities: stdVolCap,
+				Parameters:         map[string]string{EncryptedKey: "true"},
+			},
+			extraReq: &csi.CreateVolumeRequest{
+				Name:               "test-vol",
+				CapacityRange:      stdCapRange,
+				VolumeCapabilities: stdVolCap,
+				Parameters:         stdParams,
+			},
+			expVol: &csi.Volume{
+				CapacityBytes: stdVolSize,
+				VolumeId:      "vol-test",
+				VolumeContext: nil,
+			},
+		},
+		{
+			name: "fail with unknown parameter",
+			req: &csi.CreateVolumeRequest{
+				Name:               "vol-test",
+				CapacityRange:      stdCapRange,
+				VolumeCapabilities: stdVolCap,
+				Parameters:         map[string]string{"unknown": "value"},
 			},
+			expErrCode: codes.InvalidArgument,
 		},
 		{
 			name: "success with correct round up",
@@ -131,8 +251,8 @@ func TestCreateVolume(t *testing.T) {
 			},
 			expVol: &csi.Volume{
 				CapacityBytes: 2147483648, // 1 GiB + 1 byte = 2 GiB
-				Id:            "vol-test",
-				Attributes:    nil,
+				VolumeId:      "vol-test",
+				VolumeContext: nil,
 			},
 		},
 	}
@@ -181,14 +301,329 @@ func TestCreateVolume(t *testing.T) {
 			t.Fatalf("Expected volume capacity bytes: %v, got: %v", tc.expVol.GetCapacityBytes(), vol.GetCapacityBytes())
 		}
 
-		for expKey, expVal := range tc.expVol.GetAttributes() {
-			attrs := vol.GetAttributes()
+		for expKey, expVal := range tc.expVol.GetVolumeContext() {
+			attrs := vol.GetVolumeContext()
 			if gotVal, ok := attrs[expKey]; !ok || gotVal != expVal {
 				t.Fatalf("Expected volume attribute for key %v: %v, got: %v", expKey, expVal, gotVal)
 			}
 		}
-		if tc.expVol.GetAttributes() == nil && vol.GetAttributes() != nil {
-			t.Fatalf("Expected volume attributes to be nil, got: %#v", vol.GetAttributes())
+		if tc.expVol.GetVolumeContext() == nil && vol.GetVolumeContext() != nil {
+			t.Fatalf("Expected volume attributes to be nil, got: %#v", vol.GetVolumeContext())
+		}
+		if tc.expVol.GetAccessibleTopology() != nil && !reflect.DeepEqual(vol.GetAccessibleTopology(), tc.expVol.GetAccessibleTopology()) {
+			t.Fatalf("Expected accessible topology %v, got: %v", tc.expVol.GetAccessibleTopology(), vol.GetAccessibleTopology())
+		}
+	}
+}
+
+// createDiskErrorCloud fails every CreateDisk call with the given error.
+type createDiskErrorCloud struct {
+	cloud.Cloud
+	err error
+}
+
+func (c *createDiskErrorCloud) CreateDisk(ctx context.Context, volumeName string, diskOptions *cloud.DiskOptions) (*cloud.Disk, error) {
+	return nil, c.err
+}
+
+func TestCreateVolumeCloudErrors(t *testing.T) {
+	testCases := []struct {
+		name       string
+		err        error
+		expErrCode codes.Code
+	}{
+		{
+			name:       "invalid KMS key",
+			err:        cloud.ErrInvalidKMSKey,
+			expErrCode: codes.InvalidArgument,
+		},
+		{
+			name:       "idempotent parameter mismatch",
+			err:        cloud.ErrIdempotentParameterMismatch,
+			expErrCode: codes.AlreadyExists,
+		},
+		{
+			name:       "creation timeout",
+			err:        cloud.ErrCreationTimeout,
+			expErrCode: codes.DeadlineExceeded,
+		},
+		{
+			name:       "invalid disk options",
+			err:        fmt.Errorf("%w: 50 IOPS is below the minimum of 100 IOPS of volume type \"io1\"", cloud.ErrInvalidDiskOptions),
+			expErrCode: codes.InvalidArgument,
+		},
+		{
+			name:       "capacity out of range",
+			err:        fmt.Errorf("%w: 1 GiB is out of the 125-16384 GiB range of volume type \"st1\"", cloud.ErrCapacityOutOfRange),
+			expErrCode: codes.OutOfRange,
+		},
+		{
+			name:       "throttled",
+			err:        fmt.Errorf("could not create volume in EC2: %w", awserr.New("RequestLimitExceeded", "Request limit exceeded.", nil)),
+			expErrCode: codes.Unavailable,
+		},
+		{
+			name:       "volume in error state",
+			err:        fmt.Errorf("volume \"vol-test\" is in error state and was deleted"),
+			expErrCode: codes.Internal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		fakeCloud := &createDiskErrorCloud{Cloud: cloud.NewFakeCloudProvider(), err: tc.err}
+		awsDriver := NewDriver(fakeCloud, NewFakeMounter(), "")
+
+		req := &csi.CreateVolumeRequest{
+			Name: "random-vol-name",
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessType: &csi.VolumeCapability_Mount{
+						Mount: &csi.VolumeCapability_MountVolume{},
+					},
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+		}
+		_, err := awsDriver.CreateVolume(context.TODO(), req)
+		if code := status.Code(err); code != tc.expErrCode {
+			t.Fatalf("Expected error code %d, got %d: %v", tc.expErrCode, code, err)
+		}
+	}
+}
+
+// instanceErrorCloud fails every IsExistInstance call with the given error.
+type instanceErrorCloud struct {
+	cloud.Cloud
+	err error
+}
+
+func (c *instanceErrorCloud) IsExistInstance(ctx context.Context, nodeID string) (bool, error) {
+	return false, c.err
+}
+
+func TestControllerPublishVolumeCloudErrors(t *testing.T) {
+	testCases := []struct {
+		name       string
+		nodeID     string
+		err        error
+		expErrCode codes.Code
+	}{
+		{
+			name:       "instance not found",
+			nodeID:     "i-unknown",
+			expErrCode: codes.NotFound,
+		},
+		{
+			name:       "throttled",
+			nodeID:     "instanceID",
+			err:        fmt.Errorf("could not describe instance \"instanceID\": %w", awserr.New("RequestLimitExceeded", "Request limit exceeded.", nil)),
+			expErrCode: codes.Unavailable,
+		},
+		{
+			name:       "generic error",
+			nodeID:     "instanceID",
+			err:        fmt.Errorf("DescribeInstances generic error"),
+			expErrCode: codes.Internal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		var fakeCloud cloud.Cloud = cloud.NewFakeCloudProvider()
+		if tc.err != nil {
+			fakeCloud = &instanceErrorCloud{Cloud: fakeCloud, err: tc.err}
+		}
+		awsDriver := NewDriver(fakeCloud, NewFakeMounter(), "")
+
+		req := &csi.ControllerPublishVolumeRequest{
+			VolumeId: "vol-test",
+			NodeId:   tc.nodeID,
+			VolumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{
+					Mount: &csi.VolumeCapability_MountVolume{},
+				},
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+				},
+			},
+		}
+		_, err := awsDriver.ControllerPublishVolume(context.TODO(), req)
+		if code := status.Code(err); code != tc.expErrCode {
+			t.Fatalf("Expected error code %d, got %d: %v", tc.expErrCode, code, err)
+		}
+	}
+}
+
+// detachErrorCloud fails the detachments with err.
+type detachErrorCloud struct {
+	cloud.Cloud
+	err error
+}
+
+func (c *detachErrorCloud) DetachDisk(ctx context.Context, volumeID, nodeID string) error {
+	return c.err
+}
+
+func TestControllerUnpublishVolume(t *testing.T) {
+	testCases := []struct {
+		name       string
+		err        error
+		expErrCode codes.Code
+	}{
+		{
+			name:       "success",
+			expErrCode: codes.OK,
+		},
+		{
+			name:       "success volume not attached",
+			err:        cloud.ErrNotFound,
+			expErrCode: codes.OK,
+		},
+		{
+			name:       "fail detachment timed out",
+			err:        cloud.ErrAttachmentTimeout,
+			expErrCode: codes.DeadlineExceeded,
+		},
+		{
+			name:       "fail generic error",
+			err:        fmt.Errorf("DetachVolume generic error"),
+			expErrCode: codes.Internal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		fakeCloud := &detachErrorCloud{Cloud: cloud.NewFakeCloudProvider(), err: tc.err}
+		awsDriver := NewDriver(fakeCloud, NewFakeMounter(), "")
+
+		req := &csi.ControllerUnpublishVolumeRequest{
+			VolumeId: "vol-test",
+			NodeId:   "instanceID",
+		}
+		_, err := awsDriver.ControllerUnpublishVolume(context.TODO(), req)
+		if code := status.Code(err); code != tc.expErrCode {
+			t.Fatalf("Expected error code %d, got %d: %v", tc.expErrCode, code, err)
+		}
+	}
+}
+
+func TestCreateVolumeFromSnapshot(t *testing.T) {
+	stdVolCap := []*csi.VolumeCapability{
+		{
+			AccessType: &csi.VolumeCapability_Mount{
+				Mount: &csi.VolumeCapability_MountVolume{},
+			},
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+			},
+		},
+	}
+	snapshotVolSize := int64(5 * 1024 * 1024 * 1024)
+	newContentSource := func(snapshotID string) *csi.VolumeContentSource {
+		return &csi.VolumeContentSource{
+			Type: &csi.VolumeContentSource_Snapshot{
+				Snapshot: &csi.VolumeContentSource_SnapshotSource{SnapshotId: snapshotID},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name          string
+		capRange      *csi.CapacityRange
+		unknownSource bool
+		expSize       int64
+		expErrCode    codes.Code
+	}{
+		{
+			name:     "success same size as snapshot",
+			capRange: &csi.CapacityRange{RequiredBytes: snapshotVolSize},
+			expSize:  snapshotVolSize,
+		},
+		{
+			name:     "success bigger than snapshot",
+			capRange: &csi.CapacityRange{RequiredBytes: 2 * snapshotVolSize},
+			expSize:  2 * snapshotVolSize,
+		},
+		{
+			name:    "success no capacity range",
+			expSize: snapshotVolSize,
+		},
+		{
+			name:       "fail smaller than snapshot",
+			capRange:   &csi.CapacityRange{RequiredBytes: snapshotVolSize / 5},
+			expErrCode: codes.OutOfRange,
+		},
+		{
+			name:          "fail snapshot not found",
+			capRange:      &csi.CapacityRange{RequiredBytes: snapshotVolSize},
+			unknownSource: true,
+			expErrCode:    codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		awsDriver := NewDriver(cloud.NewFakeCloudProvider(), NewFakeMounter(), "")
+
+		srcVol, err := awsDriver.CreateVolume(context.TODO(), &csi.CreateVolumeRequest{
+			Name:               "source-vol",
+			CapacityRange:      &csi.CapacityRange{RequiredBytes: snapshotVolSize},
+			VolumeCapabilities: stdVolCap,
+		})
+		if err != nil {
+			t.Fatalf("Could not create source volume: %v", err)
+		}
+		snap, err := awsDriver.CreateSnapshot(context.TODO(), &csi.CreateSnapshotRequest{
+			Name:           "source-snapshot",
+			SourceVolumeId: srcVol.GetVolume().GetVolumeId(),
+		})
+		if err != nil {
+			t.Fatalf("Could not create source snapshot: %v", err)
+		}
+
+		snapshotID := snap.GetSnapshot().GetSnapshotId()
+		if tc.unknownSource {
+			snapshotID = "snap-unknown"
+		}
+		req := &csi.CreateVolumeRequest{
+			Name:                "restored-vol",
+			CapacityRange:       tc.capRange,
+			VolumeCapabilities:  stdVolCap,
+			VolumeContentSource: newContentSource(snapshotID),
+		}
+
+		resp, err := awsDriver.CreateVolume(context.TODO(), req)
+		if err != nil {
+			srvErr, ok := status.FromError(err)
+			if !ok {
+				t.Fatalf("Could not get error status code from error: %v", srvErr)
+			}
+			if srvErr.Code() != tc.expErrCode {
+				t.Fatalf("Expected error code %d, got %d message %s", tc.expErrCode, srvErr.Code(), srvErr.Message())
+			}
+			continue
+		}
+		if tc.expErrCode != codes.OK {
+			t.Fatalf("Expected error %v, got no error", tc.expErrCode)
+		}
+
+		vol := resp.GetVolume()
+		if vol.GetCapacityBytes() != tc.expSize {
+			t.Fatalf("Expected volume capacity bytes: %v, got: %v", tc.expSize, vol.GetCapacityBytes())
+		}
+		if id := vol.GetContentSource().GetSnapshot().GetSnapshotId(); id != snapshotID {
+			t.Fatalf("Expected volume content source snapshot %q, got %q", snapshotID, id)
+		}
+
+		// A volume with the same name but without the content source must not be returned
+		req.VolumeContentSource = nil
+		req.CapacityRange = &csi.CapacityRange{RequiredBytes: vol.GetCapacityBytes()}
+		_, err = awsDriver.CreateVolume(context.TODO(), req)
+		if srvErr, _ := status.FromError(err); srvErr.Code() != codes.AlreadyExists {
+			t.Fatalf("Expected error code %d, got %v", codes.AlreadyExists, err)
 		}
 	}
 }
@@ -236,62 +671,639 @@ func TestDeleteVolume(t *testing.T) {
 	}
 }
 
-func TestPickAvailabilityZone(t *testing.T) {
-	expZone := "us-west-2b"
+func TestControllerExpandVolume(t *testing.T) {
+	stdVolSize := int64(5 * 1024 * 1024 * 1024)
+
 	testCases := []struct {
-		name        string
-		requirement *csi.TopologyRequirement
-		expZone     string
+		name       string
+		unknownVol bool
+		noVolumeID bool
+		capRange   *csi.CapacityRange
+		expSize    int64
+		expErrCode codes.Code
 	}{
 		{
-			name: "Pick from preferred",
-			requirement: &csi.TopologyRequirement{
-				Requisite: []*csi.Topology{
-					&csi.Topology{
-						Segments: map[string]string{topologyKey: expZone},
-					},
-				},
-				Preferred: []*csi.Topology{
-					&csi.Topology{
-						Segments: map[string]string{topologyKey: expZone},
-					},
-				},
-			},
-			expZone: expZone,
+			name:     "success normal",
+			capRange: &csi.CapacityRange{RequiredBytes: 2 * stdVolSize},
+			expSize:  2 * stdVolSize,
 		},
 		{
-			name: "Pick from requisite",
-			requirement: &csi.TopologyRequirement{
+			name:     "success size rounded up",
+			capRange: &csi.CapacityRange{RequiredBytes: 2*stdVolSize + 1},
+			expSize:  2*stdVolSize + 1024*1024*1024,
+		},
+		{
+			name:     "success already big enough",
+			capRange: &csi.CapacityRange{RequiredBytes: stdVolSize / 5},
+			expSize:  stdVolSize,
+		},
+		{
+			name:       "fail no volume id",
+			noVolumeID: true,
+			capRange:   &csi.CapacityRange{RequiredBytes: 2 * stdVolSize},
+			expErrCode: codes.InvalidArgument,
+		},
+		{
+			name:       "fail no capacity range",
+			expErrCode: codes.InvalidArgument,
+		},
+		{
+			name:       "fail size over limit",
+			capRange:   &csi.CapacityRange{RequiredBytes: 2*stdVolSize + 1, LimitBytes: 2 * stdVolSize},
+			expErrCode: codes.InvalidArgument,
+		},
+		{
+			name:       "fail volume not found",
+			unknownVol: true,
+			capRange:   &csi.CapacityRange{RequiredBytes: 2 * stdVolSize},
+			expErrCode: codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		awsDriver := NewDriver(cloud.NewFakeCloudProvider(), NewFakeMounter(), "")
+
+		createResp, err := awsDriver.CreateVolume(context.TODO(), &csi.CreateVolumeRequest{
+			Name:          "random-vol-name",
+			CapacityRange: &csi.CapacityRange{RequiredBytes: stdVolSize},
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessType: &csi.VolumeCapability_Mount{
+						Mount: &csi.VolumeCapability_MountVolume{},
+					},
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Could not create volume: %v", err)
+		}
+		volumeID := createResp.GetVolume().GetVolumeId()
+		if tc.unknownVol {
+			volumeID = "vol-unknown"
+		}
+		if tc.noVolumeID {
+			volumeID = ""
+		}
+
+		resp, err := awsDriver.ControllerExpandVolume(context.TODO(), &csi.ControllerExpandVolumeRequest{
+			VolumeId:      volumeID,
+			CapacityRange: tc.capRange,
+		})
+		if err != nil {
+			srvErr, ok := status.FromError(err)
+			if !ok {
+				t.Fatalf("Could not get error status code from error: %v", srvErr)
+			}
+			if srvErr.Code() != tc.expErrCode {
+				t.Fatalf("Expected error code %d, got %d message %s", tc.expErrCode, srvErr.Code(), srvErr.Message())
+			}
+			continue
+		}
+		if tc.expErrCode != codes.OK {
+			t.Fatalf("Expected error %v, got no error", tc.expErrCode)
+		}
+
+		if resp.GetCapacityBytes() != tc.expSize {
+			t.Fatalf("Expected capacity %d, got %d", tc.expSize, resp.GetCapacityBytes())
+		}
+		if !resp.GetNodeExpansionRequired() {
+			t.Fatal("Expected node expansion to be required")
+		}
+	}
+}
+
+func TestListVolumes(t *testing.T) {
+	awsDriver := NewDriver(cloud.NewFakeCloudProvider(), NewFakeMounter(), "")
+	for i := 0; i < 3; i++ {
+		req := &csi.CreateVolumeRequest{
+			Name: fmt.Sprintf("vol-%d", i),
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessType: &csi.VolumeCapability_Mount{
+						Mount: &csi.VolumeCapability_MountVolume{},
+					},
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+		}
+		if _, err := awsDriver.CreateVolume(context.TODO(), req); err != nil {
+			t.Fatalf("Could not create volume: %v", err)
+		}
+	}
+
+	testCases := []struct {
+		name         string
+		req          *csi.ListVolumesRequest
+		expEntries   int
+		expNextToken string
+		expErrCode   codes.Code
+	}{
+		{
+			name:       "success all volumes",
+			req:        &csi.ListVolumesRequest{},
+			expEntries: 3,
+		},
+		{
+			name:         "success limited entries",
+			req:          &csi.ListVolumesRequest{MaxEntries: 2},
+			expEntries:   2,
+			expNextToken: "2",
+		},
+		{
+			name:         "success single entry",
+			req:          &csi.ListVolumesRequest{MaxEntries: 1},
+			expEntries:   1,
+			expNextToken: "1",
+		},
+		{
+			name:       "success starting token",
+			req:        &csi.ListVolumesRequest{StartingToken: "2"},
+			expEntries: 1,
+		},
+		{
+			name:       "fail invalid starting token",
+			req:        &csi.ListVolumesRequest{StartingToken: "invalid-token"},
+			expErrCode: codes.Aborted,
+		},
+		{
+			name:       "fail negative max entries",
+			req:        &csi.ListVolumesRequest{MaxEntries: -1},
+			expErrCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		resp, err := awsDriver.ListVolumes(context.TODO(), tc.req)
+		if err != nil {
+			srvErr, ok := status.FromError(err)
+			if !ok {
+				t.Fatalf("Could not get error status code from error: %v", srvErr)
+			}
+			if srvErr.Code() != tc.expErrCode {
+				t.Fatalf("Expected error code %d, got %d", tc.expErrCode, srvErr.Code())
+			}
+			continue
+		}
+		if tc.expErrCode != codes.OK {
+			t.Fatalf("Expected error %v, got no error", tc.expErrCode)
+		}
+		if len(resp.GetEntries()) != tc.expEntries {
+			t.Fatalf("Expected %d entries, got %d", tc.expEntries, len(resp.GetEntries()))
+		}
+		if resp.GetNextToken() != tc.expNextToken {
+			t.Fatalf("Expected next token %q, got %q", tc.expNextToken, resp.GetNextToken())
+		}
+	}
+}
+
+func TestPickAvailabilityZone(t *testing.T) {
+	validZones := []string{"us-west-2a", "us-west-2b", "us-west-2c"}
+	testCases := []struct {
+		name        string
+		requirement *csi.TopologyRequirement
+		// unknownZones is set when the valid zones of the region are unknown
+		unknownZones bool
+		// expZones are the zones the volume may be created in
+		expZones []string
+		expErr   bool
+	}{
+		{
+			name: "Pick from preferred",
+			requirement: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{
+					&csi.Topology{
+						Segments: map[string]string{topologyKey: "us-west-2a"},
+					},
+					&csi.Topology{
+						Segments: map[string]string{topologyKey: "us-west-2b"},
+					},
+				},
+				Preferred: []*csi.Topology{
+					&csi.Topology{
+						Segments: map[string]string{topologyKey: "us-west-2b"},
+					},
+					&csi.Topology{
+						Segments: map[string]string{topologyKey: "us-west-2a"},
+					},
+				},
+			},
+			expZones: []string{"us-west-2b"},
+		},
+		{
+			name: "Skip invalid preferred",
+			requirement: &csi.TopologyRequirement{
+				Preferred: []*csi.Topology{
+					&csi.Topology{
+						Segments: map[string]string{topologyKey: "us-east-1a"},
+					},
+					&csi.Topology{
+						Segments: map[string]string{topologyKey: "us-west-2c"},
+					},
+				},
+			},
+			expZones: []string{"us-west-2c"},
+		},
+		{
+			name: "Pick from requisite",
+			requirement: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{
+					&csi.Topology{
+						Segments: map[string]string{topologyKey: "us-west-2b"},
+					},
+				},
+			},
+			expZones: []string{"us-west-2b"},
+		},
+		{
+			name: "Pick from valid requisite",
+			requirement: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{
+					&csi.Topology{
+						Segments: map[string]string{topologyKey: "us-east-1a"},
+					},
+					&csi.Topology{
+						Segments: map[string]string{topologyKey: "us-west-2a"},
+					},
+					&csi.Topology{
+						Segments: map[string]string{topologyKey: "us-west-2c"},
+					},
+				},
+				Preferred: []*csi.Topology{
+					&csi.Topology{
+						Segments: map[string]string{topologyKey: "us-east-1a"},
+					},
+				},
+			},
+			expZones: []string{"us-west-2a", "us-west-2c"},
+		},
+		{
+			name: "Pick from requisite with well-known key",
+			requirement: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{
+					&csi.Topology{
+						Segments: map[string]string{wellKnownTopologyKey: "us-west-2c"},
+					},
+				},
+				Preferred: []*csi.Topology{
+					&csi.Topology{
+						Segments: map[string]string{wellKnownTopologyKey: "us-west-2c"},
+					},
+				},
+			},
+			expZones: []string{"us-west-2c"},
+		},
+		{
+			name: "Pick from requisite with both keys",
+			requirement: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{
+					&csi.Topology{
+						Segments: map[string]string{topologyKey: "us-west-2a", wellKnownTopologyKey: "us-west-2a"},
+					},
+					&csi.Topology{
+						Segments: map[string]string{wellKnownTopologyKey: "us-west-2b"},
+					},
+				},
+			},
+			expZones: []string{"us-west-2a", "us-west-2b"},
+		},
+		{
+			name: "No valid zone",
+			requirement: &csi.TopologyRequirement{
 				Requisite: []*csi.Topology{
 					&csi.Topology{
-						Segments: map[string]string{topologyKey: expZone},
+						Segments: map[string]string{topologyKey: "us-east-1a"},
+					},
+				},
+				Preferred: []*csi.Topology{
+					&csi.Topology{
+						Segments: map[string]string{topologyKey: "us-east-1a"},
 					},
 				},
 			},
-			expZone: expZone,
+			expErr: true,
 		},
 		{
-			name: "Pick from empty topology",
+			name: "No zone picked from empty topology",
 			requirement: &csi.TopologyRequirement{
 				Preferred: []*csi.Topology{&csi.Topology{}},
 				Requisite: []*csi.Topology{&csi.Topology{}},
 			},
-			expZone: "",
+			expZones: []string{""},
 		},
 		{
-			name:        "Topology Requirement is nil",
+			name:        "No zone picked when topology requirement is nil",
 			requirement: nil,
-			expZone:     "",
+			expZones:    []string{""},
+		},
+		{
+			name: "Pick from requisite with unknown zones",
+			requirement: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{
+					&csi.Topology{
+						Segments: map[string]string{topologyKey: "us-east-1a"},
+					},
+				},
+			},
+			unknownZones: true,
+			expZones:     []string{"us-east-1a"},
+		},
+		{
+			name:         "No zone picked with unknown zones and no requirement",
+			requirement:  nil,
+			unknownZones: true,
+			expZones:     []string{""},
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			actual := pickAvailabilityZone(tc.requirement)
-			if actual != tc.expZone {
-				t.Fatalf("Expected zone %v, got zone: %v", tc.expZone, actual)
+			zones := validZones
+			if tc.unknownZones {
+				zones = nil
+			}
+			actual, err := pickAvailabilityZone(tc.requirement, "test-vol", zones)
+			if err != nil {
+				if !tc.expErr {
+					t.Fatalf("pickAvailabilityZone() failed: expected no error, got: %v", err)
+				}
+				return
+			}
+			if tc.expErr {
+				t.Fatalf("pickAvailabilityZone() failed: expected error, got zone: %v", actual)
+			}
+			if !containsString(tc.expZones, actual) {
+				t.Fatalf("Expected zone in %v, got zone: %v", tc.expZones, actual)
 			}
 		})
 	}
+}
+
+func TestPickAvailabilityZoneSpread(t *testing.T) {
+	validZones := []string{"us-west-2a", "us-west-2b", "us-west-2c", "us-west-2d"}
+	requirement := &csi.TopologyRequirement{
+		Requisite: []*csi.Topology{
+			&csi.Topology{
+				Segments: map[string]string{topologyKey: "us-west-2c"},
+			},
+			&csi.Topology{
+				Segments: map[string]string{topologyKey: "us-west-2a"},
+			},
+			&csi.Topology{
+				Segments: map[string]string{topologyKey: "us-west-2b"},
+			},
+		},
+	}
+
+	counts := map[string]int{}
+	for i := 0; i < 300; i++ {
+		volumeName := fmt.Sprintf("pvc-%d", i)
+		zone, err := pickAvailabilityZone(requirement, volumeName, validZones)
+		if err != nil {
+			t.Fatalf("pickAvailabilityZone() failed: %v", err)
+		}
+		again, err := pickAvailabilityZone(requirement, volumeName, validZones)
+		if err != nil {
+			t.Fatalf("pickAvailabilityZone() failed: %v", err)
+		}
+		if again != zone {
+			t.Fatalf("Expected volume %q to be in the same zone on retry, got %v and %v", volumeName, zone, again)
+		}
+		counts[zone]++
+	}
+
+	if counts["us-west-2d"] != 0 {
+		t.Fatalf("Expected no volume in a zone that isn't requisite, got %d", counts["us-west-2d"])
+	}
+	for _, zone := range []string{"us-west-2a", "us-west-2b", "us-west-2c"} {
+		// Each zone should get roughly a third of the volumes
+		if counts[zone] < 50 {
+			t.Fatalf("Expected volumes to be spread across requisite zones, got %v", counts)
+		}
+	}
+}
+
+func TestCreateSnapshot(t *testing.T) {
+	testCases := []struct {
+		name       string
+		req        *csi.CreateSnapshotRequest
+		extraReq   *csi.CreateSnapshotRequest
+		expErrCode codes.Code
+	}{
+		{
+			name: "success normal",
+			req: &csi.CreateSnapshotRequest{
+				Name:           "test-snapshot",
+				SourceVolumeId: "vol-test",
+			},
+		},
+		{
+			name: "fail no name",
+			req: &csi.CreateSnapshotRequest{
+				SourceVolumeId: "vol-test",
+			},
+			expErrCode: codes.InvalidArgument,
+		},
+		{
+			name: "fail no source volume",
+			req: &csi.CreateSnapshotRequest{
+				Name: "test-snapshot",
+			},
+			expErrCode: codes.InvalidArgument,
+		},
+		{
+			name: "success same name and same source volume",
+			req: &csi.CreateSnapshotRequest{
+				Name:           "test-snapshot",
+				SourceVolumeId: "vol-test",
+			},
+			extraReq: &csi.CreateSnapshotRequest{
+				Name:           "test-snapshot",
+				SourceVolumeId: "vol-test",
+			},
+		},
+		{
+			name: "fail same name and different source volume",
+			req: &csi.CreateSnapshotRequest{
+				Name:           "test-snapshot",
+				SourceVolumeId: "vol-test",
+			},
+			extraReq: &csi.CreateSnapshotRequest{
+				Name:           "test-snapshot",
+				SourceVolumeId: "vol-xxx",
+			},
+			expErrCode: codes.AlreadyExists,
+		},
+	}
 
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		awsDriver := NewDriver(cloud.NewFakeCloudProvider(), NewFakeMounter(), "")
+
+		resp, err := awsDriver.CreateSnapshot(context.TODO(), tc.req)
+		if err == nil && tc.extraReq != nil {
+			resp, err = awsDriver.CreateSnapshot(context.TODO(), tc.extraReq)
+		}
+		if err != nil {
+			srvErr, ok := status.FromError(err)
+			if !ok {
+				t.Fatalf("Could not get error status code from error: %v", srvErr)
+			}
+			if srvErr.Code() != tc.expErrCode {
+				t.Fatalf("Expected error code %d, got %d message %s", tc.expErrCode, srvErr.Code(), srvErr.Message())
+			}
+			continue
+		}
+		if tc.expErrCode != codes.OK {
+			t.Fatalf("Expected error %v, got no error", tc.expErrCode)
+		}
+
+		snapshot := resp.GetSnapshot()
+		if snapshot.GetSourceVolumeId() != tc.req.GetSourceVolumeId() {
+			t.Fatalf("Expected snapshot source volume %q, got %q", tc.req.GetSourceVolumeId(), snapshot.GetSourceVolumeId())
+		}
+		if !snapshot.GetReadyToUse() {
+			t.Fatal("Expected snapshot to be ready to use")
+		}
+	}
+}
+
+func TestDeleteSnapshot(t *testing.T) {
+	testCases := []struct {
+		name       string
+		req        *csi.DeleteSnapshotRequest
+		expErrCode codes.Code
+	}{
+		{
+			name: "success invalid snapshot id",
+			req: &csi.DeleteSnapshotRequest{
+				SnapshotId: "invalid-snapshot-id",
+			},
+		},
+		{
+			name:       "fail no snapshot id",
+			req:        &csi.DeleteSnapshotRequest{},
+			expErrCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		awsDriver := NewDriver(cloud.NewFakeCloudProvider(), NewFakeMounter(), "")
+		_, err := awsDriver.DeleteSnapshot(context.TODO(), tc.req)
+		if err != nil {
+			srvErr, ok := status.FromError(err)
+			if !ok {
+				t.Fatalf("Could not get error status code from error: %v", srvErr)
+			}
+			if srvErr.Code() != tc.expErrCode {
+				t.Fatalf("Expected error code %d, got %d", tc.expErrCode, srvErr.Code())
+			}
+			continue
+		}
+		if tc.expErrCode != codes.OK {
+			t.Fatalf("Expected error %v, got no error", tc.expErrCode)
+		}
+	}
+}
+
+func TestListSnapshots(t *testing.T) {
+	awsDriver := NewDriver(cloud.NewFakeCloudProvider(), NewFakeMounter(), "")
+	var snapshotIDs []string
+	for _, volumeID := range []string{"vol-1", "vol-1", "vol-2"} {
+		req := &csi.CreateSnapshotRequest{
+			Name:           fmt.Sprintf("snapshot-%d", len(snapshotIDs)),
+			SourceVolumeId: volumeID,
+		}
+		resp, err := awsDriver.CreateSnapshot(context.TODO(), req)
+		if err != nil {
+			t.Fatalf("Could not create snapshot: %v", err)
+		}
+		snapshotIDs = append(snapshotIDs, resp.GetSnapshot().GetSnapshotId())
+	}
+
+	testCases := []struct {
+		name         string
+		req          *csi.ListSnapshotsRequest
+		expEntries   int
+		expNextToken string
+		expErrCode   codes.Code
+	}{
+		{
+			name:       "success all snapshots",
+			req:        &csi.ListSnapshotsRequest{},
+			expEntries: 3,
+		},
+		{
+			name:       "success filter by snapshot id",
+			req:        &csi.ListSnapshotsRequest{SnapshotId: snapshotIDs[2]},
+			expEntries: 1,
+		},
+		{
+			name:       "success unknown snapshot id",
+			req:        &csi.ListSnapshotsRequest{SnapshotId: "snap-unknown"},
+			expEntries: 0,
+		},
+		{
+			name:       "success filter by source volume",
+			req:        &csi.ListSnapshotsRequest{SourceVolumeId: "vol-1"},
+			expEntries: 2,
+		},
+		{
+			name:         "success single entry",
+			req:          &csi.ListSnapshotsRequest{MaxEntries: 1},
+			expEntries:   1,
+			expNextToken: "1",
+		},
+		{
+			name:         "success limited entries",
+			req:          &csi.ListSnapshotsRequest{MaxEntries: 2},
+			expEntries:   2,
+			expNextToken: "2",
+		},
+		{
+			name:       "fail invalid starting token",
+			req:        &csi.ListSnapshotsRequest{StartingToken: "invalid-token"},
+			expErrCode: codes.Aborted,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		resp, err := awsDriver.ListSnapshots(context.TODO(), tc.req)
+		if err != nil {
+			srvErr, ok := status.FromError(err)
+			if !ok {
+				t.Fatalf("Could not get error status code from error: %v", srvErr)
+			}
+			if srvErr.Code() != tc.expErrCode {
+				t.Fatalf("Expected error code %d, got %d", tc.expErrCode, srvErr.Code())
+			}
+			continue
+		}
+		if tc.expErrCode != codes.OK {
+			t.Fatalf("Expected error %v, got no error", tc.expErrCode)
+		}
+		if len(resp.GetEntries()) != tc.expEntries {
+			t.Fatalf("Expected %d entries, got %d", tc.expEntries, len(resp.GetEntries()))
+		}
+		if resp.GetNextToken() != tc.expNextToken {
+			t.Fatalf("Expected next token %q, got %q", tc.expNextToken, resp.GetNextToken())
+		}
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
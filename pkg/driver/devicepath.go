@@ -0,0 +1,122 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/logging"
+)
+
+const (
+	// nvmeEBSModel is the model reported by the NVMe controllers of EBS volumes.
+	nvmeEBSModel = "Amazon Elastic Block Store"
+
+	// nvmeEBSLinkPrefix is the prefix of the udev links of EBS volumes exposed as NVMe devices.
+	nvmeEBSLinkPrefix = "nvme-Amazon_Elastic_Block_Store_"
+)
+
+// deviceResolver finds the block device of an EBS volume on the node. On Nitro instances
+// volumes are exposed as NVMe devices, whose names have nothing to do with the device name
+// requested when attaching the volume.
+type deviceResolver struct {
+	// devDir and sysDir are the mount points of devtmpfs and sysfs.
+	devDir string
+	sysDir string
+
+	// deviceNumber returns the device number of a block device node.
+	deviceNumber func(path string) (uint64, error)
+}
+
+func newDeviceResolver() *deviceResolver {
+	return &deviceResolver{
+		devDir:       "/dev",
+		sysDir:       "/sys",
+		deviceNumber: getDeviceNumber,
+	}
+}
+
+// findDevicePath returns the block device of the volume. The NVMe controllers of EBS volumes
+// use the volume ID without the dash as serial number, which is looked up first through the
+// udev links and then through sysfs. If the volume is not an NVMe device, devicePath, the name
+// requested when attaching the volume, is returned.
+func (r *deviceResolver) findDevicePath(ctx context.Context, devicePath, volumeID string) string {
+	serial := strings.Replace(volumeID, "-", "", -1)
+
+	link := filepath.Join(r.devDir, "disk", "by-id", nvmeEBSLinkPrefix+serial)
+	if path, err := filepath.EvalSymlinks(link); err == nil {
+		logging.FromContext(ctx).V(5).Infof("Found device %s of volume %s from link %s", path, volumeID, link)
+		return path
+	} else if !os.IsNotExist(err) {
+		logging.FromContext(ctx).Warningf("Could not resolve link %s of volume %s: %v", link, volumeID, err)
+	}
+
+	if name := r.findNVMeNamespace(ctx, serial); name != "" {
+		path := filepath.Join(r.devDir, name)
+		logging.FromContext(ctx).V(5).Infof("Found device %s of volume %s from sysfs", path, volumeID)
+		return path
+	}
+
+	return devicePath
+}
+
+// findNVMeNamespace returns the name of the block device of the EBS NVMe controller
+// with the given serial number, or an empty string if there is none.
+func (r *deviceResolver) findNVMeNamespace(ctx context.Context, serial string) string {
+	controllers, err := ioutil.ReadDir(filepath.Join(r.sysDir, "class", "nvme"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logging.FromContext(ctx).Warningf("Could not list NVMe controllers: %v", err)
+		}
+		return ""
+	}
+
+	for _, controller := range controllers {
+		dir := filepath.Join(r.sysDir, "class", "nvme", controller.Name())
+		if readSysfsAttribute(filepath.Join(dir, "model")) != nvmeEBSModel ||
+			readSysfsAttribute(filepath.Join(dir, "serial")) != serial {
+			continue
+		}
+
+		// Namespaces show up as nvmeXnY entries of their controller nvmeX
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			logging.FromContext(ctx).Warningf("Could not list namespaces of NVMe controller %s: %v", controller.Name(), err)
+			return ""
+		}
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), controller.Name()+"n") {
+				return entry.Name()
+			}
+		}
+	}
+	return ""
+}
+
+// readSysfsAttribute returns the value of a sysfs attribute without its padding,
+// or an empty string if it can't be read.
+func readSysfsAttribute(path string) string {
+	value, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(value))
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindDevicePath(t *testing.T) {
+	devicePath := "/dev/xvdbc"
+	volumeID := "vol-0123456789abcdef0"
+
+	// nvmeController describes a fake NVMe controller in sysfs along with its namespace
+	type nvmeController struct {
+		name      string
+		model     string
+		serial    string
+		namespace string
+	}
+
+	testCases := []struct {
+		name          string
+		links         map[string]string
+		controllers   []nvmeController
+		expDeviceName string
+	}{
+		{
+			name: "success: udev link",
+			links: map[string]string{
+				"nvme-Amazon_Elastic_Block_Store_vol0123456789abcdef0": "../../nvme1n1",
+			},
+			expDeviceName: "nvme1n1",
+		},
+		{
+			name: "success: sysfs serial",
+			controllers: []nvmeController{
+				{name: "nvme0", model: "Amazon EC2 NVMe Instance Storage", serial: "AWS1234", namespace: "nvme0n1"},
+				{name: "nvme1", model: "Amazon Elastic Block Store              ", serial: "vol0123456789abcdef0 ", namespace: "nvme1n1"},
+			},
+			expDeviceName: "nvme1n1",
+		},
+		{
+			name: "success: fall back for a different volume",
+			controllers: []nvmeController{
+				{name: "nvme1", model: "Amazon Elastic Block Store", serial: "vol0000000000000000", namespace: "nvme1n1"},
+			},
+		},
+		{
+			name: "success: fall back without NVMe devices",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "devicepath")
+			if err != nil {
+				t.Fatalf("Could not create temp dir: %v", err)
+			}
+			defer os.RemoveAll(root)
+
+			r := &deviceResolver{
+				devDir: filepath.Join(root, "dev"),
+				sysDir: filepath.Join(root, "sys"),
+			}
+			byIDDir := filepath.Join(r.devDir, "disk", "by-id")
+			mkdirAll(t, byIDDir)
+			for link, target := range tc.links {
+				writeFile(t, filepath.Join(byIDDir, target), "")
+				if err := os.Symlink(target, filepath.Join(byIDDir, link)); err != nil {
+					t.Fatalf("Could not create link: %v", err)
+				}
+			}
+			for _, c := range tc.controllers {
+				dir := filepath.Join(r.sysDir, "class", "nvme", c.name)
+				mkdirAll(t, filepath.Join(dir, c.namespace))
+				writeFile(t, filepath.Join(dir, "model"), c.model+"\n")
+				writeFile(t, filepath.Join(dir, "serial"), c.serial+"\n")
+			}
+
+			expPath := devicePath
+			if tc.expDeviceName != "" {
+				devDir, err := filepath.EvalSymlinks(r.devDir)
+				if err != nil {
+					t.Fatalf("Could not resolve dev dir: %v", err)
+				}
+				expPath = filepath.Join(devDir, tc.expDeviceName)
+				r.devDir = devDir
+			}
+
+			if path := r.findDevicePath(context.Background(), devicePath, volumeID); path != expPath {
+				t.Fatalf("findDevicePath() failed: expected %q, got %q", expPath, path)
+			}
+		})
+	}
+}
+
+func mkdirAll(t *testing.T, path string) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatalf("Could not create dir %q: %v", path, err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Could not write file %q: %v", path, err)
+	}
+}
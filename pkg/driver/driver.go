@@ -17,10 +17,9 @@ limitations under the License.
 package driver
 
 import (
-	"context"
 	"net"
 
-	csi "github.com/container-storage-interface/spec/lib/go/csi/v0"
+	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/golang/glog"
 	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/cloud"
 	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/util"
@@ -32,6 +31,9 @@ const (
 	driverName    = "com.amazon.aws.csi.ebs"
 	vendorVersion = "0.0.1" // FIXME
 	topologyKey   = driverName + "/zone"
+	// wellKnownTopologyKey is the zone label of Kubernetes nodes. Topologies have both keys,
+	// while requirements can use either, as volumes provisioned before only had topologyKey.
+	wellKnownTopologyKey = "topology.kubernetes.io/zone"
 )
 
 type Driver struct {
@@ -41,24 +43,46 @@ type Driver struct {
 	cloud cloud.Cloud
 	srv   *grpc.Server
 
-	mounter *mount.SafeFormatAndMount
+	mounter        *mount.SafeFormatAndMount
+	deviceResolver *deviceResolver
+
+	// inFlight guards volumes and snapshots against concurrent operations.
+	inFlight *inFlight
+
+	// volumeAttachLimit overrides the number of volumes that can be attached
+	// to the node when it's not negative.
+	volumeAttachLimit int64
 
 	volumeCaps     []csi.VolumeCapability_AccessMode
 	controllerCaps []csi.ControllerServiceCapability_RPC_Type
 	nodeCaps       []csi.NodeServiceCapability_RPC_Type
 }
 
-func NewDriver(cloud cloud.Cloud, mounter *mount.SafeFormatAndMount, endpoint string) *Driver {
+// DriverOption configures optional behavior of the driver.
+type DriverOption func(*Driver)
+
+// WithVolumeAttachLimit overrides the number of volumes that can be attached to the node,
+// which is otherwise computed from the instance type. A negative limit is ignored.
+func WithVolumeAttachLimit(limit int64) DriverOption {
+	return func(d *Driver) {
+		d.volumeAttachLimit = limit
+	}
+}
+
+func NewDriver(cloud cloud.Cloud, mounter *mount.SafeFormatAndMount, endpoint string, options ...DriverOption) *Driver {
 	glog.Infof("Driver: %v", driverName)
 	if mounter == nil {
 		mounter = newSafeMounter()
 	}
 	m := cloud.GetMetadata()
-	return &Driver{
-		endpoint: endpoint,
-		nodeID:   m.GetInstanceID(),
-		cloud:    cloud,
-		mounter:  mounter,
+	d := &Driver{
+		endpoint:          endpoint,
+		nodeID:            m.GetInstanceID(),
+		cloud:             cloud,
+		mounter:           mounter,
+		deviceResolver:    newDeviceResolver(),
+		inFlight:          newInFlight(),
+		volumeAttachLimit: -1,
 		volumeCaps: []csi.VolumeCapability_AccessMode{
 			{
 				Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
@@ -70,11 +94,21 @@ func NewDriver(cloud cloud.Cloud, mounter *mount.SafeFormatAndMount, endpoint st
 		controllerCaps: []csi.ControllerServiceCapability_RPC_Type{
 			csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
 			csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME,
+			csi.ControllerServiceCapability_RPC_LIST_VOLUMES,
+			csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT,
+			csi.ControllerServiceCapability_RPC_LIST_SNAPSHOTS,
+			csi.ControllerServiceCapability_RPC_EXPAND_VOLUME,
 		},
 		nodeCaps: []csi.NodeServiceCapability_RPC_Type{
 			csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME,
+			csi.NodeServiceCapability_RPC_EXPAND_VOLUME,
+			csi.NodeServiceCapability_RPC_GET_VOLUME_STATS,
 		},
 	}
+	for _, option := range options {
+		option(d)
+	}
+	return d
 }
 
 func (d *Driver) Run() error {
@@ -88,15 +122,8 @@ func (d *Driver) Run() error {
 		return err
 	}
 
-	logErr := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-		resp, err := handler(ctx, req)
-		if err != nil {
-			glog.Errorf("GRPC error: %v", err)
-		}
-		return resp, err
-	}
 	opts := []grpc.ServerOption{
-		grpc.UnaryInterceptor(logErr),
+		grpc.UnaryInterceptor(chainUnaryInterceptors(withRequestContext, observeMetrics, logErrors)),
 	}
 	d.srv = grpc.NewServer(opts...)
 
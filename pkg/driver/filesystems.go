@@ -0,0 +1,109 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/logging"
+)
+
+// defaultFsType is the filesystem used when none is specified.
+const defaultFsType = "ext4"
+
+// fileSystem describes how a supported filesystem is formatted and mounted.
+type fileSystem struct {
+	// mkfsArgs are passed to mkfs before the device.
+	mkfsArgs []string
+
+	// mountOptions are used whenever the filesystem is mounted.
+	mountOptions []string
+
+	// readOnlyMountOptions are added when the filesystem is mounted read-only, so that
+	// its journal is not replayed onto the device.
+	readOnlyMountOptions []string
+}
+
+// fileSystems holds the filesystems volumes can be formatted with. EBS volumes are
+// blank when they are not restored from a snapshot, so discarding blocks at format
+// time is skipped as it only slows down formatting big volumes.
+var fileSystems = map[string]fileSystem{
+	"ext3": {
+		mkfsArgs:             []string{"-F", "-m0", "-E", "nodiscard"},
+		readOnlyMountOptions: []string{"noload"},
+	},
+	"ext4": {
+		mkfsArgs:             []string{"-F", "-m0", "-E", "nodiscard"},
+		readOnlyMountOptions: []string{"noload"},
+	},
+	"xfs": {
+		mkfsArgs: []string{"-K"},
+		// Volumes restored from a snapshot have the same UUID as the source volume,
+		// which would prevent mounting both of them on the same node.
+		mountOptions:         []string{"nouuid"},
+		readOnlyMountOptions: []string{"norecovery"},
+	},
+	"btrfs": {
+		mkfsArgs:             []string{"-K"},
+		readOnlyMountOptions: []string{"nologreplay"},
+	},
+}
+
+// isSupportedFsType returns whether volumes can be formatted with the filesystem.
+func isSupportedFsType(fsType string) bool {
+	_, ok := fileSystems[fsType]
+	return ok
+}
+
+// getFsType returns the filesystem of a volume. The filesystem requested in the volume
+// capability takes precedence over the one set in the StorageClass at creation time.
+func getFsType(volCap *csi.VolumeCapability, attributes map[string]string) (string, error) {
+	fsType := volCap.GetMount().GetFsType()
+	if len(fsType) == 0 {
+		fsType = attributes[FsTypeKey]
+	}
+	if len(fsType) == 0 {
+		fsType = defaultFsType
+	}
+	if !isSupportedFsType(fsType) {
+		return "", fmt.Errorf("filesystem type %q is not supported", fsType)
+	}
+	return fsType, nil
+}
+
+// formatIfNeeded formats the device with the filesystem unless it's already formatted.
+// Formatting is done here rather than by FormatAndMount to use the mkfs arguments of
+// the filesystem.
+func (d *Driver) formatIfNeeded(ctx context.Context, source, fsType string) error {
+	existingFormat, err := d.getDiskFormat(source)
+	if err != nil {
+		return fmt.Errorf("could not determine the format of %q: %v", source, err)
+	}
+	if existingFormat != "" {
+		logging.FromContext(ctx).V(5).Infof("Device %s is already formatted with %s", source, existingFormat)
+		return nil
+	}
+
+	args := append(append([]string{}, fileSystems[fsType].mkfsArgs...), source)
+	logging.FromContext(ctx).V(4).Infof("Formatting device %s with %s and arguments %v", source, fsType, args)
+	if output, err := d.mounter.Exec.Run("mkfs."+fsType, args...); err != nil {
+		return fmt.Errorf("mkfs.%s failed: %v, output: %s", fsType, err, string(output))
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/cloud"
+	"k8s.io/kubernetes/pkg/util/mount"
+)
+
+func TestGetFsType(t *testing.T) {
+	mountVolCap := func(fsType string) *csi.VolumeCapability {
+		return &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{
+				Mount: &csi.VolumeCapability_MountVolume{FsType: fsType},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name       string
+		volCap     *csi.VolumeCapability
+		attributes map[string]string
+		expFsType  string
+		expErr     bool
+	}{
+		{
+			name:      "success: default",
+			volCap:    mountVolCap(""),
+			expFsType: defaultFsType,
+		},
+		{
+			name:       "success: attribute",
+			volCap:     mountVolCap(""),
+			attributes: map[string]string{FsTypeKey: "ext3"},
+			expFsType:  "ext3",
+		},
+		{
+			name:       "success: capability overrides attribute",
+			volCap:     mountVolCap("btrfs"),
+			attributes: map[string]string{FsTypeKey: "ext3"},
+			expFsType:  "btrfs",
+		},
+		{
+			name:   "fail: unsupported capability fsType",
+			volCap: mountVolCap("vfat"),
+			expErr: true,
+		},
+		{
+			name:       "fail: unsupported attribute fsType",
+			volCap:     mountVolCap(""),
+			attributes: map[string]string{FsTypeKey: "ntfs"},
+			expErr:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsType, err := getFsType(tc.volCap, tc.attributes)
+			if err != nil {
+				if !tc.expErr {
+					t.Fatalf("getFsType() failed: expected no error, got: %v", err)
+				}
+				return
+			}
+			if tc.expErr {
+				t.Fatal("getFsType() failed: expected error, got nothing")
+			}
+			if fsType != tc.expFsType {
+				t.Fatalf("getFsType() failed: expected %q, got %q", tc.expFsType, fsType)
+			}
+		})
+	}
+}
+
+func TestFormatIfNeeded(t *testing.T) {
+	devicePath := "/dev/xvdbc"
+
+	testCases := []struct {
+		name        string
+		fsType      string
+		blkidOutput string
+		mkfsErr     error
+		expCmd      []string
+		expErr      bool
+	}{
+		{
+			name:   "success ext4",
+			fsType: "ext4",
+			expCmd: []string{"mkfs.ext4", "-F", "-m0", "-E", "nodiscard", devicePath},
+		},
+		{
+			name:   "success xfs",
+			fsType: "xfs",
+			expCmd: []string{"mkfs.xfs", "-K", devicePath},
+		},
+		{
+			name:   "success btrfs",
+			fsType: "btrfs",
+			expCmd: []string{"mkfs.btrfs", "-K", devicePath},
+		},
+		{
+			name:        "success already formatted",
+			fsType:      "xfs",
+			blkidOutput: "TYPE=ext4\n",
+		},
+		{
+			name:    "fail mkfs failed",
+			fsType:  "ext3",
+			mkfsErr: fmt.Errorf("exit status 1"),
+			expCmd:  []string{"mkfs.ext3", "-F", "-m0", "-E", "nodiscard", devicePath},
+			expErr:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		var cmds [][]string
+		mounter := NewFakeMounter()
+		mounter.Exec = mount.NewFakeExec(func(cmd string, args ...string) ([]byte, error) {
+			if cmd == "blkid" {
+				return []byte(tc.blkidOutput), nil
+			}
+			cmds = append(cmds, append([]string{cmd}, args...))
+			return nil, tc.mkfsErr
+		})
+		awsDriver := NewDriver(cloud.NewFakeCloudProvider(), mounter, "")
+
+		err := awsDriver.formatIfNeeded(context.Background(), devicePath, tc.fsType)
+		if err != nil {
+			if !tc.expErr {
+				t.Fatalf("formatIfNeeded() failed: expected no error, got: %v", err)
+			}
+		} else if tc.expErr {
+			t.Fatal("formatIfNeeded() failed: expected error, got nothing")
+		}
+
+		if tc.expCmd == nil {
+			if len(cmds) != 0 {
+				t.Fatalf("Expected no command to be run, got %v", cmds)
+			}
+			continue
+		}
+		if len(cmds) != 1 || !reflect.DeepEqual(cmds[0], tc.expCmd) {
+			t.Fatalf("Expected command %v to be run, got %v", tc.expCmd, cmds)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// inFlight tracks the volumes and snapshots, by ID or name, that have an operation in
+// progress. Concurrent operations on the same key are rejected rather than queued, as
+// the CO retries them once the first one has completed.
+type inFlight struct {
+	mux  sync.Mutex
+	keys map[string]struct{}
+}
+
+func newInFlight() *inFlight {
+	return &inFlight{
+		keys: make(map[string]struct{}),
+	}
+}
+
+// insert marks the key as in flight. It returns false when an operation is already in
+// progress for the key, in which case it must not be deleted by the caller.
+func (i *inFlight) insert(key string) bool {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+
+	if _, ok := i.keys[key]; ok {
+		return false
+	}
+	i.keys[key] = struct{}{}
+	return true
+}
+
+// delete marks the operation on the key as completed.
+func (i *inFlight) delete(key string) {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+
+	delete(i.keys, key)
+}
+
+// The keys of the operations are prefixed by the kind of their subject, so that the names and
+// the IDs of volumes and snapshots can't collide.
+
+func volumeNameKey(name string) string {
+	return "volume-name:" + name
+}
+
+func volumeIDKey(volumeID string) string {
+	return "volume:" + volumeID
+}
+
+func snapshotNameKey(name string) string {
+	return "snapshot-name:" + name
+}
+
+func snapshotIDKey(snapshotID string) string {
+	return "snapshot:" + snapshotID
+}
+
+// publishKey is the key of the publication of a volume at a target path. A volume can be
+// published at several target paths concurrently.
+func publishKey(volumeID, targetPath string) string {
+	return "publish:" + volumeID + ":" + targetPath
+}
+
+// errOperationInFlight is returned by RPCs when another operation is in progress for the
+// volume or snapshot with the given name or ID.
+func errOperationInFlight(nameOrID string) error {
+	return status.Errorf(codes.Aborted, "An operation on %q is already in progress", nameOrID)
+}
@@ -0,0 +1,275 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/cloud"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// blockingCloud blocks GetDiskByName until it is released, to keep a CreateVolume call in flight.
+type blockingCloud struct {
+	cloud.Cloud
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingCloud) GetDiskByName(ctx context.Context, name string, capacityBytes int64) (*cloud.Disk, error) {
+	c.started <- struct{}{}
+	<-c.release
+	return c.Cloud.GetDiskByName(ctx, name, capacityBytes)
+}
+
+func TestInFlight(t *testing.T) {
+	inFlight := newInFlight()
+	if !inFlight.insert("vol-test") {
+		t.Fatal("insert() failed: expected first insert to succeed")
+	}
+	if inFlight.insert("vol-test") {
+		t.Fatal("insert() failed: expected second insert of the same key to fail")
+	}
+	if !inFlight.insert("vol-other") {
+		t.Fatal("insert() failed: expected insert of another key to succeed")
+	}
+	inFlight.delete("vol-test")
+	if !inFlight.insert("vol-test") {
+		t.Fatal("insert() failed: expected insert to succeed after delete")
+	}
+}
+
+func TestInFlightParallelInsert(t *testing.T) {
+	inFlight := newInFlight()
+	var wg sync.WaitGroup
+	results := make(chan bool, 10)
+	for i := 0; i < cap(results); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- inFlight.insert("vol-test")
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	inserted := 0
+	for ok := range results {
+		if ok {
+			inserted++
+		}
+	}
+	if inserted != 1 {
+		t.Fatalf("Expected exactly 1 insert to succeed, got %d", inserted)
+	}
+}
+
+func TestOperationInFlight(t *testing.T) {
+	volCap := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{},
+		},
+		AccessMode: &csi.VolumeCapability_AccessMode{
+			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+		},
+	}
+
+	testCases := []struct {
+		name string
+		key  string
+		call func(d *Driver) error
+	}{
+		{
+			name: "CreateVolume",
+			key:  volumeNameKey("vol-name"),
+			call: func(d *Driver) error {
+				_, err := d.CreateVolume(context.TODO(), &csi.CreateVolumeRequest{Name: "vol-name", VolumeCapabilities: []*csi.VolumeCapability{volCap}})
+				return err
+			},
+		},
+		{
+			name: "DeleteVolume",
+			key:  volumeIDKey("vol-test"),
+			call: func(d *Driver) error {
+				_, err := d.DeleteVolume(context.TODO(), &csi.DeleteVolumeRequest{VolumeId: "vol-test"})
+				return err
+			},
+		},
+		{
+			name: "ControllerPublishVolume",
+			key:  volumeIDKey("vol-test"),
+			call: func(d *Driver) error {
+				_, err := d.ControllerPublishVolume(context.TODO(), &csi.ControllerPublishVolumeRequest{VolumeId: "vol-test", NodeId: "instance-1234", VolumeCapability: volCap})
+				return err
+			},
+		},
+		{
+			name: "ControllerUnpublishVolume",
+			key:  volumeIDKey("vol-test"),
+			call: func(d *Driver) error {
+				_, err := d.ControllerUnpublishVolume(context.TODO(), &csi.ControllerUnpublishVolumeRequest{VolumeId: "vol-test", NodeId: "instance-1234"})
+				return err
+			},
+		},
+		{
+			name: "CreateSnapshot",
+			key:  snapshotNameKey("snap-name"),
+			call: func(d *Driver) error {
+				_, err := d.CreateSnapshot(context.TODO(), &csi.CreateSnapshotRequest{Name: "snap-name", SourceVolumeId: "vol-test"})
+				return err
+			},
+		},
+		{
+			name: "DeleteSnapshot",
+			key:  snapshotIDKey("snap-test"),
+			call: func(d *Driver) error {
+				_, err := d.DeleteSnapshot(context.TODO(), &csi.DeleteSnapshotRequest{SnapshotId: "snap-test"})
+				return err
+			},
+		},
+		{
+			name: "NodeStageVolume",
+			key:  volumeIDKey("vol-test"),
+			call: func(d *Driver) error {
+				_, err := d.NodeStageVolume(context.TODO(), &csi.NodeStageVolumeRequest{VolumeId: "vol-test", StagingTargetPath: "/test/staging/path", VolumeCapability: volCap})
+				return err
+			},
+		},
+		{
+			name: "NodeUnstageVolume",
+			key:  volumeIDKey("vol-test"),
+			call: func(d *Driver) error {
+				_, err := d.NodeUnstageVolume(context.TODO(), &csi.NodeUnstageVolumeRequest{VolumeId: "vol-test", StagingTargetPath: "/test/staging/path"})
+				return err
+			},
+		},
+		{
+			name: "NodePublishVolume",
+			key:  publishKey("vol-test", "/test/target/path"),
+			call: func(d *Driver) error {
+				_, err := d.NodePublishVolume(context.TODO(), &csi.NodePublishVolumeRequest{VolumeId: "vol-test", StagingTargetPath: "/test/staging/path", TargetPath: "/test/target/path", VolumeCapability: volCap})
+				return err
+			},
+		},
+		{
+			name: "NodeUnpublishVolume",
+			key:  publishKey("vol-test", "/test/target/path"),
+			call: func(d *Driver) error {
+				_, err := d.NodeUnpublishVolume(context.TODO(), &csi.NodeUnpublishVolumeRequest{VolumeId: "vol-test", TargetPath: "/test/target/path"})
+				return err
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			awsDriver := NewDriver(cloud.NewFakeCloudProvider(), NewFakeMounter(), "")
+
+			awsDriver.inFlight.insert(tc.key)
+			if code := status.Code(tc.call(awsDriver)); code != codes.Aborted {
+				t.Fatalf("Expected error code %d while the operation is in flight, got %d", codes.Aborted, code)
+			}
+
+			awsDriver.inFlight.delete(tc.key)
+			if code := status.Code(tc.call(awsDriver)); code == codes.Aborted {
+				t.Fatal("Expected the operation to run once no other operation is in flight")
+			}
+			if !awsDriver.inFlight.insert(tc.key) {
+				t.Fatal("Expected the key to be released once the operation completed")
+			}
+		})
+	}
+}
+
+func TestConcurrentCreateVolume(t *testing.T) {
+	fakeCloud := &blockingCloud{
+		Cloud:   cloud.NewFakeCloudProvider(),
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	awsDriver := NewDriver(fakeCloud, NewFakeMounter(), "")
+	req := &csi.CreateVolumeRequest{
+		Name: "vol-name",
+		VolumeCapabilities: []*csi.VolumeCapability{
+			{
+				AccessType: &csi.VolumeCapability_Mount{
+					Mount: &csi.VolumeCapability_MountVolume{},
+				},
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+				},
+			},
+		},
+	}
+
+	errs := make(chan error)
+	go func() {
+		_, err := awsDriver.CreateVolume(context.TODO(), req)
+		errs <- err
+	}()
+	<-fakeCloud.started
+
+	// The first call is blocked in the cloud provider, so the second one must not reach it
+	_, err := awsDriver.CreateVolume(context.TODO(), req)
+	if code := status.Code(err); code != codes.Aborted {
+		t.Fatalf("Expected error code %d for the concurrent call, got %d", codes.Aborted, code)
+	}
+
+	close(fakeCloud.release)
+	if err := <-errs; err != nil {
+		t.Fatalf("Expected the first call to succeed, got: %v", err)
+	}
+
+	// The retry finds the volume created by the first call
+	go func() { <-fakeCloud.started }()
+	if _, err := awsDriver.CreateVolume(context.TODO(), req); err != nil {
+		t.Fatalf("Expected the retried call to succeed, got: %v", err)
+	}
+}
+
+func TestOperationInFlightKeys(t *testing.T) {
+	awsDriver := NewDriver(cloud.NewFakeCloudProvider(), NewFakeMounter(), "")
+
+	// A volume named like the ID of another volume or of a snapshot doesn't conflict with them
+	awsDriver.inFlight.insert(volumeIDKey("vol-test"))
+	awsDriver.inFlight.insert(snapshotIDKey("vol-test"))
+	defer awsDriver.inFlight.delete(volumeIDKey("vol-test"))
+	defer awsDriver.inFlight.delete(snapshotIDKey("vol-test"))
+	if !awsDriver.inFlight.insert(volumeNameKey("vol-test")) {
+		t.Fatal("Expected volume name not to conflict with volume and snapshot IDs")
+	}
+
+	// A volume can be published at another target path while it's published at one
+	volCap := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{},
+		},
+		AccessMode: &csi.VolumeCapability_AccessMode{
+			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+		},
+	}
+	awsDriver.inFlight.insert(publishKey("vol-test", "/test/target/path"))
+	defer awsDriver.inFlight.delete(publishKey("vol-test", "/test/target/path"))
+	_, err := awsDriver.NodePublishVolume(context.TODO(), &csi.NodePublishVolumeRequest{VolumeId: "vol-test", StagingTargetPath: "/test/staging/path", TargetPath: "/test/other/path", VolumeCapability: volCap})
+	if code := status.Code(err); code == codes.Aborted {
+		t.Fatal("Expected volume to be published at another target path concurrently")
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// strippedSecret replaces the values of the secrets of the requests when they are logged.
+const strippedSecret = "***stripped***"
+
+var (
+	// operationDuration observes the latency of the CSI RPCs, whether they succeeded or not.
+	// The buckets range up to the minutes taken by the RPCs waiting for volumes.
+	operationDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "aws_ebs_csi_operation_duration_seconds",
+			Help:    "Latency of CSI RPCs in seconds.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 2.5, 12),
+		},
+		[]string{"method"},
+	)
+
+	// operationErrors counts the failed CSI RPCs by gRPC code.
+	operationErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aws_ebs_csi_operation_errors_total",
+			Help: "Number of failed CSI RPCs.",
+		},
+		[]string{"method", "code"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(operationDuration, operationErrors)
+}
+
+// chainUnaryInterceptors returns an interceptor calling the interceptors in order, the first
+// one being the outermost.
+func chainUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], handler
+			handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, next)
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// withRequestContext adds the fields of the request to its context, so that the log lines of
+// the layers handling the request can be correlated, and logs the request with its secrets stripped.
+func withRequestContext(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	method := path.Base(info.FullMethod)
+	ctx = logging.WithFields(ctx,
+		logging.RequestIDKey, logging.NewRequestID(),
+		logging.MethodKey, method,
+		logging.VolumeIDKey, requestVolumeID(req),
+		logging.NodeIDKey, requestNodeID(req),
+		logging.SnapshotIDKey, requestSnapshotID(req),
+	)
+	logging.FromContext(ctx).V(4).Infof("%s: called with args %s", method, stripSecrets(req))
+	return handler(ctx, req)
+}
+
+// requestVolumeID returns the ID of the volume the request applies to, if any.
+func requestVolumeID(req interface{}) string {
+	switch r := req.(type) {
+	case interface{ GetVolumeId() string }:
+		return r.GetVolumeId()
+	case interface{ GetSourceVolumeId() string }:
+		return r.GetSourceVolumeId()
+	}
+	return ""
+}
+
+// requestNodeID returns the ID of the node the request applies to, if any.
+func requestNodeID(req interface{}) string {
+	if r, ok := req.(interface{ GetNodeId() string }); ok {
+		return r.GetNodeId()
+	}
+	return ""
+}
+
+// requestSnapshotID returns the ID of the snapshot the request applies to, if any.
+func requestSnapshotID(req interface{}) string {
+	if r, ok := req.(interface{ GetSnapshotId() string }); ok {
+		return r.GetSnapshotId()
+	}
+	return ""
+}
+
+// stripSecrets returns the request formatted for logging, with the values of its secrets
+// replaced. The secrets are the map fields whose name ends with Secrets.
+func stripSecrets(req interface{}) string {
+	msg, ok := req.(proto.Message)
+	if !ok || reflect.ValueOf(msg).IsNil() {
+		return fmt.Sprintf("%+v", req)
+	}
+
+	msg = proto.Clone(msg)
+	v := reflect.ValueOf(msg).Elem()
+	if v.Kind() == reflect.Struct {
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Field(i)
+			if !strings.HasSuffix(v.Type().Field(i).Name, "Secrets") || field.Kind() != reflect.Map || field.Type().Elem().Kind() != reflect.String {
+				continue
+			}
+			for _, key := range field.MapKeys() {
+				field.SetMapIndex(key, reflect.ValueOf(strippedSecret))
+			}
+		}
+	}
+	return proto.CompactTextString(msg)
+}
+
+// observeMetrics records the latency of the RPCs and counts their errors.
+func observeMetrics(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	method := path.Base(info.FullMethod)
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	operationDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		operationErrors.WithLabelValues(method, status.Code(err).String()).Inc()
+	}
+	return resp, err
+}
+
+// logErrors logs the errors of the RPCs.
+func logErrors(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("GRPC error: %v", err)
+	}
+	return resp, err
+}
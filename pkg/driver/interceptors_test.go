@@ -0,0 +1,192 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/logging"
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestChainUnaryInterceptors(t *testing.T) {
+	var calls []string
+	newInterceptor := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			calls = append(calls, name+" before")
+			resp, err := handler(ctx, req)
+			calls = append(calls, name+" after")
+			return resp, err
+		}
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls = append(calls, "handler")
+		return "resp", nil
+	}
+
+	interceptor := chainUnaryInterceptors(newInterceptor("first"), newInterceptor("second"))
+	resp, err := interceptor(context.TODO(), "req", &grpc.UnaryServerInfo{}, handler)
+	if err != nil || resp != "resp" {
+		t.Fatalf("Expected the response of the handler, got %v, %v", resp, err)
+	}
+
+	expCalls := []string{"first before", "second before", "handler", "second after", "first after"}
+	if !reflect.DeepEqual(calls, expCalls) {
+		t.Fatalf("Expected calls %v, got %v", expCalls, calls)
+	}
+}
+
+func TestObserveMetrics(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/csi.v0.Controller/ControllerPublishVolume"}
+	method := "ControllerPublishVolume"
+
+	testCases := []struct {
+		name    string
+		err     error
+		expCode string
+	}{
+		{
+			name: "success",
+		},
+		{
+			name:    "fail: error counted by code",
+			err:     status.Error(codes.Unavailable, "Throttled"),
+			expCode: codes.Unavailable.String(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			callsBefore := testutil.HistogramCount(t, operationDuration, method)
+			var errorsBefore float64
+			if tc.expCode != "" {
+				errorsBefore = testutil.CounterValue(t, operationErrors, method, tc.expCode)
+			}
+
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, tc.err
+			}
+			if _, err := observeMetrics(context.TODO(), nil, info, handler); err != tc.err {
+				t.Fatalf("Expected error %v, got: %v", tc.err, err)
+			}
+
+			if calls := testutil.HistogramCount(t, operationDuration, method) - callsBefore; calls != 1 {
+				t.Fatalf("Expected the latency of 1 call to be observed, got %d", calls)
+			}
+			if tc.expCode != "" {
+				if errs := testutil.CounterValue(t, operationErrors, method, tc.expCode) - errorsBefore; errs != 1 {
+					t.Fatalf("Expected 1 error with code %q to be counted, got %v", tc.expCode, errs)
+				}
+			}
+		})
+	}
+}
+
+func TestWithRequestContext(t *testing.T) {
+	testCases := []struct {
+		name          string
+		method        string
+		req           interface{}
+		expVolumeID   string
+		expNodeID     string
+		expSnapshotID string
+	}{
+		{
+			name:        "volume and node",
+			method:      "/csi.v0.Controller/ControllerPublishVolume",
+			req:         &csi.ControllerPublishVolumeRequest{VolumeId: "vol-test", NodeId: "i-1234"},
+			expVolumeID: "vol-test",
+			expNodeID:   "i-1234",
+		},
+		{
+			name:        "snapshot source volume",
+			method:      "/csi.v0.Controller/CreateSnapshot",
+			req:         &csi.CreateSnapshotRequest{Name: "snap-name", SourceVolumeId: "vol-test"},
+			expVolumeID: "vol-test",
+		},
+		{
+			name:          "snapshot",
+			method:        "/csi.v0.Controller/DeleteSnapshot",
+			req:           &csi.DeleteSnapshotRequest{SnapshotId: "snap-test"},
+			expSnapshotID: "snap-test",
+		},
+		{
+			name:   "no IDs",
+			method: "/csi.v0.Identity/Probe",
+			req:    &csi.ProbeRequest{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requestCtx context.Context
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				requestCtx = ctx
+				return nil, nil
+			}
+			if _, err := withRequestContext(context.TODO(), tc.req, &grpc.UnaryServerInfo{FullMethod: tc.method}, handler); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			if requestID := logging.Field(requestCtx, logging.RequestIDKey); requestID == "" {
+				t.Fatal("Expected the request to be assigned an ID")
+			}
+			if method := logging.Field(requestCtx, logging.MethodKey); !strings.HasSuffix(tc.method, "/"+method) {
+				t.Fatalf("Expected method of %q, got %q", tc.method, method)
+			}
+			fields := map[string]string{
+				logging.VolumeIDKey:   tc.expVolumeID,
+				logging.NodeIDKey:     tc.expNodeID,
+				logging.SnapshotIDKey: tc.expSnapshotID,
+			}
+			for key, expValue := range fields {
+				if value := logging.Field(requestCtx, key); value != expValue {
+					t.Fatalf("Expected field %s to be %q, got %q", key, expValue, value)
+				}
+			}
+		})
+	}
+}
+
+func TestStripSecrets(t *testing.T) {
+	req := &csi.NodeStageVolumeRequest{
+		VolumeId: "vol-test",
+		Secrets:  map[string]string{"password": "secret-value"},
+	}
+
+	stripped := stripSecrets(req)
+	if strings.Contains(stripped, "secret-value") {
+		t.Fatalf("Expected the secrets to be stripped, got %s", stripped)
+	}
+	if !strings.Contains(stripped, "password") || !strings.Contains(stripped, strippedSecret) || !strings.Contains(stripped, "vol-test") {
+		t.Fatalf("Expected the request with its secrets stripped, got %s", stripped)
+	}
+	if req.Secrets["password"] != "secret-value" {
+		t.Fatal("Expected the request itself to be left untouched")
+	}
+
+	if stripped := stripSecrets(&csi.CreateVolumeRequest{Name: "vol-name"}); !strings.Contains(stripped, "vol-name") {
+		t.Fatalf("Expected the request without secrets to be formatted, got %s", stripped)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+)
+
+// oppositeMountFlags maps mount flags to the flag that cancels them out.
+var oppositeMountFlags = map[string]string{
+	"ro":            "rw",
+	"rw":            "ro",
+	"sync":          "async",
+	"async":         "sync",
+	"exec":          "noexec",
+	"noexec":        "exec",
+	"suid":          "nosuid",
+	"nosuid":        "suid",
+	"dev":           "nodev",
+	"nodev":         "dev",
+	"atime":         "noatime",
+	"noatime":       "atime",
+	"diratime":      "nodiratime",
+	"nodiratime":    "diratime",
+	"relatime":      "norelatime",
+	"norelatime":    "relatime",
+	"strictatime":   "nostrictatime",
+	"nostrictatime": "strictatime",
+}
+
+// driverMountFlags are set by the driver itself and can't be requested in a volume capability.
+var driverMountFlags = map[string]bool{
+	"bind":    true,
+	"rbind":   true,
+	"remount": true,
+}
+
+// isReadOnlyAccessMode returns whether the volume capability only allows reading the volume.
+func isReadOnlyAccessMode(volCap *csi.VolumeCapability) bool {
+	switch volCap.GetAccessMode().GetMode() {
+	case csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY, csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY:
+		return true
+	}
+	return false
+}
+
+// collectMountOptions merges the mount flags of a volume capability into the options the
+// driver mounts the volume with. Duplicated options are dropped, while flags that cancel
+// each other out, like ro and rw, are rejected as the result would depend on their order.
+func collectMountOptions(options []string, mountFlags []string) ([]string, error) {
+	var merged []string
+	seen := map[string]bool{}
+	add := func(option string) {
+		if !seen[option] {
+			seen[option] = true
+			merged = append(merged, option)
+		}
+	}
+
+	for _, option := range options {
+		add(option)
+	}
+	for _, flag := range mountFlags {
+		// Several flags may be passed as a single comma separated string
+		for _, option := range strings.Split(flag, ",") {
+			option = strings.TrimSpace(option)
+			if len(option) == 0 {
+				continue
+			}
+			if driverMountFlags[option] {
+				return nil, fmt.Errorf("mount flag %q is managed by the driver", option)
+			}
+			add(option)
+		}
+	}
+
+	for _, option := range merged {
+		if opposite, ok := oppositeMountFlags[option]; ok && seen[opposite] {
+			return nil, fmt.Errorf("mount flags %q and %q conflict", option, opposite)
+		}
+	}
+	return merged, nil
+}
+
+// hasMountOption returns whether the option is part of the mount options.
+func hasMountOption(options []string, option string) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCollectMountOptions(t *testing.T) {
+	testCases := []struct {
+		name       string
+		options    []string
+		mountFlags []string
+		expOptions []string
+		expErr     bool
+	}{
+		{
+			name:       "success: no mount flags",
+			options:    []string{"bind"},
+			expOptions: []string{"bind"},
+		},
+		{
+			name:       "success: mount flags are appended",
+			options:    []string{"nouuid"},
+			mountFlags: []string{"noatime", "nodev"},
+			expOptions: []string{"nouuid", "noatime", "nodev"},
+		},
+		{
+			name:       "success: comma separated mount flags",
+			mountFlags: []string{"noatime, nodev", ""},
+			expOptions: []string{"noatime", "nodev"},
+		},
+		{
+			name:       "success: duplicates are dropped",
+			options:    []string{"bind", "ro"},
+			mountFlags: []string{"ro", "noexec", "noexec"},
+			expOptions: []string{"bind", "ro", "noexec"},
+		},
+		{
+			name:       "fail: conflicting mount flags",
+			mountFlags: []string{"exec", "noexec"},
+			expErr:     true,
+		},
+		{
+			name:       "fail: mount flag conflicts with driver option",
+			options:    []string{"bind", "ro"},
+			mountFlags: []string{"rw"},
+			expErr:     true,
+		},
+		{
+			name:       "fail: mount flag managed by the driver",
+			mountFlags: []string{"remount"},
+			expErr:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			options, err := collectMountOptions(tc.options, tc.mountFlags)
+			if err != nil {
+				if !tc.expErr {
+					t.Fatalf("collectMountOptions() failed: expected no error, got: %v", err)
+				}
+				return
+			}
+			if tc.expErr {
+				t.Fatal("collectMountOptions() failed: expected error, got nothing")
+			}
+			if !reflect.DeepEqual(options, tc.expOptions) {
+				t.Fatalf("collectMountOptions() failed: expected %v, got %v", tc.expOptions, options)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"k8s.io/kubernetes/pkg/util/mount"
+)
+
+// findMountPoint returns the entry of the mount table for the target, or nil when nothing
+// is mounted at it. The mount table is used rather than IsLikelyNotMountPoint as the latter
+// can't detect bind mounts from the same filesystem.
+func (d *Driver) findMountPoint(target string) (*mount.MountPoint, error) {
+	path, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		path = target
+	}
+
+	mountPoints, err := d.mounter.Interface.List()
+	if err != nil {
+		return nil, err
+	}
+
+	// The last entry wins as it hides the ones mounted before it at the same path
+	var found *mount.MountPoint
+	for i := range mountPoints {
+		if mountPoints[i].Path == path {
+			found = &mountPoints[i]
+		}
+	}
+	return found, nil
+}
+
+// verifyMountPoint checks that an existing mount point matches what a request would have
+// mounted, so that retries can be told apart from requests conflicting with another volume.
+// The filesystem type is not checked when it's empty.
+func verifyMountPoint(mp *mount.MountPoint, source, fsType string, readOnly bool) error {
+	if !isSameDevice(mp.Device, source) {
+		return fmt.Errorf("%q is mounted from %q instead of %q", mp.Path, mp.Device, source)
+	}
+	if len(fsType) != 0 && mp.Type != fsType {
+		return fmt.Errorf("%q is mounted with filesystem %q instead of %q", mp.Path, mp.Type, fsType)
+	}
+	if hasMountOption(mp.Opts, "ro") != readOnly {
+		return fmt.Errorf("%q is mounted with read-only set to %v", mp.Path, !readOnly)
+	}
+	return nil
+}
+
+// verifyBlockMountPoint checks that the device node bind mounted at an existing mount point is
+// the source device. Such mount points are listed with the filesystem of /dev as their device,
+// such as devtmpfs, so the device numbers of both nodes are compared instead.
+func (d *Driver) verifyBlockMountPoint(mp *mount.MountPoint, source string, readOnly bool) error {
+	mounted, err := d.deviceResolver.deviceNumber(mp.Path)
+	if err != nil {
+		return fmt.Errorf("could not get the device mounted at %q: %v", mp.Path, err)
+	}
+	expected, err := d.deviceResolver.deviceNumber(source)
+	if err != nil {
+		return fmt.Errorf("could not get the device of %q: %v", source, err)
+	}
+	if mounted != expected {
+		return fmt.Errorf("%q is bound to another device than %q", mp.Path, source)
+	}
+	if hasMountOption(mp.Opts, "ro") != readOnly {
+		return fmt.Errorf("%q is mounted with read-only set to %v", mp.Path, !readOnly)
+	}
+	return nil
+}
+
+// isSameDevice returns whether both paths refer to the same device. The mount table lists
+// the device itself, while requests may refer to it through a symlink.
+func isSameDevice(device, source string) bool {
+	if device == source {
+		return true
+	}
+	resolvedDevice, err := filepath.EvalSymlinks(device)
+	if err != nil {
+		return false
+	}
+	resolvedSource, err := filepath.EvalSymlinks(source)
+	if err != nil {
+		return false
+	}
+	return resolvedDevice == resolvedSource
+}
@@ -20,20 +20,27 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 
-	csi "github.com/container-storage-interface/spec/lib/go/csi/v0"
-	"github.com/golang/glog"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/cloud"
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/logging"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
 func (d *Driver) NodeStageVolume(ctx context.Context, req *csi.NodeStageVolumeRequest) (*csi.NodeStageVolumeResponse, error) {
-	glog.V(4).Infof("NodeStageVolume: called with args %#v", req)
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
 	}
 
+	key := volumeIDKey(volumeID)
+	if ok := d.inFlight.insert(key); !ok {
+		return nil, errOperationInFlight(volumeID)
+	}
+	defer d.inFlight.delete(key)
+
 	target := req.GetStagingTargetPath()
 	if len(target) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Staging target not provided")
@@ -48,56 +55,117 @@ func (d *Driver) NodeStageVolume(ctx context.Context, req *csi.NodeStageVolumeRe
 		return nil, status.Error(codes.InvalidArgument, "Volume capability not supported")
 	}
 
-	source, ok := req.PublishInfo["devicePath"]
+	fsType, err := getFsType(volCap, req.GetVolumeContext())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid filesystem type: %v", err)
+	}
+
+	devicePath, ok := req.GetPublishContext()["devicePath"]
 	if !ok {
 		return nil, status.Error(codes.InvalidArgument, "Device path not provided")
 	}
+	source := d.deviceResolver.findDevicePath(ctx, devicePath, volumeID)
+
+	// Raw block volumes are published straight from the device, so there is nothing to stage.
+	if volCap.GetBlock() != nil {
+		logging.FromContext(ctx).V(5).Infof("NodeStageVolume: volume %s has block access type, skipping staging", volumeID)
+		return &csi.NodeStageVolumeResponse{}, nil
+	}
+
+	options := fileSystems[fsType].mountOptions
+	if isReadOnlyAccessMode(volCap) {
+		options = append([]string{"ro"}, options...)
+	}
+	options, err = collectMountOptions(options, volCap.GetMount().GetMountFlags())
+	if err != nil {
+		msg := fmt.Sprintf("invalid mount flags: %v", err)
+		return nil, status.Error(codes.InvalidArgument, msg)
+	}
 
-	// TODO: consider replacing IsLikelyNotMountPoint by IsNotMountPoint
-	notMnt, err := d.mounter.Interface.IsLikelyNotMountPoint(target)
+	// Read-only volumes are neither formatted, checked nor resized. FormatAndMount
+	// fails to mount them if they are not formatted yet.
+	readOnly := hasMountOption(options, "ro")
+	if readOnly {
+		options = append(options, fileSystems[fsType].readOnlyMountOptions...)
+	}
+
+	mp, err := d.findMountPoint(target)
 	if err != nil {
-		if os.IsNotExist(err) {
-			if errMkDir := d.mounter.Interface.MakeDir(target); errMkDir != nil {
-				msg := fmt.Sprintf("could not create target dir %q: %v", target, errMkDir)
-				return nil, status.Error(codes.Internal, msg)
-			}
-			notMnt = true
-		} else {
-			msg := fmt.Sprintf("could not determine if %q is valid mount point: %v", target, err)
-			return nil, status.Error(codes.Internal, msg)
+		msg := fmt.Sprintf("could not determine if %q is a mount point: %v", target, err)
+		return nil, status.Error(codes.Internal, msg)
+	}
+	if mp != nil {
+		if err := verifyMountPoint(mp, source, fsType, readOnly); err != nil {
+			msg := fmt.Sprintf("staging target %q is already in use: %v", target, err)
+			return nil, status.Error(codes.AlreadyExists, msg)
 		}
+		logging.FromContext(ctx).V(5).Infof("NodeStageVolume: volume %s is already staged at %s", volumeID, target)
+		return &csi.NodeStageVolumeResponse{}, nil
 	}
 
-	if !notMnt {
-		msg := fmt.Sprintf("target %q is not a valid mount point", target)
-		return nil, status.Error(codes.InvalidArgument, msg)
+	if err := d.mounter.Interface.MakeDir(target); err != nil {
+		msg := fmt.Sprintf("could not create target dir %q: %v", target, err)
+		return nil, status.Error(codes.Internal, msg)
+	}
+
+	if !readOnly {
+		logging.FromContext(ctx).V(5).Infof("NodeStageVolume: formatting %s with fstype %s if needed", source, fsType)
+		if err := d.formatIfNeeded(ctx, source, fsType); err != nil {
+			msg := fmt.Sprintf("could not format %q: %v", source, err)
+			return nil, status.Error(codes.Internal, msg)
+		}
 	}
 
-	// FormatAndMount will format only if needed
-	glog.V(5).Infof("NodeStageVolume: formatting %s and mounting at %s", source, target)
-	err = d.mounter.FormatAndMount(source, target, "ext4", nil)
+	// FormatAndMount only mounts the device as it's already formatted
+	logging.FromContext(ctx).V(5).Infof("NodeStageVolume: mounting %s at %s with fstype %s and options %v", source, target, fsType, options)
+	err = d.mounter.FormatAndMount(source, target, fsType, options)
 	if err != nil {
 		msg := fmt.Sprintf("could not format %q and mount it at %q", source, target)
 		return nil, status.Error(codes.Internal, msg)
 	}
 
+	// The volume may have been expanded since it was formatted, so the filesystem
+	// is grown to fill the device.
+	if !readOnly {
+		if err := d.resizeFs(ctx, source, target, fsType); err != nil {
+			msg := fmt.Sprintf("could not resize filesystem of %q mounted at %q: %v", source, target, err)
+			return nil, status.Error(codes.Internal, msg)
+		}
+	}
+
 	return &csi.NodeStageVolumeResponse{}, nil
 }
 
 func (d *Driver) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstageVolumeRequest) (*csi.NodeUnstageVolumeResponse, error) {
-	glog.V(4).Infof("NodeUnstageVolume: called with args %#v", req)
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
 	}
 
+	key := volumeIDKey(volumeID)
+	if ok := d.inFlight.insert(key); !ok {
+		return nil, errOperationInFlight(volumeID)
+	}
+	defer d.inFlight.delete(key)
+
 	target := req.GetStagingTargetPath()
 	if len(target) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Staging target not provided")
 	}
 
-	glog.V(5).Infof("NodeUnstageVolume: unmounting %s", target)
-	err := d.mounter.Interface.Unmount(target)
+	// Raw block volumes are not mounted at the staging target, and retries may find
+	// the target already unmounted.
+	mp, err := d.findMountPoint(target)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not determine if %q is a mount point: %v", target, err)
+	}
+	if mp == nil {
+		logging.FromContext(ctx).V(5).Infof("NodeUnstageVolume: %s is not mounted, skipping unmount", target)
+		return &csi.NodeUnstageVolumeResponse{}, nil
+	}
+
+	logging.FromContext(ctx).V(5).Infof("NodeUnstageVolume: unmounting %s", target)
+	err = d.mounter.Interface.Unmount(target)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "Could not unmount target %q: %v", target, err)
 	}
@@ -106,12 +174,17 @@ func (d *Driver) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstageVolu
 }
 
 func (d *Driver) NodePublishVolume(ctx context.Context, req *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
-	glog.V(4).Infof("NodePublishVolume: called with args %#v", req)
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
 	}
 
+	key := publishKey(volumeID, req.GetTargetPath())
+	if ok := d.inFlight.insert(key); !ok {
+		return nil, errOperationInFlight(volumeID)
+	}
+	defer d.inFlight.delete(key)
+
 	source := req.GetStagingTargetPath()
 	if len(source) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Staging target not provided")
@@ -132,38 +205,141 @@ func (d *Driver) NodePublishVolume(ctx context.Context, req *csi.NodePublishVolu
 	}
 
 	options := []string{"bind"}
-	if req.GetReadonly() {
+	if req.GetReadonly() || isReadOnlyAccessMode(volCap) {
 		options = append(options, "ro")
 	}
+	options, err := collectMountOptions(options, volCap.GetMount().GetMountFlags())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid mount flags: %v", err)
+	}
+
+	if volCap.GetBlock() != nil {
+		err = d.nodePublishVolumeForBlock(ctx, req, options)
+	} else {
+		err = d.nodePublishVolumeForFileSystem(ctx, req, options)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &csi.NodePublishVolumeResponse{}, nil
+}
+
+// nodePublishVolumeForBlock bind mounts the device of a raw block volume onto a file at the target path.
+func (d *Driver) nodePublishVolumeForBlock(ctx context.Context, req *csi.NodePublishVolumeRequest, options []string) error {
+	target := req.GetTargetPath()
+
+	devicePath, ok := req.GetPublishContext()["devicePath"]
+	if !ok {
+		return status.Error(codes.InvalidArgument, "Device path not provided")
+	}
+	source := d.deviceResolver.findDevicePath(ctx, devicePath, req.GetVolumeId())
+
+	mp, err := d.findMountPoint(target)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Could not determine if %q is a mount point: %v", target, err)
+	}
+	if mp != nil {
+		if err := d.verifyBlockMountPoint(mp, source, hasMountOption(options, "ro")); err != nil {
+			return status.Errorf(codes.AlreadyExists, "Target %q is already in use: %v", target, err)
+		}
+		logging.FromContext(ctx).V(5).Infof("NodePublishVolume: device %s is already published at %s", source, target)
+		return nil
+	}
+
+	targetDir := filepath.Dir(target)
+	logging.FromContext(ctx).V(5).Infof("NodePublishVolume: creating dir %s", targetDir)
+	if err := d.mounter.Interface.MakeDir(targetDir); err != nil {
+		return status.Errorf(codes.Internal, "Could not create dir %q: %v", targetDir, err)
+	}
+
+	logging.FromContext(ctx).V(5).Infof("NodePublishVolume: creating file %s", target)
+	if err := d.mounter.Interface.MakeFile(target); err != nil {
+		return status.Errorf(codes.Internal, "Could not create file %q: %v", target, err)
+	}
+
+	logging.FromContext(ctx).V(5).Infof("NodePublishVolume: mounting %s at %s", source, target)
+	if err := d.mounter.Interface.Mount(source, target, "", options); err != nil {
+		os.Remove(target)
+		return status.Errorf(codes.Internal, "Could not mount %q at %q: %v", source, target, err)
+	}
+
+	return nil
+}
+
+// nodePublishVolumeForFileSystem bind mounts the staged filesystem of a volume onto the target path.
+func (d *Driver) nodePublishVolumeForFileSystem(ctx context.Context, req *csi.NodePublishVolumeRequest, options []string) error {
+	source := req.GetStagingTargetPath()
+	target := req.GetTargetPath()
+
+	fsType, err := getFsType(req.GetVolumeCapability(), req.GetVolumeContext())
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "Invalid filesystem type: %v", err)
+	}
+
+	mp, err := d.findMountPoint(target)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Could not determine if %q is a mount point: %v", target, err)
+	}
+	if mp != nil {
+		// Bind mounts are listed with the device of the staged filesystem as their source
+		device := source
+		stagingMp, err := d.findMountPoint(source)
+		if err != nil {
+			return status.Errorf(codes.Internal, "Could not determine if %q is a mount point: %v", source, err)
+		}
+		if stagingMp != nil {
+			device = stagingMp.Device
+		}
+		if err := verifyMountPoint(mp, device, "", hasMountOption(options, "ro")); err != nil {
+			return status.Errorf(codes.AlreadyExists, "Target %q is already in use: %v", target, err)
+		}
+		logging.FromContext(ctx).V(5).Infof("NodePublishVolume: %s is already published at %s", source, target)
+		return nil
+	}
 
-	glog.V(5).Infof("NodePublishVolume: creating dir %s", target)
+	logging.FromContext(ctx).V(5).Infof("NodePublishVolume: creating dir %s", target)
 	if err := d.mounter.Interface.MakeDir(target); err != nil {
-		return nil, status.Errorf(codes.Internal, "Could not create dir %q: %v", target, err)
+		return status.Errorf(codes.Internal, "Could not create dir %q: %v", target, err)
 	}
 
-	glog.V(5).Infof("NodePublishVolume: mounting %s at %s", source, target)
-	if err := d.mounter.Interface.Mount(source, target, "ext4", options); err != nil {
+	logging.FromContext(ctx).V(5).Infof("NodePublishVolume: mounting %s at %s", source, target)
+	if err := d.mounter.Interface.Mount(source, target, fsType, options); err != nil {
 		os.Remove(target)
-		return nil, status.Errorf(codes.Internal, "Could not mount %q at %q: %v", source, target, err)
+		return status.Errorf(codes.Internal, "Could not mount %q at %q: %v", source, target, err)
 	}
 
-	return &csi.NodePublishVolumeResponse{}, nil
+	return nil
 }
 
 func (d *Driver) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpublishVolumeRequest) (*csi.NodeUnpublishVolumeResponse, error) {
-	glog.V(4).Infof("NodeUnpublishVolume: called with args %#v", req)
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
 	}
 
+	key := publishKey(volumeID, req.GetTargetPath())
+	if ok := d.inFlight.insert(key); !ok {
+		return nil, errOperationInFlight(volumeID)
+	}
+	defer d.inFlight.delete(key)
+
 	target := req.GetTargetPath()
 	if len(target) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Target path not provided")
 	}
 
-	glog.V(5).Infof("NodeUnpublishVolume: unmounting %s", target)
-	err := d.mounter.Interface.Unmount(target)
+	mp, err := d.findMountPoint(target)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not determine if %q is a mount point: %v", target, err)
+	}
+	if mp == nil {
+		logging.FromContext(ctx).V(5).Infof("NodeUnpublishVolume: %s is not mounted, skipping unmount", target)
+		return &csi.NodeUnpublishVolumeResponse{}, nil
+	}
+
+	logging.FromContext(ctx).V(5).Infof("NodeUnpublishVolume: unmounting %s", target)
+	err = d.mounter.Interface.Unmount(target)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "Could not unmount %q: %v", target, err)
 	}
@@ -171,8 +347,84 @@ func (d *Driver) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpublish
 	return &csi.NodeUnpublishVolumeResponse{}, nil
 }
 
+func (d *Driver) NodeExpandVolume(ctx context.Context, req *csi.NodeExpandVolumeRequest) (*csi.NodeExpandVolumeResponse, error) {
+	volumeID := req.GetVolumeId()
+	if len(volumeID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
+	}
+
+	volumePath := req.GetVolumePath()
+	if len(volumePath) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume path not provided")
+	}
+
+	key := volumeIDKey(volumeID)
+	if ok := d.inFlight.insert(key); !ok {
+		return nil, errOperationInFlight(volumeID)
+	}
+	defer d.inFlight.delete(key)
+
+	mp, err := d.findMountPoint(volumePath)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not determine if %q is a mount point: %v", volumePath, err)
+	}
+	if mp == nil {
+		return nil, status.Errorf(codes.NotFound, "Volume path %q is not mounted", volumePath)
+	}
+
+	// Raw block volumes are bind mounts of the device, which has no filesystem to grow
+	if _, ok := fileSystems[mp.Type]; !ok {
+		logging.FromContext(ctx).V(5).Infof("NodeExpandVolume: %s has no filesystem to resize", volumePath)
+		return &csi.NodeExpandVolumeResponse{}, nil
+	}
+	if hasMountOption(mp.Opts, "ro") {
+		return nil, status.Errorf(codes.FailedPrecondition, "Volume path %q is mounted read-only", volumePath)
+	}
+
+	logging.FromContext(ctx).V(5).Infof("NodeExpandVolume: resizing filesystem %s of %s mounted at %s", mp.Type, mp.Device, volumePath)
+	if err := d.resizeFs(ctx, mp.Device, volumePath, mp.Type); err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not resize filesystem of %q mounted at %q: %v", mp.Device, volumePath, err)
+	}
+
+	return &csi.NodeExpandVolumeResponse{}, nil
+}
+
+func (d *Driver) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
+	if len(req.GetVolumeId()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
+	}
+
+	volumePath := req.GetVolumePath()
+	if len(volumePath) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume path not provided")
+	}
+
+	stats, err := d.getVolumeStats(volumePath)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := []*csi.VolumeUsage{
+		{
+			Unit:      csi.VolumeUsage_BYTES,
+			Available: stats.availableBytes,
+			Total:     stats.totalBytes,
+			Used:      stats.usedBytes,
+		},
+	}
+	// Raw block volumes and some filesystems, such as btrfs, have no inode count
+	if stats.totalInodes > 0 {
+		usage = append(usage, &csi.VolumeUsage{
+			Unit:      csi.VolumeUsage_INODES,
+			Available: stats.availableInodes,
+			Total:     stats.totalInodes,
+			Used:      stats.usedInodes,
+		})
+	}
+	return &csi.NodeGetVolumeStatsResponse{Usage: usage}, nil
+}
+
 func (d *Driver) NodeGetCapabilities(ctx context.Context, req *csi.NodeGetCapabilitiesRequest) (*csi.NodeGetCapabilitiesResponse, error) {
-	glog.V(4).Infof("NodeGetCapabilities: called with args %#v", req)
 	var caps []*csi.NodeServiceCapability
 	for _, cap := range d.nodeCaps {
 		c := &csi.NodeServiceCapability{
@@ -188,23 +440,28 @@ func (d *Driver) NodeGetCapabilities(ctx context.Context, req *csi.NodeGetCapabi
 }
 
 func (d *Driver) NodeGetInfo(ctx context.Context, req *csi.NodeGetInfoRequest) (*csi.NodeGetInfoResponse, error) {
-	glog.V(4).Infof("NodeGetInfo: called with args %#v", req)
 	m := d.cloud.GetMetadata()
 
-	topology := &csi.Topology{
-		Segments: map[string]string{topologyKey: m.GetAvailabilityZone()},
-	}
-
 	return &csi.NodeGetInfoResponse{
 		NodeId:             m.GetInstanceID(),
-		AccessibleTopology: topology,
+		MaxVolumesPerNode:  d.getVolumesLimit(),
+		AccessibleTopology: newZoneTopology(m.GetAvailabilityZone()),
 	}, nil
 }
 
-func (d *Driver) NodeGetId(ctx context.Context, req *csi.NodeGetIdRequest) (*csi.NodeGetIdResponse, error) {
-	glog.V(4).Infof("NodeGetId: called with args %#v", req)
+// getVolumesLimit returns the number of CSI volumes that can be attached to the node.
+// The root volume and the volumes the instance was launched with are not managed by
+// the driver, so their attachments are reserved.
+func (d *Driver) getVolumesLimit() int64 {
+	if d.volumeAttachLimit >= 0 {
+		return d.volumeAttachLimit
+	}
+
 	m := d.cloud.GetMetadata()
-	return &csi.NodeGetIdResponse{
-		NodeId: m.GetInstanceID(),
-	}, nil
+	limit := cloud.GetMaxEBSAttachments(m.GetInstanceType(), m.GetNumAttachedENIs()) - m.GetNumBlockDeviceMappings()
+	// A limit of 0 means there is no limit, so at least one volume is reported
+	if limit < 1 {
+		limit = 1
+	}
+	return int64(limit)
 }
@@ -0,0 +1,903 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/cloud"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/kubernetes/pkg/util/mount"
+)
+
+func TestNodeStageVolume(t *testing.T) {
+	stdVolCap := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{},
+		},
+		AccessMode: &csi.VolumeCapability_AccessMode{
+			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+		},
+	}
+	blockVolCap := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Block{
+			Block: &csi.VolumeCapability_BlockVolume{},
+		},
+		AccessMode: &csi.VolumeCapability_AccessMode{
+			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+		},
+	}
+	devicePath := "/dev/xvdbc"
+	stagingPath := "/test/staging/path"
+
+	testCases := []struct {
+		name        string
+		req         *csi.NodeStageVolumeRequest
+		mountPoints []mount.MountPoint
+		expFsType   string
+		expNoMount  bool
+		expReadOnly bool
+		expErrCode  codes.Code
+	}{
+		{
+			name: "success normal",
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:          "vol-test",
+				PublishContext:    map[string]string{"devicePath": devicePath},
+				StagingTargetPath: stagingPath,
+				VolumeCapability:  stdVolCap,
+			},
+			expFsType: defaultFsType,
+		},
+		{
+			name: "success with fsType attribute",
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:          "vol-test",
+				PublishContext:    map[string]string{"devicePath": devicePath},
+				StagingTargetPath: stagingPath,
+				VolumeCapability:  stdVolCap,
+				VolumeContext:     map[string]string{FsTypeKey: "xfs"},
+			},
+			expFsType: "xfs",
+		},
+		{
+			name: "success with fsType in volume capability",
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:          "vol-test",
+				PublishContext:    map[string]string{"devicePath": devicePath},
+				StagingTargetPath: stagingPath,
+				VolumeCapability: &csi.VolumeCapability{
+					AccessType: &csi.VolumeCapability_Mount{
+						Mount: &csi.VolumeCapability_MountVolume{FsType: "btrfs"},
+					},
+					AccessMode: stdVolCap.AccessMode,
+				},
+				VolumeContext: map[string]string{FsTypeKey: "xfs"},
+			},
+			expFsType: "btrfs",
+		},
+		{
+			name: "success read-only access mode",
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:          "vol-test",
+				PublishContext:    map[string]string{"devicePath": devicePath},
+				StagingTargetPath: stagingPath,
+				VolumeCapability: &csi.VolumeCapability{
+					AccessType: stdVolCap.AccessType,
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+					},
+				},
+			},
+			expFsType:   defaultFsType,
+			expReadOnly: true,
+		},
+		{
+			name: "success with mount flags",
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:          "vol-test",
+				PublishContext:    map[string]string{"devicePath": devicePath},
+				StagingTargetPath: stagingPath,
+				VolumeCapability: &csi.VolumeCapability{
+					AccessType: &csi.VolumeCapability_Mount{
+						Mount: &csi.VolumeCapability_MountVolume{MountFlags: []string{"noatime", "ro"}},
+					},
+					AccessMode: stdVolCap.AccessMode,
+				},
+			},
+			expFsType:   defaultFsType,
+			expReadOnly: true,
+		},
+		{
+			name: "success block volume is not formatted",
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:          "vol-test",
+				PublishContext:    map[string]string{"devicePath": devicePath},
+				StagingTargetPath: stagingPath,
+				VolumeCapability:  blockVolCap,
+			},
+			expNoMount: true,
+		},
+		{
+			name: "success already staged",
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:          "vol-test",
+				PublishContext:    map[string]string{"devicePath": devicePath},
+				StagingTargetPath: stagingPath,
+				VolumeCapability:  stdVolCap,
+			},
+			mountPoints: []mount.MountPoint{{Device: devicePath, Path: stagingPath, Type: defaultFsType}},
+			expNoMount:  true,
+		},
+		{
+			name: "fail staging target mounted from another device",
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:          "vol-test",
+				PublishContext:    map[string]string{"devicePath": devicePath},
+				StagingTargetPath: stagingPath,
+				VolumeCapability:  stdVolCap,
+			},
+			mountPoints: []mount.MountPoint{{Device: "/dev/xvdcd", Path: stagingPath, Type: defaultFsType}},
+			expErrCode:  codes.AlreadyExists,
+		},
+		{
+			name: "fail staging target mounted with another filesystem",
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:          "vol-test",
+				PublishContext:    map[string]string{"devicePath": devicePath},
+				StagingTargetPath: stagingPath,
+				VolumeCapability:  stdVolCap,
+			},
+			mountPoints: []mount.MountPoint{{Device: devicePath, Path: stagingPath, Type: "xfs"}},
+			expErrCode:  codes.AlreadyExists,
+		},
+		{
+			name: "fail staging target mounted read-only",
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:          "vol-test",
+				PublishContext:    map[string]string{"devicePath": devicePath},
+				StagingTargetPath: stagingPath,
+				VolumeCapability:  stdVolCap,
+			},
+			mountPoints: []mount.MountPoint{{Device: devicePath, Path: stagingPath, Type: defaultFsType, Opts: []string{"ro"}}},
+			expErrCode:  codes.AlreadyExists,
+		},
+		{
+			name: "fail no device path",
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:          "vol-test",
+				StagingTargetPath: stagingPath,
+				VolumeCapability:  stdVolCap,
+			},
+			expErrCode: codes.InvalidArgument,
+		},
+		{
+			name: "fail no access type",
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:          "vol-test",
+				PublishContext:    map[string]string{"devicePath": devicePath},
+				StagingTargetPath: stagingPath,
+				VolumeCapability: &csi.VolumeCapability{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+			expErrCode: codes.InvalidArgument,
+		},
+		{
+			name: "fail unsupported fsType",
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:          "vol-test",
+				PublishContext:    map[string]string{"devicePath": devicePath},
+				StagingTargetPath: stagingPath,
+				VolumeCapability: &csi.VolumeCapability{
+					AccessType: &csi.VolumeCapability_Mount{
+						Mount: &csi.VolumeCapability_MountVolume{FsType: "vfat"},
+					},
+					AccessMode: stdVolCap.AccessMode,
+				},
+			},
+			expErrCode: codes.InvalidArgument,
+		},
+		{
+			name: "fail conflicting mount flags",
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:          "vol-test",
+				PublishContext:    map[string]string{"devicePath": devicePath},
+				StagingTargetPath: stagingPath,
+				VolumeCapability: &csi.VolumeCapability{
+					AccessType: &csi.VolumeCapability_Mount{
+						Mount: &csi.VolumeCapability_MountVolume{MountFlags: []string{"sync", "async"}},
+					},
+					AccessMode: stdVolCap.AccessMode,
+				},
+			},
+			expErrCode: codes.InvalidArgument,
+		},
+		{
+			name: "fail rw mount flag on read-only access mode",
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:          "vol-test",
+				PublishContext:    map[string]string{"devicePath": devicePath},
+				StagingTargetPath: stagingPath,
+				VolumeCapability: &csi.VolumeCapability{
+					AccessType: &csi.VolumeCapability_Mount{
+						Mount: &csi.VolumeCapability_MountVolume{MountFlags: []string{"rw"}},
+					},
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+					},
+				},
+			},
+			expErrCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		var cmds [][]string
+		mounter := NewFakeMounter()
+		mounter.Exec = mount.NewFakeExec(func(cmd string, args ...string) ([]byte, error) {
+			cmds = append(cmds, append([]string{cmd}, args...))
+			return nil, nil
+		})
+		if tc.mountPoints != nil {
+			mounter.Interface.(*mount.FakeMounter).MountPoints = tc.mountPoints
+		}
+		awsDriver := NewDriver(cloud.NewFakeCloudProvider(), mounter, "")
+
+		_, err := awsDriver.NodeStageVolume(context.TODO(), tc.req)
+		if err != nil {
+			srvErr, ok := status.FromError(err)
+			if !ok {
+				t.Fatalf("Could not get error status code from error: %v", srvErr)
+			}
+			if srvErr.Code() != tc.expErrCode {
+				t.Fatalf("Expected error code %d, got %d message %s", tc.expErrCode, srvErr.Code(), srvErr.Message())
+			}
+			continue
+		}
+		if tc.expErrCode != codes.OK {
+			t.Fatalf("Expected error %v, got no error", tc.expErrCode)
+		}
+
+		fakeMounter := mounter.Interface.(*mount.FakeMounter)
+		if tc.expNoMount {
+			if len(fakeMounter.Log) != 0 {
+				t.Fatalf("Expected no mount action, got %+v", fakeMounter.Log)
+			}
+			continue
+		}
+		if len(fakeMounter.Log) != 1 {
+			t.Fatalf("Expected 1 mount action, got %d", len(fakeMounter.Log))
+		}
+		action := fakeMounter.Log[0]
+		if action.Source != devicePath || action.Target != stagingPath || action.FSType != tc.expFsType {
+			t.Fatalf("Expected %s to be mounted at %s with fstype %s, got %+v", devicePath, stagingPath, tc.expFsType, action)
+		}
+		if readOnly := isMountedReadOnly(fakeMounter, stagingPath); readOnly != tc.expReadOnly {
+			t.Fatalf("Expected read-only mount to be %v, got %v", tc.expReadOnly, readOnly)
+		}
+		// The device must not be checked, formatted or resized when it is mounted read-only
+		if tc.expReadOnly && len(cmds) != 0 {
+			t.Fatalf("Expected no command to be run, got %v", cmds)
+		}
+	}
+}
+
+func TestNodePublishVolume(t *testing.T) {
+	mountVolCap := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{},
+		},
+		AccessMode: &csi.VolumeCapability_AccessMode{
+			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+		},
+	}
+	blockVolCap := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Block{
+			Block: &csi.VolumeCapability_BlockVolume{},
+		},
+		AccessMode: &csi.VolumeCapability_AccessMode{
+			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+		},
+	}
+	devicePath := "/dev/xvdbc"
+	stagingPath := "/test/staging/path"
+	targetPath := "/test/target/path"
+
+	testCases := []struct {
+		name        string
+		req         *csi.NodePublishVolumeRequest
+		mountPoints []mount.MountPoint
+		// deviceNumbers are the device numbers of the block device nodes by path
+		deviceNumbers map[string]uint64
+		expSource     string
+		expFsType     string
+		expNoMount    bool
+		expReadOnly   bool
+		expErrCode    codes.Code
+	}{
+		{
+			name: "success filesystem",
+			req: &csi.NodePublishVolumeRequest{
+				VolumeId:          "vol-test",
+				PublishContext:    map[string]string{"devicePath": devicePath},
+				StagingTargetPath: stagingPath,
+				TargetPath:        targetPath,
+				VolumeCapability:  mountVolCap,
+			},
+			expSource: stagingPath,
+			expFsType: defaultFsType,
+		},
+		{
+			name: "success block",
+			req: &csi.NodePublishVolumeRequest{
+				VolumeId:          "vol-test",
+				PublishContext:    map[string]string{"devicePath": devicePath},
+				StagingTargetPath: stagingPath,
+				TargetPath:        targetPath,
+				VolumeCapability:  blockVolCap,
+			},
+			expSource: devicePath,
+			expFsType: "",
+		},
+		{
+			name: "success read-only access mode",
+			req: &csi.NodePublishVolumeRequest{
+				VolumeId:          "vol-test",
+				StagingTargetPath: stagingPath,
+				TargetPath:        targetPath,
+				VolumeCapability: &csi.VolumeCapability{
+					AccessType: mountVolCap.AccessType,
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+					},
+				},
+			},
+			expSource:   stagingPath,
+			expFsType:   defaultFsType,
+			expReadOnly: true,
+		},
+		{
+			name: "success with mount flags",
+			req: &csi.NodePublishVolumeRequest{
+				VolumeId:          "vol-test",
+				StagingTargetPath: stagingPath,
+				TargetPath:        targetPath,
+				VolumeCapability: &csi.VolumeCapability{
+					AccessType: &csi.VolumeCapability_Mount{
+						Mount: &csi.VolumeCapability_MountVolume{MountFlags: []string{"nosuid,ro"}},
+					},
+					AccessMode: mountVolCap.AccessMode,
+				},
+			},
+			expSource:   stagingPath,
+			expFsType:   defaultFsType,
+			expReadOnly: true,
+		},
+		{
+			name: "fail rw mount flag on read-only publish",
+			req: &csi.NodePublishVolumeRequest{
+				VolumeId:          "vol-test",
+				StagingTargetPath: stagingPath,
+				TargetPath:        targetPath,
+				VolumeCapability: &csi.VolumeCapability{
+					AccessType: &csi.VolumeCapability_Mount{
+						Mount: &csi.VolumeCapability_MountVolume{MountFlags: []string{"rw"}},
+					},
+					AccessMode: mountVolCap.AccessMode,
+				},
+				Readonly: true,
+			},
+			expErrCode: codes.InvalidArgument,
+		},
+		{
+			name: "success filesystem already published",
+			req: &csi.NodePublishVolumeRequest{
+				VolumeId:          "vol-test",
+				StagingTargetPath: stagingPath,
+				TargetPath:        targetPath,
+				VolumeCapability:  mountVolCap,
+			},
+			mountPoints: []mount.MountPoint{
+				{Device: devicePath, Path: stagingPath, Type: defaultFsType},
+				{Device: devicePath, Path: targetPath, Type: defaultFsType},
+			},
+			expNoMount: true,
+		},
+		{
+			name: "success block already published",
+			req: &csi.NodePublishVolumeRequest{
+				VolumeId:          "vol-test",
+				PublishContext:    map[string]string{"devicePath": devicePath},
+				StagingTargetPath: stagingPath,
+				TargetPath:        targetPath,
+				VolumeCapability:  blockVolCap,
+			},
+			mountPoints:   []mount.MountPoint{{Device: "devtmpfs", Path: targetPath, Type: "devtmpfs"}},
+			deviceNumbers: map[string]uint64{devicePath: 0xca80, targetPath: 0xca80},
+			expNoMount:    true,
+		},
+		{
+			name: "fail block target bound to another device",
+			req: &csi.NodePublishVolumeRequest{
+				VolumeId:          "vol-test",
+				PublishContext:    map[string]string{"devicePath": devicePath},
+				StagingTargetPath: stagingPath,
+				TargetPath:        targetPath,
+				VolumeCapability:  blockVolCap,
+			},
+			mountPoints:   []mount.MountPoint{{Device: "devtmpfs", Path: targetPath, Type: "devtmpfs"}},
+			deviceNumbers: map[string]uint64{devicePath: 0xca80, targetPath: 0xca90},
+			expErrCode:    codes.AlreadyExists,
+		},
+		{
+			name: "fail target bound to another volume",
+			req: &csi.NodePublishVolumeRequest{
+				VolumeId:          "vol-test",
+				StagingTargetPath: stagingPath,
+				TargetPath:        targetPath,
+				VolumeCapability:  mountVolCap,
+			},
+			mountPoints: []mount.MountPoint{
+				{Device: devicePath, Path: stagingPath, Type: defaultFsType},
+				{Device: "/dev/xvdcd", Path: targetPath, Type: defaultFsType},
+			},
+			expErrCode: codes.AlreadyExists,
+		},
+		{
+			name: "fail target published read-write",
+			req: &csi.NodePublishVolumeRequest{
+				VolumeId:          "vol-test",
+				StagingTargetPath: stagingPath,
+				TargetPath:        targetPath,
+				VolumeCapability:  mountVolCap,
+				Readonly:          true,
+			},
+			mountPoints: []mount.MountPoint{
+				{Device: devicePath, Path: stagingPath, Type: defaultFsType},
+				{Device: devicePath, Path: targetPath, Type: defaultFsType},
+			},
+			expErrCode: codes.AlreadyExists,
+		},
+		{
+			name: "fail block without device path",
+			req: &csi.NodePublishVolumeRequest{
+				VolumeId:          "vol-test",
+				StagingTargetPath: stagingPath,
+				TargetPath:        targetPath,
+				VolumeCapability:  blockVolCap,
+			},
+			expErrCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		mounter := NewFakeMounter()
+		if tc.mountPoints != nil {
+			mounter.Interface.(*mount.FakeMounter).MountPoints = tc.mountPoints
+		}
+		awsDriver := NewDriver(cloud.NewFakeCloudProvider(), mounter, "")
+		awsDriver.deviceResolver.deviceNumber = func(path string) (uint64, error) {
+			if number, ok := tc.deviceNumbers[path]; ok {
+				return number, nil
+			}
+			return 0, fmt.Errorf("%q is not a block device", path)
+		}
+
+		_, err := awsDriver.NodePublishVolume(context.TODO(), tc.req)
+		if err != nil {
+			srvErr, ok := status.FromError(err)
+			if !ok {
+				t.Fatalf("Could not get error status code from error: %v", srvErr)
+			}
+			if srvErr.Code() != tc.expErrCode {
+				t.Fatalf("Expected error code %d, got %d message %s", tc.expErrCode, srvErr.Code(), srvErr.Message())
+			}
+			continue
+		}
+		if tc.expErrCode != codes.OK {
+			t.Fatalf("Expected error %v, got no error", tc.expErrCode)
+		}
+
+		fakeMounter := mounter.Interface.(*mount.FakeMounter)
+		if tc.expNoMount {
+			if len(fakeMounter.Log) != 0 {
+				t.Fatalf("Expected no mount action, got %+v", fakeMounter.Log)
+			}
+			continue
+		}
+		if len(fakeMounter.Log) != 1 {
+			t.Fatalf("Expected 1 mount action, got %d", len(fakeMounter.Log))
+		}
+		action := fakeMounter.Log[0]
+		if action.Source != tc.expSource || action.Target != targetPath || action.FSType != tc.expFsType {
+			t.Fatalf("Expected %s to be mounted at %s with fstype %s, got %+v", tc.expSource, targetPath, tc.expFsType, action)
+		}
+		if readOnly := isMountedReadOnly(fakeMounter, targetPath); readOnly != tc.expReadOnly {
+			t.Fatalf("Expected read-only mount to be %v, got %v", tc.expReadOnly, readOnly)
+		}
+	}
+}
+
+// isMountedReadOnly returns whether the fake mounter mounted the target read-only.
+func isMountedReadOnly(fakeMounter *mount.FakeMounter, target string) bool {
+	for _, mp := range fakeMounter.MountPoints {
+		if mp.Path == target {
+			return reflect.DeepEqual(mp.Opts, []string{"ro"})
+		}
+	}
+	return false
+}
+
+func TestNodeUnstageVolume(t *testing.T) {
+	devicePath := "/dev/xvdbc"
+	stagingPath := "/test/staging/path"
+
+	testCases := []struct {
+		name         string
+		req          *csi.NodeUnstageVolumeRequest
+		mountPoints  []mount.MountPoint
+		expUnmounted bool
+		expErrCode   codes.Code
+	}{
+		{
+			name: "success normal",
+			req: &csi.NodeUnstageVolumeRequest{
+				VolumeId:          "vol-test",
+				StagingTargetPath: stagingPath,
+			},
+			mountPoints:  []mount.MountPoint{{Device: devicePath, Path: stagingPath, Type: defaultFsType}},
+			expUnmounted: true,
+		},
+		{
+			name: "success already unstaged",
+			req: &csi.NodeUnstageVolumeRequest{
+				VolumeId:          "vol-test",
+				StagingTargetPath: stagingPath,
+			},
+		},
+		{
+			name: "fail no staging target",
+			req: &csi.NodeUnstageVolumeRequest{
+				VolumeId: "vol-test",
+			},
+			expErrCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		mounter := NewFakeMounter()
+		fakeMounter := mounter.Interface.(*mount.FakeMounter)
+		fakeMounter.MountPoints = tc.mountPoints
+		awsDriver := NewDriver(cloud.NewFakeCloudProvider(), mounter, "")
+
+		_, err := awsDriver.NodeUnstageVolume(context.TODO(), tc.req)
+		if err != nil {
+			srvErr, ok := status.FromError(err)
+			if !ok {
+				t.Fatalf("Could not get error status code from error: %v", srvErr)
+			}
+			if srvErr.Code() != tc.expErrCode {
+				t.Fatalf("Expected error code %d, got %d message %s", tc.expErrCode, srvErr.Code(), srvErr.Message())
+			}
+			continue
+		}
+		if tc.expErrCode != codes.OK {
+			t.Fatalf("Expected error %v, got no error", tc.expErrCode)
+		}
+
+		if unmounted := len(fakeMounter.Log) == 1 && fakeMounter.Log[0].Action == mount.FakeActionUnmount; unmounted != tc.expUnmounted {
+			t.Fatalf("Expected unmount to be %v, got actions %+v", tc.expUnmounted, fakeMounter.Log)
+		}
+		if len(fakeMounter.MountPoints) != 0 {
+			t.Fatalf("Expected no mount point left, got %+v", fakeMounter.MountPoints)
+		}
+	}
+}
+
+func TestNodeUnpublishVolume(t *testing.T) {
+	devicePath := "/dev/xvdbc"
+	targetPath := "/test/target/path"
+
+	testCases := []struct {
+		name         string
+		req          *csi.NodeUnpublishVolumeRequest
+		mountPoints  []mount.MountPoint
+		expUnmounted bool
+		expErrCode   codes.Code
+	}{
+		{
+			name: "success normal",
+			req: &csi.NodeUnpublishVolumeRequest{
+				VolumeId:   "vol-test",
+				TargetPath: targetPath,
+			},
+			mountPoints:  []mount.MountPoint{{Device: devicePath, Path: targetPath, Type: defaultFsType}},
+			expUnmounted: true,
+		},
+		{
+			name: "success already unpublished",
+			req: &csi.NodeUnpublishVolumeRequest{
+				VolumeId:   "vol-test",
+				TargetPath: targetPath,
+			},
+		},
+		{
+			name: "fail no target path",
+			req: &csi.NodeUnpublishVolumeRequest{
+				VolumeId: "vol-test",
+			},
+			expErrCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		mounter := NewFakeMounter()
+		fakeMounter := mounter.Interface.(*mount.FakeMounter)
+		fakeMounter.MountPoints = tc.mountPoints
+		awsDriver := NewDriver(cloud.NewFakeCloudProvider(), mounter, "")
+
+		_, err := awsDriver.NodeUnpublishVolume(context.TODO(), tc.req)
+		if err != nil {
+			srvErr, ok := status.FromError(err)
+			if !ok {
+				t.Fatalf("Could not get error status code from error: %v", srvErr)
+			}
+			if srvErr.Code() != tc.expErrCode {
+				t.Fatalf("Expected error code %d, got %d message %s", tc.expErrCode, srvErr.Code(), srvErr.Message())
+			}
+			continue
+		}
+		if tc.expErrCode != codes.OK {
+			t.Fatalf("Expected error %v, got no error", tc.expErrCode)
+		}
+
+		if unmounted := len(fakeMounter.Log) == 1 && fakeMounter.Log[0].Action == mount.FakeActionUnmount; unmounted != tc.expUnmounted {
+			t.Fatalf("Expected unmount to be %v, got actions %+v", tc.expUnmounted, fakeMounter.Log)
+		}
+		if len(fakeMounter.MountPoints) != 0 {
+			t.Fatalf("Expected no mount point left, got %+v", fakeMounter.MountPoints)
+		}
+	}
+}
+
+func TestResizeFs(t *testing.T) {
+	devicePath := "/dev/xvdbc"
+	mountPath := "/test/staging/path"
+
+	testCases := []struct {
+		name    string
+		fsType  string
+		expCmd  []string
+		execErr error
+		expErr  bool
+	}{
+		{
+			name:   "success ext4",
+			fsType: "ext4",
+			expCmd: []string{"resize2fs", devicePath},
+		},
+		{
+			name:   "success xfs",
+			fsType: "xfs",
+			expCmd: []string{"xfs_growfs", "-d", mountPath},
+		},
+		{
+			name:   "success btrfs",
+			fsType: "btrfs",
+			expCmd: []string{"btrfs", "filesystem", "resize", "max", mountPath},
+		},
+		{
+			name:   "success unsupported filesystem is skipped",
+			fsType: "vfat",
+		},
+		{
+			name:    "fail resize command failed",
+			fsType:  "ext4",
+			expCmd:  []string{"resize2fs", devicePath},
+			execErr: fmt.Errorf("exit status 1"),
+			expErr:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		var cmds [][]string
+		mounter := NewFakeMounter()
+		mounter.Exec = mount.NewFakeExec(func(cmd string, args ...string) ([]byte, error) {
+			cmds = append(cmds, append([]string{cmd}, args...))
+			return nil, tc.execErr
+		})
+		awsDriver := NewDriver(cloud.NewFakeCloudProvider(), mounter, "")
+
+		err := awsDriver.resizeFs(context.Background(), devicePath, mountPath, tc.fsType)
+		if err != nil {
+			if !tc.expErr {
+				t.Fatalf("resizeFs() failed: expected no error, got: %v", err)
+			}
+		} else if tc.expErr {
+			t.Fatal("resizeFs() failed: expected error, got nothing")
+		}
+
+		if tc.expCmd == nil {
+			if len(cmds) != 0 {
+				t.Fatalf("Expected no command to be run, got %v", cmds)
+			}
+			continue
+		}
+		if len(cmds) != 1 || !reflect.DeepEqual(cmds[0], tc.expCmd) {
+			t.Fatalf("Expected command %v to be run, got %v", tc.expCmd, cmds)
+		}
+	}
+}
+
+func TestNodeExpandVolume(t *testing.T) {
+	devicePath := "/dev/xvdbc"
+	volumePath := "/test/target/path"
+
+	testCases := []struct {
+		name        string
+		req         *csi.NodeExpandVolumeRequest
+		mountPoints []mount.MountPoint
+		execErr     error
+		expCmd      []string
+		expErrCode  codes.Code
+	}{
+		{
+			name: "success normal",
+			req: &csi.NodeExpandVolumeRequest{
+				VolumeId:   "vol-test",
+				VolumePath: volumePath,
+			},
+			mountPoints: []mount.MountPoint{{Device: devicePath, Path: volumePath, Type: "ext4"}},
+			expCmd:      []string{"resize2fs", devicePath},
+		},
+		{
+			name: "success raw block volume",
+			req: &csi.NodeExpandVolumeRequest{
+				VolumeId:   "vol-test",
+				VolumePath: volumePath,
+			},
+			mountPoints: []mount.MountPoint{{Device: "udev", Path: volumePath, Type: "devtmpfs"}},
+		},
+		{
+			name: "fail no volume id",
+			req: &csi.NodeExpandVolumeRequest{
+				VolumePath: volumePath,
+			},
+			expErrCode: codes.InvalidArgument,
+		},
+		{
+			name: "fail no volume path",
+			req: &csi.NodeExpandVolumeRequest{
+				VolumeId: "vol-test",
+			},
+			expErrCode: codes.InvalidArgument,
+		},
+		{
+			name: "fail not mounted",
+			req: &csi.NodeExpandVolumeRequest{
+				VolumeId:   "vol-test",
+				VolumePath: volumePath,
+			},
+			expErrCode: codes.NotFound,
+		},
+		{
+			name: "fail mounted read-only",
+			req: &csi.NodeExpandVolumeRequest{
+				VolumeId:   "vol-test",
+				VolumePath: volumePath,
+			},
+			mountPoints: []mount.MountPoint{{Device: devicePath, Path: volumePath, Type: "ext4", Opts: []string{"ro"}}},
+			expErrCode:  codes.FailedPrecondition,
+		},
+		{
+			name: "fail resize command failed",
+			req: &csi.NodeExpandVolumeRequest{
+				VolumeId:   "vol-test",
+				VolumePath: volumePath,
+			},
+			mountPoints: []mount.MountPoint{{Device: devicePath, Path: volumePath, Type: "ext4"}},
+			execErr:     fmt.Errorf("exit status 1"),
+			expCmd:      []string{"resize2fs", devicePath},
+			expErrCode:  codes.Internal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		var cmds [][]string
+		mounter := NewFakeMounter()
+		mounter.Interface.(*mount.FakeMounter).MountPoints = tc.mountPoints
+		mounter.Exec = mount.NewFakeExec(func(cmd string, args ...string) ([]byte, error) {
+			cmds = append(cmds, append([]string{cmd}, args...))
+			return nil, tc.execErr
+		})
+		awsDriver := NewDriver(cloud.NewFakeCloudProvider(), mounter, "")
+
+		_, err := awsDriver.NodeExpandVolume(context.TODO(), tc.req)
+		if err != nil {
+			srvErr, ok := status.FromError(err)
+			if !ok {
+				t.Fatalf("Could not get error status code from error: %v", srvErr)
+			}
+			if srvErr.Code() != tc.expErrCode {
+				t.Fatalf("Expected error code %d, got %d message %s", tc.expErrCode, srvErr.Code(), srvErr.Message())
+			}
+		} else if tc.expErrCode != codes.OK {
+			t.Fatalf("Expected error %v, got no error", tc.expErrCode)
+		}
+
+		if tc.expCmd == nil {
+			if len(cmds) != 0 {
+				t.Fatalf("Expected no command to be run, got %v", cmds)
+			}
+			continue
+		}
+		if len(cmds) != 1 || !reflect.DeepEqual(cmds[0], tc.expCmd) {
+			t.Fatalf("Expected command %v to be run, got %v", tc.expCmd, cmds)
+		}
+	}
+}
+
+func TestNodeGetInfo(t *testing.T) {
+	testCases := []struct {
+		name              string
+		volumeAttachLimit int64
+		expMaxVolumes     int64
+	}{
+		{
+			name:              "success instance type limit",
+			volumeAttachLimit: -1,
+			// The fake m5.large has 1 network interface and a root volume
+			expMaxVolumes: 26,
+		},
+		{
+			name:              "success overridden limit",
+			volumeAttachLimit: 10,
+			expMaxVolumes:     10,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		awsDriver := NewDriver(cloud.NewFakeCloudProvider(), NewFakeMounter(), "", WithVolumeAttachLimit(tc.volumeAttachLimit))
+
+		resp, err := awsDriver.NodeGetInfo(context.TODO(), &csi.NodeGetInfoRequest{})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if resp.GetMaxVolumesPerNode() != tc.expMaxVolumes {
+			t.Fatalf("Expected max volumes per node %d, got %d", tc.expMaxVolumes, resp.GetMaxVolumesPerNode())
+		}
+		expSegments := map[string]string{topologyKey: "az", wellKnownTopologyKey: "az"}
+		if segments := resp.GetAccessibleTopology().GetSegments(); !reflect.DeepEqual(segments, expSegments) {
+			t.Fatalf("Expected topology segments %v, got %v", expSegments, segments)
+		}
+	}
+}
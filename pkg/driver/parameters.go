@@ -0,0 +1,174 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/cloud"
+)
+
+const (
+	// VolumeTypeKey represents the StorageClass parameter for the EBS volume type.
+	VolumeTypeKey = "type"
+
+	// IopsPerGBKey represents the StorageClass parameter for the I/O operations per second per GiB.
+	IopsPerGBKey = "iopsPerGB"
+
+	// IopsKey represents the StorageClass parameter for the I/O operations per second of the volume.
+	// It's mutually exclusive with IopsPerGBKey.
+	IopsKey = "iops"
+
+	// AllowAutoIOPSPerGBIncreaseKey represents the StorageClass parameter for whether the IOPS
+	// are increased to the minimum of the volume type when too few are requested, instead of failing.
+	AllowAutoIOPSPerGBIncreaseKey = "allowAutoIOPSPerGBIncrease"
+
+	// ThroughputKey represents the StorageClass parameter for the throughput of the volume in MiB/s.
+	ThroughputKey = "throughput"
+
+	// BlockExpressKey represents the StorageClass parameter for whether io2 volumes are
+	// io2 Block Express volumes, which have higher limits.
+	BlockExpressKey = "blockExpress"
+
+	// EncryptedKey represents the StorageClass parameter for whether the volume is encrypted or not.
+	EncryptedKey = "encrypted"
+
+	// KmsKeyIDKey represents the StorageClass parameter for the customer managed KMS key used to
+	// encrypt the volume. The AWS managed key is used when it's not set.
+	KmsKeyIDKey = "kmsKeyId"
+
+	// FsTypeKey represents the StorageClass parameter for the filesystem type.
+	// It's also used as volume attribute key so the node service knows how to format the volume.
+	FsTypeKey = "fsType"
+)
+
+// volumeParameters holds the StorageClass parameters of a CreateVolume request.
+type volumeParameters struct {
+	volumeType             string
+	blockExpress           bool
+	iopsPerGB              int64
+	iops                   int64
+	allowIOPSPerGBIncrease bool
+	throughput             int64
+	encrypted              bool
+	kmsKeyID               string
+	fsType                 string
+}
+
+// parseVolumeParameters validates the parameters of a CreateVolume request.
+// Unknown keys are rejected so that typos in a StorageClass don't go unnoticed.
+func parseVolumeParameters(params map[string]string) (*volumeParameters, error) {
+	p := &volumeParameters{}
+	for key, value := range params {
+		switch key {
+		case VolumeTypeKey:
+			switch value {
+			case cloud.VolumeTypeIO1, cloud.VolumeTypeIO2, cloud.VolumeTypeGP2, cloud.VolumeTypeGP3, cloud.VolumeTypeSC1, cloud.VolumeTypeST1:
+				p.volumeType = value
+			default:
+				return nil, fmt.Errorf("invalid volume type %q", value)
+			}
+		case IopsPerGBKey:
+			iopsPerGB, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || iopsPerGB <= 0 {
+				return nil, fmt.Errorf("invalid %s %q: must be a positive integer", IopsPerGBKey, value)
+			}
+			p.iopsPerGB = iopsPerGB
+		case IopsKey:
+			iops, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || iops <= 0 {
+				return nil, fmt.Errorf("invalid %s %q: must be a positive integer", IopsKey, value)
+			}
+			p.iops = iops
+		case AllowAutoIOPSPerGBIncreaseKey:
+			allow, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q: must be a boolean", AllowAutoIOPSPerGBIncreaseKey, value)
+			}
+			p.allowIOPSPerGBIncrease = allow
+		case ThroughputKey:
+			throughput, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || throughput <= 0 {
+				return nil, fmt.Errorf("invalid %s %q: must be a positive integer", ThroughputKey, value)
+			}
+			p.throughput = throughput
+		case BlockExpressKey:
+			blockExpress, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q: must be a boolean", BlockExpressKey, value)
+			}
+			p.blockExpress = blockExpress
+		case EncryptedKey:
+			encrypted, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q: must be a boolean", EncryptedKey, value)
+			}
+			p.encrypted = encrypted
+		case KmsKeyIDKey:
+			if len(value) == 0 {
+				return nil, fmt.Errorf("%s must not be empty", KmsKeyIDKey)
+			}
+			p.kmsKeyID = value
+		case FsTypeKey:
+			if !isSupportedFsType(value) {
+				return nil, fmt.Errorf("invalid %s %q: filesystem not supported", FsTypeKey, value)
+			}
+			p.fsType = value
+		default:
+			return nil, fmt.Errorf("invalid parameter key %q", key)
+		}
+	}
+
+	if p.iops > 0 && p.iopsPerGB > 0 {
+		return nil, fmt.Errorf("%s and %s are mutually exclusive", IopsKey, IopsPerGBKey)
+	}
+	if p.blockExpress && p.volumeType != cloud.VolumeTypeIO2 {
+		return nil, fmt.Errorf("%s requires %s to be %s", BlockExpressKey, VolumeTypeKey, cloud.VolumeTypeIO2)
+	}
+	if len(p.kmsKeyID) > 0 && !p.encrypted {
+		return nil, fmt.Errorf("%s requires %s to be true", KmsKeyIDKey, EncryptedKey)
+	}
+	return p, nil
+}
+
+// matchesEncryption returns whether an existing disk has the requested encryption settings.
+// Requests without encryption match encrypted disks too, as EC2 encrypts volumes when encryption
+// by default is enabled for the account or when their snapshot is encrypted. EC2 always reports
+// the ARN of the key, while the StorageClass may use a key ID, a key ARN or an alias. Aliases
+// can't be resolved without KMS, so only the encryption itself is checked for them.
+func (p *volumeParameters) matchesEncryption(disk *cloud.Disk) bool {
+	if !p.encrypted {
+		return true
+	}
+	if !disk.Encrypted {
+		return false
+	}
+	if len(p.kmsKeyID) == 0 || strings.Contains(p.kmsKeyID, "alias/") {
+		return true
+	}
+	return disk.KmsKeyID == p.kmsKeyID || strings.HasSuffix(disk.KmsKeyID, ":key/"+p.kmsKeyID)
+}
+
+// volumeAttributes returns the attributes that are passed along to the node service.
+func (p *volumeParameters) volumeAttributes() map[string]string {
+	if len(p.fsType) == 0 {
+		return nil
+	}
+	return map[string]string{FsTypeKey: p.fsType}
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/cloud"
+)
+
+func TestParseVolumeParameters(t *testing.T) {
+	testCases := []struct {
+		name      string
+		params    map[string]string
+		expParams *volumeParameters
+		expErr    bool
+	}{
+		{
+			name:      "success: no parameters",
+			params:    nil,
+			expParams: &volumeParameters{},
+		},
+		{
+			name: "success: all parameters",
+			params: map[string]string{
+				VolumeTypeKey: "io1",
+				IopsPerGBKey:  "10",
+				EncryptedKey:  "true",
+				KmsKeyIDKey:   "arn:aws:kms:us-east-1:012345678910:key/abcd1234",
+				FsTypeKey:     "xfs",
+			},
+			expParams: &volumeParameters{
+				volumeType: "io1",
+				iopsPerGB:  10,
+				encrypted:  true,
+				kmsKeyID:   "arn:aws:kms:us-east-1:012345678910:key/abcd1234",
+				fsType:     "xfs",
+			},
+		},
+		{
+			name: "success: gp3 with IOPS and throughput",
+			params: map[string]string{
+				VolumeTypeKey:                 "gp3",
+				IopsKey:                       "4000",
+				ThroughputKey:                 "250",
+				AllowAutoIOPSPerGBIncreaseKey: "true",
+			},
+			expParams: &volumeParameters{
+				volumeType:             "gp3",
+				iops:                   4000,
+				throughput:             250,
+				allowIOPSPerGBIncrease: true,
+			},
+		},
+		{
+			name: "success: io2 Block Express",
+			params: map[string]string{
+				VolumeTypeKey:   "io2",
+				BlockExpressKey: "true",
+				IopsPerGBKey:    "1000",
+			},
+			expParams: &volumeParameters{
+				volumeType:   "io2",
+				blockExpress: true,
+				iopsPerGB:    1000,
+			},
+		},
+		{
+			name:   "fail: iops and iopsPerGB",
+			params: map[string]string{VolumeTypeKey: "io1", IopsKey: "1000", IopsPerGBKey: "10"},
+			expErr: true,
+		},
+		{
+			name:   "fail: invalid iops",
+			params: map[string]string{IopsKey: "0"},
+			expErr: true,
+		},
+		{
+			name:   "fail: invalid throughput",
+			params: map[string]string{ThroughputKey: "fast"},
+			expErr: true,
+		},
+		{
+			name:   "fail: invalid allowAutoIOPSPerGBIncrease",
+			params: map[string]string{AllowAutoIOPSPerGBIncreaseKey: "maybe"},
+			expErr: true,
+		},
+		{
+			name:   "fail: blockExpress without io2",
+			params: map[string]string{VolumeTypeKey: "gp3", BlockExpressKey: "true"},
+			expErr: true,
+		},
+		{
+			name:   "fail: unknown key",
+			params: map[string]string{"unknown": "value"},
+			expErr: true,
+		},
+		{
+			name:   "fail: invalid volume type",
+			params: map[string]string{VolumeTypeKey: "gp9"},
+			expErr: true,
+		},
+		{
+			name:   "fail: invalid iopsPerGB",
+			params: map[string]string{IopsPerGBKey: "ten"},
+			expErr: true,
+		},
+		{
+			name:   "fail: negative iopsPerGB",
+			params: map[string]string{IopsPerGBKey: "-10"},
+			expErr: true,
+		},
+		{
+			name:   "fail: empty fsType",
+			params: map[string]string{FsTypeKey: ""},
+			expErr: true,
+		},
+		{
+			name:   "fail: unsupported fsType",
+			params: map[string]string{FsTypeKey: "vfat"},
+			expErr: true,
+		},
+		{
+			name:   "fail: invalid encrypted",
+			params: map[string]string{EncryptedKey: "yes"},
+			expErr: true,
+		},
+		{
+			name:   "fail: kmsKeyId without encrypted",
+			params: map[string]string{KmsKeyIDKey: "abcd1234"},
+			expErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			params, err := parseVolumeParameters(tc.params)
+			if err != nil {
+				if !tc.expErr {
+					t.Fatalf("parseVolumeParameters() failed: expected no error, got: %v", err)
+				}
+				return
+			}
+			if tc.expErr {
+				t.Fatal("parseVolumeParameters() failed: expected error, got nothing")
+			}
+			if !reflect.DeepEqual(params, tc.expParams) {
+				t.Fatalf("parseVolumeParameters() failed: expected %+v, got %+v", tc.expParams, params)
+			}
+		})
+	}
+}
+
+func TestMatchesEncryption(t *testing.T) {
+	keyARN := "arn:aws:kms:us-east-1:012345678910:key/abcd1234"
+	testCases := []struct {
+		name     string
+		params   *volumeParameters
+		disk     *cloud.Disk
+		expMatch bool
+	}{
+		{
+			name:     "unencrypted request matches unencrypted disk",
+			params:   &volumeParameters{},
+			disk:     &cloud.Disk{},
+			expMatch: true,
+		},
+		{
+			name:     "unencrypted request matches encrypted disk",
+			params:   &volumeParameters{},
+			disk:     &cloud.Disk{Encrypted: true, KmsKeyID: keyARN},
+			expMatch: true,
+		},
+		{
+			name:     "encrypted request does not match unencrypted disk",
+			params:   &volumeParameters{encrypted: true},
+			disk:     &cloud.Disk{},
+			expMatch: false,
+		},
+		{
+			name:     "key ARN matches",
+			params:   &volumeParameters{encrypted: true, kmsKeyID: keyARN},
+			disk:     &cloud.Disk{Encrypted: true, KmsKeyID: keyARN},
+			expMatch: true,
+		},
+		{
+			name:     "key ID matches",
+			params:   &volumeParameters{encrypted: true, kmsKeyID: "abcd1234"},
+			disk:     &cloud.Disk{Encrypted: true, KmsKeyID: keyARN},
+			expMatch: true,
+		},
+		{
+			name:     "alias is not compared",
+			params:   &volumeParameters{encrypted: true, kmsKeyID: "alias/my-key"},
+			disk:     &cloud.Disk{Encrypted: true, KmsKeyID: keyARN},
+			expMatch: true,
+		},
+		{
+			name:     "different key does not match",
+			params:   &volumeParameters{encrypted: true, kmsKeyID: "efgh5678"},
+			disk:     &cloud.Disk{Encrypted: true, KmsKeyID: keyARN},
+			expMatch: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if match := tc.params.matchesEncryption(tc.disk); match != tc.expMatch {
+				t.Fatalf("matchesEncryption() failed: expected %v, got %v", tc.expMatch, match)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/logging"
+)
+
+// resizeFs grows the filesystem of the device mounted at deviceMountPath so it
+// fills the whole device. It's a no-op if the filesystem already fills it.
+func (d *Driver) resizeFs(ctx context.Context, devicePath, deviceMountPath, fsType string) error {
+	var cmd string
+	var args []string
+	switch fsType {
+	case "ext2", "ext3", "ext4":
+		cmd, args = "resize2fs", []string{devicePath}
+	case "xfs":
+		// xfs and btrfs can only be grown while they are mounted.
+		cmd, args = "xfs_growfs", []string{"-d", deviceMountPath}
+	case "btrfs":
+		cmd, args = "btrfs", []string{"filesystem", "resize", "max", deviceMountPath}
+	default:
+		logging.FromContext(ctx).Warningf("Resizing filesystem %s of device %s is not supported", fsType, devicePath)
+		return nil
+	}
+
+	logging.FromContext(ctx).V(5).Infof("Resizing filesystem %s of device %s mounted at %s", fsType, devicePath, deviceMountPath)
+	output, err := d.mounter.Exec.Run(cmd, args...)
+	if err != nil {
+		return fmt.Errorf("%s failed: %v, output: %s", cmd, err, string(output))
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"os"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// volumeStats represents the capacity and usage of a published volume.
+// Inodes are only reported for filesystem volumes.
+type volumeStats struct {
+	availableBytes int64
+	totalBytes     int64
+	usedBytes      int64
+
+	availableInodes int64
+	totalInodes     int64
+	usedInodes      int64
+}
+
+// getVolumeStats returns the capacity and usage of the volume published at volumePath.
+func (d *Driver) getVolumeStats(volumePath string) (*volumeStats, error) {
+	info, err := os.Stat(volumePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, status.Errorf(codes.NotFound, "Path %q does not exist", volumePath)
+		}
+		return nil, status.Errorf(codes.Internal, "Could not stat %q: %v", volumePath, err)
+	}
+
+	// Published volumes are bind mounts, which IsLikelyNotMountPoint can't detect
+	mp, err := d.findMountPoint(volumePath)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not determine if %q is a mount point: %v", volumePath, err)
+	}
+	if mp == nil {
+		return nil, status.Errorf(codes.NotFound, "No volume is mounted at %q", volumePath)
+	}
+
+	// Raw block volumes are published as the device node itself
+	if info.Mode()&os.ModeDevice != 0 {
+		size, err := getBlockSizeBytes(volumePath)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Could not get size of block device %q: %v", volumePath, err)
+		}
+		return &volumeStats{totalBytes: size}, nil
+	}
+
+	stats, err := getFilesystemStats(volumePath)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not get filesystem stats of %q: %v", volumePath, err)
+	}
+	return stats, nil
+}
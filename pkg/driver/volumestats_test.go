@@ -0,0 +1,125 @@
+//go:build linux
+// +build linux
+
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/cloud"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/kubernetes/pkg/util/mount"
+)
+
+func TestNodeGetVolumeStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "volumestats")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	mountedPath := filepath.Join(dir, "mounted")
+	notMountedPath := filepath.Join(dir, "not-mounted")
+	mkdirAll(t, mountedPath)
+	mkdirAll(t, notMountedPath)
+
+	testCases := []struct {
+		name       string
+		req        *csi.NodeGetVolumeStatsRequest
+		expErrCode codes.Code
+	}{
+		{
+			name: "success filesystem",
+			req: &csi.NodeGetVolumeStatsRequest{
+				VolumeId:   "vol-test",
+				VolumePath: mountedPath,
+			},
+		},
+		{
+			name: "fail no volume id",
+			req: &csi.NodeGetVolumeStatsRequest{
+				VolumePath: mountedPath,
+			},
+			expErrCode: codes.InvalidArgument,
+		},
+		{
+			name: "fail no volume path",
+			req: &csi.NodeGetVolumeStatsRequest{
+				VolumeId: "vol-test",
+			},
+			expErrCode: codes.InvalidArgument,
+		},
+		{
+			name: "fail path does not exist",
+			req: &csi.NodeGetVolumeStatsRequest{
+				VolumeId:   "vol-test",
+				VolumePath: filepath.Join(dir, "missing"),
+			},
+			expErrCode: codes.NotFound,
+		},
+		{
+			name: "fail path is not mounted",
+			req: &csi.NodeGetVolumeStatsRequest{
+				VolumeId:   "vol-test",
+				VolumePath: notMountedPath,
+			},
+			expErrCode: codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("Test case: %s", tc.name)
+		mounter := NewFakeMounter()
+		mounter.Interface.(*mount.FakeMounter).MountPoints = []mount.MountPoint{
+			{Device: "/dev/xvdbc", Path: mountedPath, Type: "ext4"},
+		}
+		awsDriver := NewDriver(cloud.NewFakeCloudProvider(), mounter, "")
+
+		resp, err := awsDriver.NodeGetVolumeStats(context.TODO(), tc.req)
+		if err != nil {
+			srvErr, ok := status.FromError(err)
+			if !ok {
+				t.Fatalf("Could not get error status code from error: %v", srvErr)
+			}
+			if srvErr.Code() != tc.expErrCode {
+				t.Fatalf("Expected error code %d, got %d message %s", tc.expErrCode, srvErr.Code(), srvErr.Message())
+			}
+			continue
+		}
+		if tc.expErrCode != codes.OK {
+			t.Fatalf("Expected error %v, got no error", tc.expErrCode)
+		}
+
+		usage := resp.GetUsage()
+		if len(usage) == 0 || usage[0].GetUnit() != csi.VolumeUsage_BYTES {
+			t.Fatalf("Expected byte usage first, got %+v", usage)
+		}
+		for _, u := range usage {
+			if u.GetTotal() <= 0 || u.GetAvailable() > u.GetTotal() || u.GetUsed() > u.GetTotal() {
+				t.Fatalf("Expected consistent %v usage, got %+v", u.GetUnit(), u)
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package logging implements request-scoped logging. The fields of a request, such as its ID
+// and the IDs of the volume and node it applies to, are carried by its context and prefixed to
+// the log lines of the layers handling it, so that they can be correlated.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// RequestIDKey is the field holding the ID of the request.
+	RequestIDKey = "request_id"
+	// MethodKey is the field holding the CSI RPC of the request.
+	MethodKey = "method"
+	// VolumeIDKey is the field holding the ID of the volume of the request.
+	VolumeIDKey = "volume_id"
+	// NodeIDKey is the field holding the ID of the node of the request.
+	NodeIDKey = "node_id"
+	// SnapshotIDKey is the field holding the ID of the snapshot of the request.
+	SnapshotIDKey = "snapshot_id"
+)
+
+type fieldsKey struct{}
+
+// field is a key and value pair logged with the requests.
+type field struct {
+	key   string
+	value string
+}
+
+// WithFields returns a context carrying the fields of ctx and the given key and value pairs.
+// Fields with an empty value are ignored, and a field overrides the field of ctx with the same key.
+func WithFields(ctx context.Context, keysAndValues ...string) context.Context {
+	if len(keysAndValues)%2 != 0 {
+		panic(fmt.Sprintf("odd number of keys and values: %v", keysAndValues))
+	}
+
+	parent, _ := ctx.Value(fieldsKey{}).([]field)
+	fields := make([]field, 0, len(parent)+len(keysAndValues)/2)
+	for _, f := range parent {
+		if !containsKey(keysAndValues, f.key) {
+			fields = append(fields, f)
+		}
+	}
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if keysAndValues[i+1] != "" {
+			fields = append(fields, field{key: keysAndValues[i], value: keysAndValues[i+1]})
+		}
+	}
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+func containsKey(keysAndValues []string, key string) bool {
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if keysAndValues[i] == key && keysAndValues[i+1] != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Field returns the value of the field of ctx with the given key, or "" if it has no such field.
+func Field(ctx context.Context, key string) string {
+	fields, _ := ctx.Value(fieldsKey{}).([]field)
+	for _, f := range fields {
+		if f.key == key {
+			return f.value
+		}
+	}
+	return ""
+}
+
+// NewRequestID returns a random ID for a request.
+func NewRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		glog.Warningf("Could not generate request ID: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Logger logs with the fields of a request.
+type Logger struct {
+	prefix string
+}
+
+// FromContext returns the logger of the request of ctx. Without fields, it logs as glog does.
+func FromContext(ctx context.Context) Logger {
+	fields, _ := ctx.Value(fieldsKey{}).([]field)
+	if len(fields) == 0 {
+		return Logger{}
+	}
+	pairs := make([]string, 0, len(fields))
+	for _, f := range fields {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", f.key, f.value))
+	}
+	return Logger{prefix: "[" + strings.Join(pairs, " ") + "] "}
+}
+
+func (l Logger) Infof(format string, args ...interface{}) {
+	glog.InfoDepth(1, l.prefix+fmt.Sprintf(format, args...))
+}
+
+func (l Logger) Warningf(format string, args ...interface{}) {
+	glog.WarningDepth(1, l.prefix+fmt.Sprintf(format, args...))
+}
+
+func (l Logger) Errorf(format string, args ...interface{}) {
+	glog.ErrorDepth(1, l.prefix+fmt.Sprintf(format, args...))
+}
+
+// V returns a logger that only logs when the verbosity is at least level, as glog.V does.
+func (l Logger) V(level glog.Level) Verbose {
+	return Verbose{logger: l, enabled: bool(glog.V(level))}
+}
+
+// Verbose is a logger enabled depending on the verbosity.
+type Verbose struct {
+	logger  Logger
+	enabled bool
+}
+
+func (v Verbose) Infof(format string, args ...interface{}) {
+	if v.enabled {
+		glog.InfoDepth(1, v.logger.prefix+fmt.Sprintf(format, args...))
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithFields(t *testing.T) {
+	testCases := []struct {
+		name         string
+		parentFields []string
+		fields       []string
+		expPrefix    string
+		expVolumeID  string
+		expRequestID string
+	}{
+		{
+			name:      "no fields",
+			expPrefix: "",
+		},
+		{
+			name:         "fields",
+			fields:       []string{RequestIDKey, "1234", VolumeIDKey, "vol-test"},
+			expPrefix:    `[request_id="1234" volume_id="vol-test"] `,
+			expVolumeID:  "vol-test",
+			expRequestID: "1234",
+		},
+		{
+			name:         "empty fields are ignored",
+			fields:       []string{RequestIDKey, "1234", VolumeIDKey, ""},
+			expPrefix:    `[request_id="1234"] `,
+			expRequestID: "1234",
+		},
+		{
+			name:         "fields are added to the fields of the parent",
+			parentFields: []string{RequestIDKey, "1234", VolumeIDKey, "vol-test"},
+			fields:       []string{NodeIDKey, "i-1234"},
+			expPrefix:    `[request_id="1234" volume_id="vol-test" node_id="i-1234"] `,
+			expVolumeID:  "vol-test",
+			expRequestID: "1234",
+		},
+		{
+			name:         "fields override the fields of the parent",
+			parentFields: []string{RequestIDKey, "1234", VolumeIDKey, "vol-test"},
+			fields:       []string{VolumeIDKey, "vol-other", NodeIDKey, ""},
+			expPrefix:    `[request_id="1234" volume_id="vol-other"] `,
+			expVolumeID:  "vol-other",
+			expRequestID: "1234",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.parentFields != nil {
+				ctx = WithFields(ctx, tc.parentFields...)
+			}
+			ctx = WithFields(ctx, tc.fields...)
+
+			if prefix := FromContext(ctx).prefix; prefix != tc.expPrefix {
+				t.Fatalf("Expected prefix %q, got %q", tc.expPrefix, prefix)
+			}
+			if volumeID := Field(ctx, VolumeIDKey); volumeID != tc.expVolumeID {
+				t.Fatalf("Expected volume ID %q, got %q", tc.expVolumeID, volumeID)
+			}
+			if requestID := Field(ctx, RequestIDKey); requestID != tc.expRequestID {
+				t.Fatalf("Expected request ID %q, got %q", tc.expRequestID, requestID)
+			}
+		})
+	}
+}
+
+func TestNewRequestID(t *testing.T) {
+	id1, id2 := NewRequestID(), NewRequestID()
+	if len(id1) != 16 {
+		t.Fatalf("Expected request ID of 16 characters, got %q", id1)
+	}
+	if id1 == id2 {
+		t.Fatalf("Expected request IDs to be unique, got %q twice", id1)
+	}
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package testutil reads the values of Prometheus metrics in tests, which the vendored
+// client_golang has no helpers for.
+package testutil
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// CounterValue returns the value of the counter of vec with the label values.
+func CounterValue(t *testing.T, vec *prometheus.CounterVec, labelValues ...string) float64 {
+	m := &dto.Metric{}
+	if err := vec.WithLabelValues(labelValues...).Write(m); err != nil {
+		t.Fatalf("Could not read counter %v: %v", labelValues, err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+// HistogramCount returns the number of observations of the histogram of vec with the label values.
+func HistogramCount(t *testing.T, vec *prometheus.HistogramVec, labelValues ...string) uint64 {
+	m := &dto.Metric{}
+	if err := vec.WithLabelValues(labelValues...).(prometheus.Histogram).Write(m); err != nil {
+		t.Fatalf("Could not read histogram %v: %v", labelValues, err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
@@ -26,8 +26,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
-	csi "github.com/container-storage-interface/spec/lib/go/csi/v0"
-	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/cloud"
+	"github.com/container-storage-interface/spec/lib/go/csi"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -65,24 +64,76 @@ var _ = Describe("EBS CSI Driver", func() {
 
 		volume := resp.GetVolume()
 		Expect(volume).NotTo(BeNil(), "Expected valid volume, got nil")
-		waitForVolumeState(volume.Id, "available")
+		waitForVolumeState(volume.VolumeId, "available")
 
 		// Delete volume
 		defer func() {
-			_, err = csiClient.ctrl.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: volume.Id})
+			_, err = csiClient.ctrl.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: volume.VolumeId})
 			Expect(err).To(BeNil(), "Could not delete volume")
-			waitForVolumes(volume.Id, 0 /* number of expected volumes */)
+			waitForVolumes(volume.VolumeId, 0 /* number of expected volumes */)
 
 			// Deleting volume twice
-			_, err = csiClient.ctrl.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: volume.Id})
+			_, err = csiClient.ctrl.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: volume.VolumeId})
 			Expect(err).To(BeNil(), "Error when trying to delete volume twice")
 		}()
 
 		// Attach, stage, publish, unpublish, unstage, detach
 		nodeID := ebs.GetMetadata().GetInstanceID()
-		testAttachWriteReadDetach(volume.Id, req.GetName(), nodeID, false)
+		testAttachWriteReadDetach(volume.VolumeId, req.GetName(), nodeID, false)
 
 	})
+
+	It("Should create a snapshot of a volume, list it, delete it, and check if it's deleted", func() {
+
+		r1 := rand.New(rand.NewSource(time.Now().UnixNano()))
+		req := &csi.CreateVolumeRequest{
+			Name:               fmt.Sprintf("volume-name-e2e-test-%d", r1.Uint64()),
+			CapacityRange:      stdCapRange,
+			VolumeCapabilities: stdVolCap,
+			Parameters:         nil,
+		}
+
+		resp, err := csiClient.ctrl.CreateVolume(context.Background(), req)
+		Expect(err).To(BeNil(), "Could not create volume")
+
+		volume := resp.GetVolume()
+		Expect(volume).NotTo(BeNil(), "Expected valid volume, got nil")
+		waitForVolumeState(volume.VolumeId, "available")
+
+		// Delete volume
+		defer func() {
+			_, err = csiClient.ctrl.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: volume.VolumeId})
+			Expect(err).To(BeNil(), "Could not delete volume")
+			waitForVolumes(volume.VolumeId, 0 /* number of expected volumes */)
+		}()
+
+		snapshotReq := &csi.CreateSnapshotRequest{
+			Name:           fmt.Sprintf("snapshot-name-e2e-test-%d", r1.Uint64()),
+			SourceVolumeId: volume.VolumeId,
+		}
+		snapshotResp, err := csiClient.ctrl.CreateSnapshot(context.Background(), snapshotReq)
+		Expect(err).To(BeNil(), "Could not create snapshot")
+
+		snapshot := snapshotResp.GetSnapshot()
+		Expect(snapshot).NotTo(BeNil(), "Expected valid snapshot, got nil")
+		Expect(snapshot.SourceVolumeId).To(Equal(volume.VolumeId), "Unexpected snapshot source volume")
+
+		// Creating the same snapshot twice
+		snapshotResp, err = csiClient.ctrl.CreateSnapshot(context.Background(), snapshotReq)
+		Expect(err).To(BeNil(), "Error when trying to create snapshot twice")
+		Expect(snapshotResp.GetSnapshot().SnapshotId).To(Equal(snapshot.SnapshotId), "Expected same snapshot when creating it twice")
+
+		listResp, err := csiClient.ctrl.ListSnapshots(context.Background(), &csi.ListSnapshotsRequest{SnapshotId: snapshot.SnapshotId})
+		Expect(err).To(BeNil(), "Could not list snapshots")
+		Expect(listResp.GetEntries()).To(HaveLen(1), "Expected snapshot to be listed")
+
+		_, err = csiClient.ctrl.DeleteSnapshot(context.Background(), &csi.DeleteSnapshotRequest{SnapshotId: snapshot.SnapshotId})
+		Expect(err).To(BeNil(), "Could not delete snapshot")
+
+		// Deleting snapshot twice
+		_, err = csiClient.ctrl.DeleteSnapshot(context.Background(), &csi.DeleteSnapshotRequest{SnapshotId: snapshot.SnapshotId})
+		Expect(err).To(BeNil(), "Error when trying to delete snapshot twice")
+	})
 })
 
 func testAttachWriteReadDetach(volumeID, volName, nodeID string, readOnly bool) {
@@ -120,7 +171,7 @@ func testAttachWriteReadDetach(volumeID, volName, nodeID string, readOnly bool)
 			VolumeId:          volumeID,
 			StagingTargetPath: stageDir,
 			VolumeCapability:  stdVolCap[0],
-			PublishInfo:       map[string]string{"devicePath": respAttach.PublishInfo["devicePath"]},
+			PublishContext:    map[string]string{"devicePath": respAttach.PublishContext["devicePath"]},
 		})
 	Expect(err).To(BeNil(), "NodeStageVolume failed with error")
 
@@ -17,55 +17,117 @@ limitations under the License.
 package sanity
 
 import (
+	"context"
+	"net"
 	"os"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/container-storage-interface/spec/lib/go/csi"
 	sanity "github.com/kubernetes-csi/csi-test/pkg/sanity"
 	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/cloud"
 	"github.com/kubernetes-sigs/aws-ebs-csi-driver/pkg/driver"
+	"google.golang.org/grpc"
 )
 
+const (
+	mountPath = "/tmp/csi/mount"
+	stagePath = "/tmp/csi/stage"
+	socket    = "/tmp/csi.sock"
+	endpoint  = "unix://" + socket
+)
+
+var srv *grpc.Server
+
 func TestSanity(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "AWS EBS CSI Driver Sanity Tests")
 }
 
-var _ = Describe("AWS EBS CSI Driver", func() {
-	const (
-		mountPath = "/tmp/csi/mount"
-		stagePath = "/tmp/csi/stage"
-		socket    = "/tmp/csi.sock"
-		endpoint  = "unix://" + socket
-	)
+// The sanity tests reuse their connection, so the driver runs for the whole suite.
+var _ = BeforeSuite(func() {
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		Expect(err).To(BeNil())
+	}
+	listener, err := net.Listen("unix", socket)
+	Expect(err).To(BeNil())
 
+	d := &sanityDriver{driver.NewDriver(cloud.NewFakeCloudProvider(), driver.NewFakeMounter(), endpoint)}
+	srv = grpc.NewServer()
+	csi.RegisterIdentityServer(srv, d)
+	csi.RegisterControllerServer(srv, d)
+	csi.RegisterNodeServer(srv, d)
+	go func() {
+		defer GinkgoRecover()
+		Expect(srv.Serve(listener)).To(Succeed())
+	}()
+})
+
+var _ = AfterSuite(func() {
+	srv.Stop()
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		Expect(err).To(BeNil())
+	}
+})
+
+var _ = Describe("AWS EBS CSI Driver", func() {
 	config := &sanity.Config{
 		Address:     endpoint,
 		TargetPath:  mountPath,
 		StagingPath: stagePath,
 	}
 
-	var ebsDriver *driver.Driver
-
-	BeforeEach(func() {
-		ebsDriver = driver.NewDriver(cloud.NewFakeCloudProvider(), driver.NewFakeMounter(), endpoint)
-		go func() {
-			err := ebsDriver.Run()
-			Expect(err).To(BeNil())
-		}()
+	Describe("Sanity Test", func() {
+		sanity.GinkgoTest(config)
 	})
+})
+
+// sanityDriver hides the volume expansion capabilities of the driver, which the sanity tests
+// of csi-test v1.1.0 predate and fail on as unknown.
+type sanityDriver struct {
+	*driver.Driver
+}
 
-	AfterEach(func() {
-		ebsDriver.Stop()
-		if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
-			Expect(err).To(BeNil())
+func (d *sanityDriver) GetPluginCapabilities(ctx context.Context, req *csi.GetPluginCapabilitiesRequest) (*csi.GetPluginCapabilitiesResponse, error) {
+	resp, err := d.Driver.GetPluginCapabilities(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	var caps []*csi.PluginCapability
+	for _, cap := range resp.GetCapabilities() {
+		if cap.GetVolumeExpansion() == nil {
+			caps = append(caps, cap)
 		}
-	})
+	}
+	return &csi.GetPluginCapabilitiesResponse{Capabilities: caps}, nil
+}
 
-	Describe("Sanity Test", func() {
-		sanity.GinkgoTest(config)
-	})
+func (d *sanityDriver) ControllerGetCapabilities(ctx context.Context, req *csi.ControllerGetCapabilitiesRequest) (*csi.ControllerGetCapabilitiesResponse, error) {
+	resp, err := d.Driver.ControllerGetCapabilities(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	var caps []*csi.ControllerServiceCapability
+	for _, cap := range resp.GetCapabilities() {
+		if cap.GetRpc().GetType() != csi.ControllerServiceCapability_RPC_EXPAND_VOLUME {
+			caps = append(caps, cap)
+		}
+	}
+	return &csi.ControllerGetCapabilitiesResponse{Capabilities: caps}, nil
+}
 
-})
+func (d *sanityDriver) NodeGetCapabilities(ctx context.Context, req *csi.NodeGetCapabilitiesRequest) (*csi.NodeGetCapabilitiesResponse, error) {
+	resp, err := d.Driver.NodeGetCapabilities(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	var caps []*csi.NodeServiceCapability
+	for _, cap := range resp.GetCapabilities() {
+		if cap.GetRpc().GetType() != csi.NodeServiceCapability_RPC_EXPAND_VOLUME {
+			caps = append(caps, cap)
+		}
+	}
+	return &csi.NodeGetCapabilitiesResponse{Capabilities: caps}, nil
+}